@@ -0,0 +1,382 @@
+/*
+Package appledouble reads and writes AppleDouble files, the "._name"
+companions in which macOS keeps the extended attributes, Finder info and
+resource fork of "name" on filesystems that cannot store them natively.
+
+An AppleDouble file starts with a header and a table of entries. macOS
+writes a Finder info entry, whose tail holds the extended attributes in an
+"ATTR" section, followed by the resource fork entry. Other entries are
+preserved as they are.
+
+Import and Export convert between "._" files and native attributes, so that
+files copied from a Mac can be ingested without losing their metadata:
+
+	if err := appledouble.Import(path, true); err != nil {
+		...
+	}
+*/
+package appledouble
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// Entry IDs defined by the AppleSingle/AppleDouble format.
+const (
+	DataFork       = 1
+	ResourceFork   = 2
+	RealName       = 3
+	Comment        = 4
+	IconBW         = 5
+	IconColor      = 6
+	FileDatesInfo  = 8
+	FinderInfo     = 9
+	MacFileInfo    = 10
+	ProDOSFileInfo = 11
+	MSDOSFileInfo  = 12
+	ShortName      = 13
+	AFPFileInfo    = 14
+	DirectoryID    = 15
+)
+
+// Names of the attributes macOS presents the Finder info and the resource
+// fork as.
+const (
+	FinderInfoAttr   = "com.apple.FinderInfo"
+	ResourceForkAttr = "com.apple.ResourceFork"
+)
+
+const (
+	magic   = 0x00051607
+	version = 0x00020000
+	filler  = "Mac OS X        "
+
+	headerSize     = 26
+	entrySize      = 12
+	finderInfoSize = 32
+
+	// The attribute section follows the Finder info and two bytes of
+	// padding.
+	attrMagic      = 0x41545452 // "ATTR"
+	attrHeaderSize = 36
+	attrEntrySize  = 11 // without the name
+
+	// maxNameLen is XATTR_MAXNAMELEN from macOS <sys/xattr.h>, the
+	// longest attribute name macOS accepts.
+	maxNameLen = 127
+)
+
+// ErrFormat is returned for data that is not a valid AppleDouble file.
+var ErrFormat = errors.New("appledouble: invalid format")
+
+// Attr is an extended attribute with its macOS name.
+type Attr struct {
+	Name  string
+	Value []byte
+}
+
+// Entry is an entry of an AppleDouble file that this package does not
+// interpret.
+type Entry struct {
+	ID   uint32
+	Data []byte
+}
+
+// File is the decoded content of an AppleDouble file.
+type File struct {
+	// FinderInfo is the 32 byte Finder info, or nil if there is none.
+	FinderInfo []byte
+	// ResourceFork is the resource fork, or nil if there is none.
+	ResourceFork []byte
+	// Attrs are the extended attributes, sorted by name.
+	Attrs []Attr
+	// Entries are all other entries, in file order.
+	Entries []Entry
+}
+
+// Path returns the path of the AppleDouble file that belongs to path.
+func Path(path string) string {
+	dir, base := filepath.Split(filepath.Clean(path))
+	return filepath.Join(dir, "._"+base)
+}
+
+// IsAppleDouble reports whether the file name looks like an AppleDouble
+// file.
+func IsAppleDouble(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, "._") && len(base) > 2
+}
+
+// Decode parses an AppleDouble file.
+func Decode(data []byte) (*File, error) {
+	be := binary.BigEndian
+	if len(data) < headerSize || be.Uint32(data) != magic || be.Uint32(data[4:]) != version {
+		return nil, ErrFormat
+	}
+	n := int(be.Uint16(data[24:]))
+	if len(data) < headerSize+n*entrySize {
+		return nil, ErrFormat
+	}
+	f := &File{}
+	for i := 0; i < n; i++ {
+		e := data[headerSize+i*entrySize:]
+		id, off, length := be.Uint32(e), be.Uint32(e[4:]), be.Uint32(e[8:])
+		if uint64(off)+uint64(length) > uint64(len(data)) {
+			return nil, ErrFormat
+		}
+		body := data[off : off+length]
+		switch id {
+		case FinderInfo:
+			if len(body) < finderInfoSize {
+				return nil, ErrFormat
+			}
+			f.FinderInfo = append([]byte{}, body[:finderInfoSize]...)
+			attrs, err := decodeAttrs(data, body)
+			if err != nil {
+				return nil, err
+			}
+			f.Attrs = attrs
+		case ResourceFork:
+			f.ResourceFork = append([]byte{}, body...)
+		default:
+			f.Entries = append(f.Entries, Entry{id, append([]byte{}, body...)})
+		}
+	}
+	sortAttrs(f.Attrs)
+	return f, nil
+}
+
+// decodeAttrs parses the attribute section at the end of the Finder info
+// entry fi. The offsets in the section are relative to the start of the
+// file.
+func decodeAttrs(data, fi []byte) ([]Attr, error) {
+	be := binary.BigEndian
+	if len(fi) < finderInfoSize+2+attrHeaderSize {
+		return nil, nil
+	}
+	h := fi[finderInfoSize+2:]
+	if be.Uint32(h) != attrMagic {
+		return nil, nil
+	}
+	n := int(be.Uint16(h[34:]))
+	p := h[attrHeaderSize:]
+	attrs := make([]Attr, 0, n)
+	for i := 0; i < n; i++ {
+		if len(p) < attrEntrySize {
+			return nil, ErrFormat
+		}
+		off, length, nameLen := be.Uint32(p), be.Uint32(p[4:]), int(p[10])
+		if nameLen == 0 || len(p) < attrEntrySize+nameLen || uint64(off)+uint64(length) > uint64(len(data)) {
+			return nil, ErrFormat
+		}
+		name := p[attrEntrySize : attrEntrySize+nameLen]
+		name = bytes.TrimRight(name, "\x00")
+		attrs = append(attrs, Attr{string(name), append([]byte{}, data[off:off+length]...)})
+		next := align4(attrEntrySize + nameLen)
+		if next > len(p) {
+			next = len(p)
+		}
+		p = p[next:]
+	}
+	return attrs, nil
+}
+
+func align4(n int) int { return (n + 3) &^ 3 }
+
+func sortAttrs(attrs []Attr) {
+	sort.SliceStable(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+}
+
+// Read decodes an AppleDouble file from r.
+func Read(r io.Reader) (*File, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Encode returns f in the layout macOS uses: the Finder info entry with the
+// attribute section, then the other entries and the resource fork last.
+// A missing Finder info is written as zeros.
+func (f *File) Encode() ([]byte, error) {
+	be := binary.BigEndian
+	attrs := append([]Attr{}, f.Attrs...)
+	sortAttrs(attrs)
+
+	n := 2 + len(f.Entries)
+	buf := make([]byte, headerSize+n*entrySize)
+	be.PutUint32(buf, magic)
+	be.PutUint32(buf[4:], version)
+	copy(buf[8:], filler)
+	be.PutUint16(buf[24:], uint16(n))
+
+	// The entry table is filled in as the entries are appended; buf may
+	// be reallocated meanwhile.
+	next := headerSize
+	entry := func(id uint32, off, length int) {
+		be.PutUint32(buf[next:], id)
+		be.PutUint32(buf[next+4:], uint32(off))
+		be.PutUint32(buf[next+8:], uint32(length))
+		next += entrySize
+	}
+
+	// Finder info, padding and attribute section.
+	start := len(buf)
+	fi := make([]byte, finderInfoSize)
+	copy(fi, f.FinderInfo)
+	buf = append(buf, fi...)
+	if len(attrs) > 0 {
+		buf = append(buf, 0, 0)
+		hdr := len(buf)
+		buf = append(buf, make([]byte, attrHeaderSize)...)
+		entries := make([]int, len(attrs))
+		for i, a := range attrs {
+			if len(a.Name) == 0 || len(a.Name) > maxNameLen {
+				return nil, ErrFormat
+			}
+			entries[i] = len(buf)
+			rec := make([]byte, align4(attrEntrySize+len(a.Name)+1))
+			rec[10] = byte(len(a.Name) + 1)
+			copy(rec[attrEntrySize:], a.Name)
+			buf = append(buf, rec...)
+		}
+		dataStart := len(buf)
+		for i, a := range attrs {
+			be.PutUint32(buf[entries[i]:], uint32(len(buf)))
+			be.PutUint32(buf[entries[i]+4:], uint32(len(a.Value)))
+			buf = append(buf, a.Value...)
+		}
+		h := buf[hdr:]
+		be.PutUint32(h, attrMagic)
+		be.PutUint32(h[8:], uint32(len(buf)))
+		be.PutUint32(h[12:], uint32(dataStart))
+		be.PutUint32(h[16:], uint32(len(buf)-dataStart))
+		be.PutUint16(h[34:], uint16(len(attrs)))
+	}
+	entry(FinderInfo, start, len(buf)-start)
+
+	for _, e := range f.Entries {
+		entry(e.ID, len(buf), len(e.Data))
+		buf = append(buf, e.Data...)
+	}
+	entry(ResourceFork, len(buf), len(f.ResourceFork))
+	buf = append(buf, f.ResourceFork...)
+	return buf, nil
+}
+
+// WriteTo writes the encoded file to w.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	data, err := f.Encode()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Import reads the AppleDouble file of path and stores its attributes, the
+// Finder info and the resource fork as native extended attributes of path,
+// under their names on the current platform. Finder info that is all zeros
+// and an empty resource fork are skipped, as macOS does. If remove is set,
+// the AppleDouble file is removed afterwards.
+func Import(path string, remove bool) error {
+	ad := Path(path)
+	data, err := ioutil.ReadFile(ad)
+	if err != nil {
+		return err
+	}
+	f, err := Decode(data)
+	if err != nil {
+		return &os.PathError{Op: "appledouble.Import", Path: ad, Err: err}
+	}
+	attrs := f.Attrs
+	if f.FinderInfo != nil && !bytes.Equal(f.FinderInfo, make([]byte, finderInfoSize)) {
+		attrs = append(attrs, Attr{FinderInfoAttr, f.FinderInfo})
+	}
+	if len(f.ResourceFork) > 0 {
+		attrs = append(attrs, Attr{ResourceForkAttr, f.ResourceFork})
+	}
+	for _, a := range attrs {
+		name, err := xattr.TranslateName("darwin", runtime.GOOS, a.Name)
+		if err != nil {
+			return err
+		}
+		if err := xattr.LSet(path, name, a.Value); err != nil {
+			return err
+		}
+	}
+	if remove {
+		return os.Remove(ad)
+	}
+	return nil
+}
+
+// Export writes the extended attributes of path to its AppleDouble file.
+// Attributes that have no macOS name, such as those outside the user
+// namespace on Linux, are left out. If nothing is left, no AppleDouble
+// file is written.
+func Export(path string) error {
+	attrs, err := walk.Read(path, nil)
+	if err != nil {
+		return err
+	}
+	f := &File{}
+	for _, a := range attrs {
+		name, err := xattr.TranslateName(runtime.GOOS, "darwin", a.Name)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case FinderInfoAttr:
+			f.FinderInfo = a.Value
+		case ResourceForkAttr:
+			f.ResourceFork = a.Value
+		default:
+			f.Attrs = append(f.Attrs, Attr{name, a.Value})
+		}
+	}
+	if f.FinderInfo == nil && f.ResourceFork == nil && len(f.Attrs) == 0 {
+		return nil
+	}
+	data, err := f.Encode()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(Path(path), data, 0644)
+}
+
+// ImportTree imports the AppleDouble files in the tree rooted at root whose
+// file exists and returns how many it imported. If remove is set, imported
+// AppleDouble files are removed.
+func ImportTree(root string, remove bool) (int, error) {
+	n := 0
+	err := walk.Walk(root, func(path, _ string, info os.FileInfo) error {
+		if info.IsDir() || !IsAppleDouble(path) {
+			return nil
+		}
+		dir, base := filepath.Split(path)
+		file := filepath.Join(dir, base[2:])
+		if _, err := os.Lstat(file); err != nil {
+			return nil
+		}
+		if err := Import(file, remove); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
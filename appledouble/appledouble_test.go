@@ -0,0 +1,157 @@
+package appledouble
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	fi := make([]byte, 32)
+	copy(fi, "TEXTttxt")
+	f := &File{
+		FinderInfo:   fi,
+		ResourceFork: []byte("resource"),
+		Attrs: []Attr{
+			{"com.apple.quarantine", []byte("0083;5f000000;Safari;")},
+			{"com.apple.lastuseddate#PS", []byte{1, 2, 3}},
+			{"empty", []byte{}},
+		},
+	}
+	data, err := f.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The layout matches what macOS writes.
+	be := binary.BigEndian
+	if be.Uint32(data) != magic || string(data[8:24]) != filler || be.Uint16(data[24:]) != 2 {
+		t.Errorf("bad header % x", data[:26])
+	}
+	if be.Uint32(data[26:]) != FinderInfo || be.Uint32(data[30:]) != 0x32 {
+		t.Errorf("bad Finder info entry % x", data[26:38])
+	}
+	if !bytes.Equal(data[0x32:0x3a], []byte("TEXTttxt")) || string(data[0x54:0x58]) != "ATTR" {
+		t.Errorf("bad Finder info % x", data[0x32:0x58])
+	}
+
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortAttrs(f.Attrs)
+	if !reflect.DeepEqual(got, f) {
+		t.Errorf("Decode(Encode(f)) = %+v, want %+v", got, f)
+	}
+
+	// Other entries are preserved.
+	f.Entries = []Entry{{RealName, []byte("name.txt")}}
+	data, _ = f.Encode()
+	if got, err := Decode(data); err != nil || !reflect.DeepEqual(got, f) {
+		t.Errorf("Decode with extra entry = %+v, %v", got, err)
+	}
+
+	// macOS pads the Finder info entry; trailing space must be ignored.
+	padded := &File{Attrs: []Attr{{"a", []byte("b")}}}
+	data, _ = padded.Encode()
+	length := be.Uint32(data[34:])
+	data = append(data[:0x32+length], append(make([]byte, 100), data[0x32+length:]...)...)
+	be.PutUint32(data[34:], length+100)
+	be.PutUint32(data[42:], be.Uint32(data[42:])+100)
+	if got, err := Decode(data); err != nil || !reflect.DeepEqual(got.Attrs, padded.Attrs) {
+		t.Errorf("Decode of padded file = %+v, %v", got, err)
+	}
+
+	for _, bad := range [][]byte{nil, []byte("not an appledouble file at all"), data[:40]} {
+		if _, err := Decode(bad); err != ErrFormat {
+			t.Errorf("Decode(%q) = %v", bad, err)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	if got := Path(filepath.FromSlash("a/b.txt")); got != filepath.FromSlash("a/._b.txt") {
+		t.Errorf("Path = %q", got)
+	}
+	if !IsAppleDouble("a/._b.txt") || IsAppleDouble("a/b.txt") || IsAppleDouble("._") {
+		t.Error("IsAppleDouble is wrong")
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-appledouble-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "photo.jpg")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := &File{
+		FinderInfo: make([]byte, 32),
+		Attrs:      []Attr{{"com.apple.quarantine", []byte("0083;5f000000;Safari;")}},
+	}
+	if _, err := f.WriteTo(mustCreate(t, Path(path))); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := ImportTree(dir, true)
+	xattrtest.Check(t, err)
+	if n != 1 {
+		t.Errorf("imported %d files", n)
+	}
+	if _, err := os.Lstat(Path(path)); !os.IsNotExist(err) {
+		t.Error("AppleDouble file not removed")
+	}
+	name, _ := xattr.TranslateName("darwin", runtime.GOOS, "com.apple.quarantine")
+	names, err := walk.List(path)
+	if err != nil || !reflect.DeepEqual(names, []string{name}) {
+		t.Errorf("attributes after import: %q, %v", names, err)
+	}
+
+	if err := Export(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(Path(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Attrs, f.Attrs) {
+		t.Errorf("exported %+v, want %+v", got.Attrs, f.Attrs)
+	}
+
+	// A file without attributes gets no AppleDouble file.
+	plain := filepath.Join(dir, "plain.txt")
+	if err := ioutil.WriteFile(plain, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Export(plain); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(Path(plain)); !os.IsNotExist(err) {
+		t.Errorf("AppleDouble file for a file without attributes: %v", err)
+	}
+}
+
+func mustCreate(t *testing.T, path string) *os.File {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
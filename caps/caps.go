@@ -0,0 +1,154 @@
+/*
+Package caps decodes and encodes the file capabilities Linux stores in the
+security.capability attribute.
+
+Three revisions exist. Revision 1 only holds the lower 32 capabilities.
+Revision 2 holds 64. Revision 3 adds the root user ID of the user
+namespace the capabilities are valid in, and the kernel writes it when
+capabilities are set from inside a user namespace.
+*/
+package caps
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/pkg/xattr"
+)
+
+// Attr is the attribute name.
+const Attr = "security.capability"
+
+// Revisions of the attribute format.
+const (
+	Revision1 = 1
+	Revision2 = 2
+	Revision3 = 3
+)
+
+const (
+	revisionMask  = 0xff000000
+	revisionShift = 24
+	flagEffective = 0x000001
+
+	size1 = 4 + 8
+	size2 = 4 + 16
+	size3 = 4 + 16 + 4
+)
+
+// ErrFormat is returned for values that are not valid capability sets.
+var ErrFormat = errors.New("caps: invalid security.capability value")
+
+// Set is a decoded security.capability value. Capabilities are bit
+// numbers as in <linux/capability.h>, for example 1<<CAP_NET_BIND_SERVICE.
+type Set struct {
+	Revision int
+	// Effective makes the permitted capabilities effective at exec.
+	Effective   bool
+	Permitted   uint64
+	Inheritable uint64
+	// RootID is the host uid of the root user of the namespace the
+	// capabilities apply to. It is only stored in revision 3.
+	RootID uint32
+}
+
+// Common capability numbers.
+const (
+	CapChown          = 0
+	CapDacOverride    = 1
+	CapFowner         = 3
+	CapSetgid         = 6
+	CapSetuid         = 7
+	CapNetBindService = 10
+	CapNetRaw         = 13
+	CapSysAdmin       = 21
+	CapSetfcap        = 31
+)
+
+// Parse decodes an attribute value.
+func Parse(b []byte) (*Set, error) {
+	if len(b) < 4 {
+		return nil, ErrFormat
+	}
+	le := binary.LittleEndian
+	magic := le.Uint32(b)
+	s := &Set{
+		Revision:  int(magic&revisionMask) >> revisionShift,
+		Effective: magic&flagEffective != 0,
+	}
+	if magic&^(revisionMask|flagEffective) != 0 {
+		return nil, ErrFormat
+	}
+	switch {
+	case s.Revision == Revision1 && len(b) == size1:
+		s.Permitted = uint64(le.Uint32(b[4:]))
+		s.Inheritable = uint64(le.Uint32(b[8:]))
+	case s.Revision == Revision2 && len(b) == size2,
+		s.Revision == Revision3 && len(b) == size3:
+		s.Permitted = uint64(le.Uint32(b[4:])) | uint64(le.Uint32(b[12:]))<<32
+		s.Inheritable = uint64(le.Uint32(b[8:])) | uint64(le.Uint32(b[16:]))<<32
+		if s.Revision == Revision3 {
+			s.RootID = le.Uint32(b[20:])
+		}
+	default:
+		return nil, ErrFormat
+	}
+	return s, nil
+}
+
+// Encode returns the attribute value of s. Revision 1 can only encode the
+// lower 32 capabilities and fails with ErrFormat otherwise.
+func (s *Set) Encode() ([]byte, error) {
+	le := binary.LittleEndian
+	magic := uint32(s.Revision) << revisionShift
+	if s.Effective {
+		magic |= flagEffective
+	}
+	var b []byte
+	switch s.Revision {
+	case Revision1:
+		if (s.Permitted|s.Inheritable)>>32 != 0 {
+			return nil, ErrFormat
+		}
+		b = make([]byte, size1)
+	case Revision2:
+		b = make([]byte, size2)
+	case Revision3:
+		b = make([]byte, size3)
+		le.PutUint32(b[20:], s.RootID)
+	default:
+		return nil, ErrFormat
+	}
+	le.PutUint32(b, magic)
+	le.PutUint32(b[4:], uint32(s.Permitted))
+	le.PutUint32(b[8:], uint32(s.Inheritable))
+	if s.Revision != Revision1 {
+		le.PutUint32(b[12:], uint32(s.Permitted>>32))
+		le.PutUint32(b[16:], uint32(s.Inheritable>>32))
+	}
+	return b, nil
+}
+
+// Has reports whether capability c is permitted.
+func (s *Set) Has(c int) bool {
+	return s.Permitted&(1<<uint(c)) != 0
+}
+
+// Get reads the capabilities of path, without following a symlink at the
+// end of path.
+func Get(path string) (*Set, error) {
+	b, err := xattr.LGet(path, Attr)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Put writes the capabilities of path.
+func Put(path string, s *Set) error {
+	b, err := s.Encode()
+	if err != nil {
+		return err
+	}
+	return xattr.LSet(path, Attr, b)
+}
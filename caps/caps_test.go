@@ -0,0 +1,49 @@
+package caps
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	// cap_net_bind_service,cap_net_raw+ep as written by setcap.
+	v2 := []byte{
+		0x01, 0x00, 0x00, 0x02,
+		0x00, 0x24, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	s, err := Parse(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Revision != Revision2 || !s.Effective || !s.Has(CapNetBindService) || !s.Has(CapNetRaw) || s.Has(CapSysAdmin) {
+		t.Errorf("Parse = %+v", s)
+	}
+	if b, err := s.Encode(); err != nil || !bytes.Equal(b, v2) {
+		t.Errorf("Encode = % x, %v", b, err)
+	}
+
+	s = &Set{Revision: Revision3, Permitted: 1<<40 | 1, Inheritable: 2, RootID: 100000}
+	b, err := s.Encode()
+	if err != nil || len(b) != 24 {
+		t.Fatalf("Encode = % x, %v", b, err)
+	}
+	if got, err := Parse(b); err != nil || *got != *s {
+		t.Errorf("Parse = %+v, %v", got, err)
+	}
+
+	s.Revision = Revision1
+	if _, err := s.Encode(); err != ErrFormat {
+		t.Errorf("Encode of high capability in revision 1 = %v", err)
+	}
+	s.Permitted = 1
+	if b, err := s.Encode(); err != nil || len(b) != 12 {
+		t.Errorf("Encode of revision 1 = % x, %v", b, err)
+	}
+
+	for _, bad := range [][]byte{nil, v2[:19], append([]byte{0x02, 0, 0, 0x02}, v2[4:]...), append(v2, 0, 0, 0, 0)} {
+		if _, err := Parse(bad); err != ErrFormat {
+			t.Errorf("Parse(% x) = %v", bad, err)
+		}
+	}
+}
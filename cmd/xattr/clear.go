@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/xattr"
+)
+
+var (
+	clearNamespaces string
+	clearSkipDenied bool
+	clearRecursive  bool
+)
+
+var clearCmd = &command{
+	name:  "clear",
+	args:  "path...",
+	short: "remove all or selected attributes",
+	flags: func(fs *flag.FlagSet) {
+		fs.StringVar(&clearNamespaces, "ns", "", "only remove attributes in the comma-separated `namespaces`, such as user.")
+		fs.BoolVar(&clearSkipDenied, "skip-denied", false, "skip attributes that cannot be removed for lack of permission")
+		fs.BoolVar(&clearRecursive, "r", false, "remove attributes from directory trees")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() < 1 {
+			fs.Usage()
+			return exitError
+		}
+		filter := xattr.Filter{SkipDenied: clearSkipDenied}
+		if clearNamespaces != "" {
+			filter.Match = xattr.Namespace(strings.Split(clearNamespaces, ",")...).Match
+		}
+		status := exitOK
+		for _, path := range fs.Args() {
+			var err error
+			if clearRecursive {
+				err = xattr.RemoveAllTree(path, filter)
+			} else {
+				err = xattr.LRemoveAll(path, filter)
+			}
+			if list, ok := err.(xattr.ErrorList); ok {
+				for _, e := range list {
+					fmt.Fprintf(os.Stderr, "xattr clear: %v\n", e)
+				}
+				status = exitProblems
+			} else if err != nil {
+				return fail(fs, err)
+			}
+		}
+		return status
+	},
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/xattr/du"
+)
+
+var (
+	duDepth     int
+	duTop       int
+	duLimit     int
+	duThreshold float64
+)
+
+var duCmd = &command{
+	name:  "du",
+	args:  "dir",
+	short: "summarize the storage used by attributes",
+	flags: func(fs *flag.FlagSet) {
+		fs.IntVar(&duDepth, "d", 0, "print directory totals down to `depth` levels below dir")
+		fs.IntVar(&duTop, "top", du.DefaultTop, "list the `n` largest attributes")
+		fs.IntVar(&duLimit, "limit", du.DefaultLimit, "per-inode attribute space in `bytes`")
+		fs.Float64Var(&duThreshold, "threshold", du.DefaultThreshold, "report files above this `fraction` of the limit")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return exitError
+		}
+		var near []du.Entry
+		r, err := du.Scan(fs.Arg(0), du.Options{
+			Limit:     duLimit,
+			Threshold: duThreshold,
+			Top:       duTop,
+			Dir: func(e du.Entry) {
+				if depth(e.Path) <= duDepth {
+					fmt.Printf("%d\t%d\t%s\n", e.Bytes(), e.Attrs, e.Path)
+				}
+			},
+			Near: func(e du.Entry) { near = append(near, e) },
+		})
+		if r == nil {
+			return fail(fs, err)
+		}
+
+		fmt.Printf("\n%d files, %d attributes, %d name bytes, %d value bytes\n",
+			r.Files, r.Total.Attrs, r.Total.NameBytes, r.Total.ValueBytes)
+
+		var namespaces []string
+		for ns := range r.Namespaces {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		if len(namespaces) > 0 {
+			fmt.Printf("\nnamespaces:\n")
+		}
+		for _, ns := range namespaces {
+			u := r.Namespaces[ns]
+			if ns == "" {
+				ns = "(none)"
+			}
+			fmt.Printf("%d\t%d\t%s\n", u.Bytes(), u.Attrs, ns)
+		}
+
+		if len(near) > 0 {
+			fmt.Printf("\nnear the per-inode limit of %d bytes:\n", duLimit)
+			for _, e := range near {
+				fmt.Printf("%d\t%d\t%s\n", e.Footprint, e.Attrs, e.Path)
+			}
+		}
+
+		if len(r.Largest) > 0 {
+			fmt.Printf("\nlargest attributes:\n")
+			for _, a := range r.Largest {
+				fmt.Printf("%d\t%s\t%s\n", a.Size, a.Path, a.Name)
+			}
+		}
+		return walkStatus(fs, err, exitOK)
+	},
+}
+
+func depth(rel string) int {
+	if rel == "." {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/xattr/search"
+)
+
+var (
+	findValues bool
+	findNull   bool
+)
+
+var findCmd = &command{
+	name:  "find",
+	args:  "dir expression...",
+	short: "search a tree for files by attribute",
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&findValues, "v", false, "print the matched attributes and their values")
+		fs.BoolVar(&findNull, "0", false, "terminate paths with NUL instead of newline")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() < 2 {
+			fs.Usage()
+			return exitError
+		}
+		e, err := search.Parse(strings.Join(fs.Args()[1:], " "))
+		if err != nil {
+			return fail(fs, err)
+		}
+		err = search.Search(fs.Arg(0), e, func(m *search.Match) error {
+			if findNull {
+				fmt.Printf("%s\x00", m.Path)
+			} else {
+				fmt.Println(m.Path)
+			}
+			if findValues {
+				for _, a := range m.Attrs {
+					fmt.Printf("\t%s=%s\n", a.Name, quoteValue(a.Value))
+				}
+			}
+			return nil
+		})
+		return walkStatus(fs, err, exitOK)
+	},
+}
+
+// quoteValue returns value as is if it is printable text and as a quoted Go
+// string otherwise.
+func quoteValue(value []byte) string {
+	s := string(value)
+	if q := strconv.Quote(s); q[1:len(q)-1] != s {
+		return q
+	}
+	return s
+}
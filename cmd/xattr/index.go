@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/xattr/index"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+var indexMaxValue int
+
+var indexCmd = &command{
+	name:  "index",
+	args:  "index-file dir",
+	short: "build or refresh an attribute index of a tree",
+	flags: func(fs *flag.FlagSet) {
+		fs.IntVar(&indexMaxValue, "max-value", index.DefaultMaxValue, "store values larger than `bytes` as hashes; a refresh keeps the previous size unless set")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return exitError
+		}
+		file := fs.Arg(0)
+		dir, err := filepath.Abs(fs.Arg(1))
+		if err != nil {
+			return fail(fs, err)
+		}
+		idx, err := readIndex(file)
+		var walkErr error
+		switch {
+		case err == nil && idx.Root == dir:
+			fs.Visit(func(f *flag.Flag) {
+				if f.Name == "max-value" {
+					idx.SetMaxValue(indexMaxValue)
+				}
+			})
+			var st index.Stats
+			st, walkErr = idx.Refresh()
+			if _, ok := walkErr.(walk.Errors); walkErr != nil && !ok {
+				return fail(fs, walkErr)
+			}
+			fmt.Printf("%d files, %d updated, %d removed\n", st.Files, st.Updated, st.Removed)
+		case err == nil || os.IsNotExist(err):
+			if idx, walkErr = index.Build(dir, index.Options{MaxValue: indexMaxValue}); idx == nil {
+				return fail(fs, walkErr)
+			}
+			fmt.Printf("%d files\n", len(idx.Entries()))
+		default:
+			return fail(fs, err)
+		}
+		if err := writeIndex(file, idx); err != nil {
+			return fail(fs, err)
+		}
+		return walkStatus(fs, walkErr, exitOK)
+	},
+}
+
+var lookupCmd = &command{
+	name:  "lookup",
+	args:  "index-file name [value]",
+	short: "look up files by attribute in an index",
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() != 2 && fs.NArg() != 3 {
+			fs.Usage()
+			return exitError
+		}
+		idx, err := readIndex(fs.Arg(0))
+		if err != nil {
+			return fail(fs, err)
+		}
+		var hits []index.Hit
+		if fs.NArg() == 3 {
+			hits = idx.LookupValue(fs.Arg(1), []byte(fs.Arg(2)))
+		} else {
+			hits = idx.Lookup(fs.Arg(1))
+		}
+		for _, h := range hits {
+			fmt.Println(filepath.Join(idx.Root, filepath.FromSlash(h.Path)))
+		}
+		if len(hits) == 0 {
+			return exitProblems
+		}
+		return exitOK
+	},
+}
+
+func readIndex(file string) (*index.Index, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return index.Read(f)
+}
+
+// writeIndex replaces file atomically, so that an interrupted run leaves
+// the previous index intact.
+func writeIndex(file string, idx *index.Index) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := idx.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
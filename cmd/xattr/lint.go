@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/xattr/lint"
+)
+
+var (
+	lintFix        bool
+	lintMaxSize    int
+	lintDeprecated string
+	lintDisable    string
+)
+
+var lintCmd = &command{
+	name:  "lint",
+	args:  "dir",
+	short: "report questionable attribute names and values",
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&lintFix, "fix", false, "rename or remove offending attributes where a fix is known")
+		fs.IntVar(&lintMaxSize, "max-size", lint.DefaultMaxSize, "report attributes whose name and value exceed `bytes`")
+		fs.StringVar(&lintDeprecated, "deprecated", "", "comma-separated `old=new` names to report; an empty new name suggests removal")
+		fs.StringVar(&lintDisable, "disable", "", "comma-separated `checks` to skip")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return exitError
+		}
+		cfg := lint.Config{MaxSize: lintMaxSize, Deprecated: map[string]string{}}
+		if lintDisable != "" {
+			cfg.Disable = strings.Split(lintDisable, ",")
+		}
+		if lintDeprecated != "" {
+			for _, kv := range strings.Split(lintDeprecated, ",") {
+				i := strings.IndexByte(kv, '=')
+				if i < 0 {
+					return fail(fs, fmt.Errorf("invalid -deprecated entry %q", kv))
+				}
+				cfg.Deprecated[kv[:i]] = kv[i+1:]
+			}
+		}
+		status := exitOK
+		err := lint.New(cfg).Tree(fs.Arg(0), func(f lint.Finding) error {
+			fmt.Println(f)
+			status = exitProblems
+			if lintFix && f.Fix != nil {
+				if err := lint.Apply(f); err != nil {
+					fmt.Fprintf(os.Stderr, "xattr lint: %v\n", err)
+				}
+			}
+			return nil
+		})
+		return walkStatus(fs, err, status)
+	},
+}
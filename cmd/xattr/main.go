@@ -0,0 +1,129 @@
+/*
+Command xattr inspects, verifies and maintains the extended attributes of
+file trees.
+
+Usage:
+
+	xattr <command> [flags] [arguments]
+
+The commands are:
+
+	manifest  record the extended attributes of a tree
+	verify    compare a tree against a manifest
+	lint      report questionable attribute names and values
+	du        summarize the storage used by attributes
+	find      search a tree for files by attribute
+	index     build or refresh an attribute index of a tree
+	lookup    look up files by attribute in an index
+	migrate   rename attribute keys across a tree
+	clear     remove all or selected attributes
+	sidecar   move attributes from sidecar files to native storage
+	shatag    print cached content digests or check them for corruption
+	override  convert between real ownership and override_stat attributes
+
+Run "xattr <command> -h" for the flags of a command. The exit status is 0 on
+success, 1 if a command found problems and 2 on errors.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// Exit statuses shared by all commands.
+const (
+	exitOK       = 0
+	exitProblems = 1
+	exitError    = 2
+)
+
+// command is a subcommand of xattr.
+type command struct {
+	name  string
+	args  string
+	short string
+	// run executes the command with the flags in fs already parsed and
+	// returns the exit status.
+	run   func(fs *flag.FlagSet) int
+	flags func(fs *flag.FlagSet)
+}
+
+var commands = []*command{
+	manifestCmd,
+	verifyCmd,
+	lintCmd,
+	duCmd,
+	findCmd,
+	indexCmd,
+	lookupCmd,
+	migrateCmd,
+	clearCmd,
+	sidecarCmd,
+	shatagCmd,
+	overrideCmd,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		usage()
+		return exitError
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "usage: xattr %s [flags] %s\n\n%s.\n\n", cmd.name, cmd.args, cmd.short)
+			fs.PrintDefaults()
+		}
+		if cmd.flags != nil {
+			cmd.flags(fs)
+		}
+		if err := fs.Parse(args[1:]); err != nil {
+			return exitError
+		}
+		return cmd.run(fs)
+	}
+	fmt.Fprintf(os.Stderr, "xattr: unknown command %q\n", args[0])
+	usage()
+	return exitError
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: xattr <command> [flags] [arguments]\n\nThe commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-10s%s\n", cmd.name, cmd.short)
+	}
+}
+
+// fail prints err prefixed by the command name and returns exitError.
+func fail(fs *flag.FlagSet, err error) int {
+	fmt.Fprintf(os.Stderr, "xattr %s: %v\n", fs.Name(), err)
+	return exitError
+}
+
+// walkStatus prints the errors for the individual files that a tree walk
+// went on past and returns exitProblems, or status if err is nil. Any other
+// error is passed to fail.
+func walkStatus(fs *flag.FlagSet, err error, status int) int {
+	if err == nil {
+		return status
+	}
+	errs, ok := err.(walk.Errors)
+	if !ok {
+		return fail(fs, err)
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "xattr %s: %v\n", fs.Name(), err)
+	}
+	return exitProblems
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/xattr/manifest"
+)
+
+var (
+	manifestDigest bool
+	manifestOutput string
+	manifestPrefix string
+)
+
+var manifestCmd = &command{
+	name:  "manifest",
+	args:  "dir",
+	short: "record the extended attributes of a tree",
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&manifestDigest, "digest", false, "record SHA-256 digests instead of values")
+		fs.StringVar(&manifestOutput, "o", "", "write the manifest to `file` instead of stdout")
+		fs.StringVar(&manifestPrefix, "prefix", "", "only record attributes with one of the comma-separated `prefixes`")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return exitError
+		}
+		opts := manifest.Options{Digest: manifestDigest}
+		if manifestPrefix != "" {
+			opts.Prefixes = strings.Split(manifestPrefix, ",")
+		}
+		m, err := manifest.Generate(fs.Arg(0), opts)
+		if m == nil {
+			return fail(fs, err)
+		}
+		walkErr := err
+		out := os.Stdout
+		if manifestOutput != "" {
+			if out, err = os.Create(manifestOutput); err != nil {
+				return fail(fs, err)
+			}
+		}
+		_, err = m.WriteTo(out)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fail(fs, err)
+		}
+		return walkStatus(fs, walkErr, exitOK)
+	},
+}
+
+var verifyCmd = &command{
+	name:  "verify",
+	args:  "manifest dir",
+	short: "compare a tree against a manifest",
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return exitError
+		}
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fail(fs, err)
+		}
+		m, err := manifest.Read(f)
+		f.Close()
+		if err != nil {
+			return fail(fs, err)
+		}
+		changes, err := manifest.Verify(fs.Arg(1), m)
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		status := exitOK
+		if len(changes) > 0 {
+			status = exitProblems
+		}
+		return walkStatus(fs, err, status)
+	},
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/xattr/migrate"
+)
+
+var (
+	migrateTable   string
+	migrateJournal string
+	migrateReplace bool
+)
+
+var migrateCmd = &command{
+	name:  "migrate",
+	args:  "dir [old=new...]",
+	short: "rename attribute keys across a tree",
+	flags: func(fs *flag.FlagSet) {
+		fs.StringVar(&migrateTable, "table", "", "read \"old new\" pairs from `file`")
+		fs.StringVar(&migrateJournal, "journal", "", "record progress in `file` and resume from it")
+		fs.BoolVar(&migrateReplace, "replace", false, "overwrite existing attributes with the new names")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() < 1 {
+			fs.Usage()
+			return exitError
+		}
+		m := migrate.Mapping{}
+		if migrateTable != "" {
+			f, err := os.Open(migrateTable)
+			if err != nil {
+				return fail(fs, err)
+			}
+			m, err = migrate.ParseMapping(f)
+			f.Close()
+			if err != nil {
+				return fail(fs, err)
+			}
+		}
+		for _, kv := range fs.Args()[1:] {
+			i := strings.IndexByte(kv, '=')
+			if i < 0 {
+				return fail(fs, fmt.Errorf("invalid mapping %q", kv))
+			}
+			m[kv[:i]] = kv[i+1:]
+		}
+		if len(m) == 0 {
+			return fail(fs, fmt.Errorf("no mapping given"))
+		}
+		st, err := migrate.Tree(fs.Arg(0), m, migrate.Options{
+			Replace: migrateReplace,
+			Journal: migrateJournal,
+		})
+		fmt.Printf("%d files, %d skipped, %d attributes renamed\n", st.Files, st.Skipped, st.Renamed)
+		if err != nil {
+			return fail(fs, err)
+		}
+		return exitOK
+	},
+}
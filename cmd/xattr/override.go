@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/pkg/xattr/overridestat"
+)
+
+var (
+	overrideApply bool
+	overrideChown string
+)
+
+var overrideCmd = &command{
+	name:  "override",
+	args:  "dir",
+	short: "convert between real ownership and override_stat attributes",
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&overrideApply, "apply", false, "apply recorded overrides as real ownership instead of recording them")
+		fs.StringVar(&overrideChown, "chown", "", "after recording, change the owner of every file to `uid:gid`")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return exitError
+		}
+		if overrideApply {
+			n, err := overridestat.ApplyTree(fs.Arg(0))
+			if err != nil {
+				return fail(fs, err)
+			}
+			fmt.Printf("applied %d overrides\n", n)
+			return exitOK
+		}
+		var opts overridestat.Options
+		if overrideChown != "" {
+			if _, err := fmt.Sscanf(overrideChown, "%d:%d", &opts.UID, &opts.GID); err != nil {
+				return fail(fs, fmt.Errorf("invalid -chown %q", overrideChown))
+			}
+			opts.Chown = true
+		}
+		n, err := overridestat.RecordTree(fs.Arg(0), opts)
+		if err != nil {
+			return fail(fs, err)
+		}
+		fmt.Printf("recorded %d overrides\n", n)
+		return exitOK
+	},
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pkg/xattr/internal/walk"
+	"github.com/pkg/xattr/shatag"
+)
+
+var (
+	shatagCheck bool
+	shatagAlg   string
+)
+
+var shatagCmd = &command{
+	name:  "shatag",
+	args:  "path...",
+	short: "print cached content digests or check them for corruption",
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&shatagCheck, "check", false, "rehash every file and report corrupt ones")
+		fs.StringVar(&shatagAlg, "alg", "sha256", "digest `algorithm`: sha256, sha512, sha1 or md5")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() < 1 {
+			fs.Usage()
+			return exitError
+		}
+		alg, ok := shatag.Lookup(shatagAlg)
+		if !ok {
+			return fail(fs, fmt.Errorf("unknown algorithm %q", shatagAlg))
+		}
+		status := exitOK
+		for _, root := range fs.Args() {
+			err := walk.Walk(root, func(path, _ string, info os.FileInfo) error {
+				if !info.Mode().IsRegular() {
+					return nil
+				}
+				if !shatagCheck {
+					// Digest returns the digest even if storing it failed.
+					sum, err := alg.Digest(path)
+					if sum != "" {
+						fmt.Printf("%s  %s\n", sum, path)
+					}
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "xattr shatag: %v\n", err)
+						status = exitProblems
+					}
+					return nil
+				}
+				r, err := alg.Verify(path)
+				switch {
+				case err != nil:
+					fmt.Fprintf(os.Stderr, "xattr shatag: %v\n", err)
+					status = exitProblems
+				case r.Status == shatag.Corrupt:
+					fmt.Printf("corrupt  %s  cached %s, actual %s\n", path, r.Cached, r.Actual)
+					status = exitProblems
+				case r.Status != shatag.OK:
+					fmt.Printf("%-8s %s\n", r.Status, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return fail(fs, err)
+			}
+		}
+		return status
+	},
+}
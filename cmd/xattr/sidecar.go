@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pkg/xattr/sidecar"
+)
+
+var sidecarClean bool
+
+var sidecarCmd = &command{
+	name:  "sidecar",
+	args:  "dir...",
+	short: "move attributes from sidecar files to native storage",
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&sidecarClean, "clean", false, "remove orphaned sidecar files first")
+	},
+	run: func(fs *flag.FlagSet) int {
+		if fs.NArg() < 1 {
+			fs.Usage()
+			return exitError
+		}
+		status := exitOK
+		store := sidecar.New()
+		for _, dir := range fs.Args() {
+			if sidecarClean {
+				st, err := sidecar.Clean(dir)
+				if err != nil {
+					return fail(fs, err)
+				}
+				if st.Removed > 0 {
+					fmt.Printf("%s: removed %d orphaned sidecar files\n", dir, st.Removed)
+				}
+				if st.Skipped > 0 {
+					fmt.Printf("%s: kept %d orphaned files that are not sidecar files\n", dir, st.Skipped)
+				}
+			}
+			st, err := store.Migrate(dir)
+			fmt.Printf("%s: moved %d attributes of %d files\n", dir, st.Attrs, st.Files)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "xattr sidecar: %d files kept their sidecar file: %v\n", st.Failed, err)
+				status = exitProblems
+			}
+		}
+		return status
+	},
+}
@@ -0,0 +1,245 @@
+/*
+Package du accounts for the storage used by extended attributes in a file
+tree, similar to du(1) for file contents.
+
+Scan sums the name and value bytes of all attributes per file, per
+directory and per namespace, reports files whose attributes come close to
+the per-inode limit of the filesystem and keeps track of the largest
+attributes. Directories and files near the limit are streamed to callbacks
+while walking, so memory use does not grow with the size of the tree.
+*/
+package du
+
+import (
+	"container/heap"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+const (
+	// DefaultLimit is the default per-inode limit in bytes. ext4 stores the
+	// attributes that do not fit into the inode in a single block of
+	// typically 4 KB; exceeding it fails with ENOSPC.
+	DefaultLimit = 4096
+
+	// DefaultThreshold is the default fraction of the limit above which a
+	// file is reported as near the limit.
+	DefaultThreshold = 0.75
+
+	// DefaultTop is the default number of largest attributes to keep.
+	DefaultTop = 10
+
+	// entryOverhead approximates the size of the ext4 entry header that
+	// precedes every attribute.
+	entryOverhead = 16
+)
+
+// Usage is the storage used by a set of attributes.
+type Usage struct {
+	Attrs      int64
+	NameBytes  int64
+	ValueBytes int64
+	// Footprint estimates the space taken on disk, using the ext4 layout of
+	// a 16 byte entry header plus name and value padded to 4 bytes.
+	Footprint int64
+}
+
+// Bytes returns the sum of name and value bytes.
+func (u Usage) Bytes() int64 { return u.NameBytes + u.ValueBytes }
+
+func (u *Usage) add(v Usage) {
+	u.Attrs += v.Attrs
+	u.NameBytes += v.NameBytes
+	u.ValueBytes += v.ValueBytes
+	u.Footprint += v.Footprint
+}
+
+func attrUsage(name string, size int) Usage {
+	return Usage{
+		Attrs:      1,
+		NameBytes:  int64(len(name)),
+		ValueBytes: int64(size),
+		Footprint:  int64(entryOverhead + pad4(len(name)) + pad4(size)),
+	}
+}
+
+func pad4(n int) int { return (n + 3) &^ 3 }
+
+// Entry is the usage of a single file or of a directory including
+// everything below it. Path is relative to the root of the scan.
+type Entry struct {
+	Path string
+	Usage
+}
+
+// Attr is a single attribute and the size of its value.
+type Attr struct {
+	Path string
+	Name string
+	Size int
+}
+
+// Options control a scan. The zero value uses the defaults.
+type Options struct {
+	// Limit is the per-inode limit in bytes, DefaultLimit if zero.
+	Limit int
+	// Threshold is the fraction of Limit above which a file's footprint is
+	// reported to Near, DefaultThreshold if zero.
+	Threshold float64
+	// Top is the number of largest attributes to report, DefaultTop if
+	// zero. It must not be negative.
+	Top int
+	// Dir, if set, is called for every directory after everything below it
+	// has been scanned.
+	Dir func(Entry)
+	// Near, if set, is called for every file near the per-inode limit.
+	Near func(Entry)
+}
+
+// Report summarizes a scan.
+type Report struct {
+	Total Usage
+	// Files is the number of files and directories scanned.
+	Files int64
+	// Namespaces maps namespace prefixes such as "user." to their usage.
+	// Names without a namespace are counted under "".
+	Namespaces map[string]Usage
+	// Largest lists the largest attributes by value size, largest first.
+	Largest []Attr
+}
+
+// ErrNegativeTop is returned by Scan if Options.Top is negative.
+var ErrNegativeTop = errors.New("du: negative number of largest attributes")
+
+// Scan walks the tree rooted at root without following symlinks and
+// accounts for the extended attributes found. Files that cannot be read are
+// left out: Scan goes on and returns the report of the rest together with
+// an error listing every failure.
+func Scan(root string, opts Options) (*Report, error) {
+	if opts.Top < 0 {
+		return nil, ErrNegativeTop
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultLimit
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	if opts.Top == 0 {
+		opts.Top = DefaultTop
+	}
+	near := int64(float64(opts.Limit) * opts.Threshold)
+
+	r := &Report{Namespaces: map[string]Usage{}}
+	var largest attrHeap
+	var stack []Entry
+	pop := func() {
+		dir := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if len(stack) > 0 {
+			stack[len(stack)-1].add(dir.Usage)
+		}
+		if opts.Dir != nil {
+			opts.Dir(dir)
+		}
+	}
+
+	var errs walk.Errors
+	err := walk.All(root, &errs, func(path, rel string, info os.FileInfo) error {
+		for len(stack) > 0 && !within(rel, stack[len(stack)-1].Path) {
+			pop()
+		}
+		// On error, file holds the attributes read before the failure,
+		// which are already counted in the namespaces.
+		file, err := scanFile(path, rel, r, &largest, opts.Top)
+		if err != nil {
+			errs.Add(err)
+		}
+		r.Files++
+		r.Total.add(file.Usage)
+		if file.Footprint > near && opts.Near != nil {
+			opts.Near(file)
+		}
+		if info.IsDir() {
+			stack = append(stack, file)
+		} else if len(stack) > 0 {
+			stack[len(stack)-1].add(file.Usage)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for len(stack) > 0 {
+		pop()
+	}
+	r.Largest = make([]Attr, len(largest))
+	copy(r.Largest, largest)
+	sort.Slice(r.Largest, func(i, j int) bool { return r.Largest[i].Size > r.Largest[j].Size })
+	return r, errs.Err()
+}
+
+// within reports whether rel is dir or below it.
+func within(rel, dir string) bool {
+	return dir == "." || rel == dir || strings.HasPrefix(rel, dir+"/")
+}
+
+func scanFile(path, rel string, r *Report, largest *attrHeap, top int) (Entry, error) {
+	e := Entry{Path: rel}
+	names, err := walk.List(path)
+	if err != nil {
+		return e, err
+	}
+	for _, name := range names {
+		value, err := xattr.LGet(path, name)
+		if err != nil {
+			if walk.Vanished(err) {
+				continue
+			}
+			return e, err
+		}
+		u := attrUsage(name, len(value))
+		e.add(u)
+		ns := Namespace(name)
+		nu := r.Namespaces[ns]
+		nu.add(u)
+		r.Namespaces[ns] = nu
+
+		a := Attr{rel, name, len(value)}
+		if largest.Len() < top {
+			heap.Push(largest, a)
+		} else if a.Size > (*largest)[0].Size {
+			(*largest)[0] = a
+			heap.Fix(largest, 0)
+		}
+	}
+	return e, nil
+}
+
+// Namespace returns the namespace prefix of name including the dot, such as
+// "user.", or "" if name has no namespace.
+func Namespace(name string) string {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[:i+1]
+	}
+	return ""
+}
+
+// attrHeap is a min-heap of attributes by size.
+type attrHeap []Attr
+
+func (h attrHeap) Len() int            { return len(h) }
+func (h attrHeap) Less(i, j int) bool  { return h[i].Size < h[j].Size }
+func (h attrHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *attrHeap) Push(x interface{}) { *h = append(*h, x.(Attr)) }
+func (h *attrHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,107 @@
+package du
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/xattr/internal/walk"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-du-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a/x", "a/y", "b/z", "c"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	xattrtest.Set(t, filepath.Join(dir, "a/x"), "user.x", strings.Repeat("v", 10))
+	xattrtest.Set(t, filepath.Join(dir, "a/y"), "user.y", strings.Repeat("v", 3100))
+	xattrtest.Set(t, filepath.Join(dir, "b"), "user.b", strings.Repeat("v", 5))
+	xattrtest.Set(t, filepath.Join(dir, "c"), "user.c", strings.Repeat("v", 1))
+
+	dirs := map[string]int64{}
+	var near []string
+	r, err := Scan(dir, Options{
+		Top:  2,
+		Dir:  func(e Entry) { dirs[e.Path] = e.ValueBytes },
+		Near: func(e Entry) { near = append(near, e.Path) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tmpfs or ext4 test directory may carry security attributes, so
+	// only look at the user namespace.
+	if u := r.Namespaces["user."]; u.Attrs != 4 || u.ValueBytes != 3116 || u.NameBytes != 24 {
+		t.Errorf("wrong user namespace usage: %+v", u)
+	}
+	if r.Files != 7 {
+		t.Errorf("scanned %d files, want 7", r.Files)
+	}
+	want := map[string]int64{".": 3116, "a": 3110, "b": 5}
+	for k, v := range want {
+		if dirs[k] != v {
+			t.Errorf("directory %q: have %d value bytes, want %d", k, dirs[k], v)
+		}
+	}
+	if !reflect.DeepEqual(near, []string{"a/y"}) {
+		t.Errorf("near limit: have %v, want [a/y]", near)
+	}
+	if len(r.Largest) != 2 || r.Largest[0].Name != "user.y" || r.Largest[1].Name != "user.x" {
+		t.Errorf("wrong largest attributes: %+v", r.Largest)
+	}
+}
+
+func TestFootprint(t *testing.T) {
+	u := attrUsage("user.abc", 5)
+	if u.Footprint != 16+8+8 {
+		t.Errorf("footprint is %d, want 32", u.Footprint)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	if _, err := Scan(".", Options{Top: -1}); err != ErrNegativeTop {
+		t.Errorf("Top -1: err = %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "xattr-du-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a/x", "b", "c"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Removing c once a is done makes the walk fail on it, after b.
+	r, err := Scan(dir, Options{Dir: func(e Entry) {
+		if e.Path == "a" {
+			os.Remove(filepath.Join(dir, "c"))
+		}
+	}})
+	if errs, ok := err.(walk.Errors); !ok || len(errs) != 1 || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("err = %v", err)
+	}
+	if r == nil || r.Files != 4 {
+		t.Errorf("report = %+v, want 4 files", r)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !plan9
+// +build !plan9
+
+package xattr
+
+import "syscall"
+
+// Error numbers used by the code shared by all platforms. They are defined
+// here because the syscall package of plan9 lacks them.
+const (
+	errRange        = syscall.ERANGE
+	errTooBig       = syscall.E2BIG
+	errOverflow     = syscall.EOVERFLOW
+	errNotSupported = syscall.ENOTSUP
+	errOpNotSupp    = syscall.EOPNOTSUPP
+)
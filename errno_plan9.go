@@ -0,0 +1,13 @@
+package xattr
+
+import "syscall"
+
+// Error numbers used by the code shared by all platforms. plan9 has no
+// error numbers, so the Linux values stand in for them, as for ENOATTR.
+const (
+	errRange        = syscall.Errno(0x22)
+	errTooBig       = syscall.Errno(0x7)
+	errOverflow     = syscall.Errno(0x4b)
+	errNotSupported = syscall.Errno(0x5f)
+	errOpNotSupp    = errNotSupported
+)
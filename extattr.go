@@ -0,0 +1,51 @@
+package xattr
+
+import "strings"
+
+// FreeBSD and NetBSD select the namespace of an attribute with a separate
+// argument to the extattr syscalls. The helpers below map the "user." and
+// "system." prefixes used by this package to those namespaces and back.
+// They are free of syscalls so that they can be tested on every platform.
+
+const (
+	extattrNamespaceUser   = 1
+	extattrNamespaceSystem = 2
+)
+
+var extattrPrefixes = [...]string{
+	extattrNamespaceUser:   "user.",
+	extattrNamespaceSystem: "system.",
+}
+
+// splitExtattrName returns the namespace and the name within it for an
+// attribute name. Names without a known prefix are in the user namespace,
+// as they were before prefixes were supported.
+func splitExtattrName(name string) (namespace int, attrname string) {
+	for ns, prefix := range extattrPrefixes {
+		if prefix != "" && strings.HasPrefix(name, prefix) {
+			return ns, name[len(prefix):]
+		}
+	}
+	return extattrNamespaceUser, name
+}
+
+// appendExtattrList converts a list returned by extattr_list_file(2) for
+// the given namespace to the prefixed, NULL-terminated form used on Linux
+// and appends it to dst. Each entry of buf consists of a single byte
+// containing the length of the name, followed by the name, which is not
+// terminated by NULL. A truncated last entry is ignored.
+func appendExtattrList(dst []byte, namespace int, buf []byte) []byte {
+	prefix := extattrPrefixes[namespace]
+	index := 0
+	for index < len(buf) {
+		next := index + 1 + int(buf[index])
+		if next > len(buf) {
+			break
+		}
+		dst = append(dst, prefix...)
+		dst = append(dst, buf[index+1:next]...)
+		dst = append(dst, 0)
+		index = next
+	}
+	return dst
+}
@@ -0,0 +1,32 @@
+package xattr
+
+import "testing"
+
+func TestSplitExtattrName(t *testing.T) {
+	tests := []struct {
+		name     string
+		ns       int
+		attrname string
+	}{
+		{"user.foo", extattrNamespaceUser, "foo"},
+		{"system.posix1e.acl_access", extattrNamespaceSystem, "posix1e.acl_access"},
+		{"foo", extattrNamespaceUser, "foo"},
+		{"trusted.foo", extattrNamespaceUser, "trusted.foo"},
+		{"user.", extattrNamespaceUser, ""},
+	}
+	for _, tt := range tests {
+		ns, attrname := splitExtattrName(tt.name)
+		if ns != tt.ns || attrname != tt.attrname {
+			t.Errorf("splitExtattrName(%q) = %d, %q; want %d, %q", tt.name, ns, attrname, tt.ns, tt.attrname)
+		}
+	}
+}
+
+func TestAppendExtattrList(t *testing.T) {
+	buf := appendExtattrList(nil, extattrNamespaceUser, []byte("\x03foo\x06barbaz"))
+	buf = appendExtattrList(buf, extattrNamespaceSystem, []byte("\x01x\x06trunc"))
+	want := "user.foo\x00user.barbaz\x00system.x\x00"
+	if string(buf) != want {
+		t.Errorf("have %q, want %q", buf, want)
+	}
+}
@@ -0,0 +1,71 @@
+package xattr
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Fallback stores extended attributes where the platform or the filesystem
+// does not support them, for example in sidecar files. It is consulted
+// whenever a native call fails with ENOTSUP; on platforms without extended
+// attribute support, that is every call. Names the platform rejects
+// regardless of the filesystem, such as names without a namespace on
+// Linux, fail as usual and never reach the Fallback.
+//
+// The follow argument is false for the "L" variants, which must not follow
+// a symlink at the end of the path. The "F" variants pass the name of the
+// file and follow set to true. Methods should report errors like the
+// native calls do, in particular ENOATTR for a missing attribute and
+// EEXIST and ENOATTR for the XATTR_CREATE and XATTR_REPLACE flags. The
+// errors are wrapped in *Error before they are returned to the caller.
+type Fallback interface {
+	Get(path, name string, follow bool) ([]byte, error)
+	Set(path, name string, data []byte, flags int, follow bool) error
+	Remove(path, name string, follow bool) error
+	List(path string, follow bool) ([]string, error)
+}
+
+// fallbackHolder wraps the registered Fallback, as atomic.Value cannot
+// store nil or values of differing concrete types.
+type fallbackHolder struct{ fb Fallback }
+
+var fallback atomic.Value
+
+// RegisterFallback makes fb handle all calls that the platform or the
+// filesystem does not support. Passing nil unregisters the fallback.
+func RegisterFallback(fb Fallback) {
+	fallback.Store(fallbackHolder{fb})
+}
+
+// fallbackFor returns the registered Fallback if err, as returned by a
+// native call for name, means that extended attributes are not supported.
+// Otherwise it returns nil. name is empty for List.
+func fallbackFor(err error, name string) Fallback {
+	if e, ok := err.(*Error); ok {
+		err = e.Err
+	}
+	if err == nil || (err != ENOTSUP && err != errOpNotSupp) {
+		return nil
+	}
+	if name != "" && !knownNamespace(runtime.GOOS, name) {
+		return nil
+	}
+	return registeredFallback()
+}
+
+func registeredFallback() Fallback {
+	h, _ := fallback.Load().(fallbackHolder)
+	return h.fb
+}
+
+// fallbackError wraps an error returned by a Fallback like the errors of
+// the native calls.
+func fallbackError(myname, path, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		err = e.Err
+	}
+	return &Error{myname, path, name, err}
+}
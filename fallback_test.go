@@ -0,0 +1,128 @@
+//go:build linux
+// +build linux
+
+package xattr
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"syscall"
+	"testing"
+)
+
+// memFallback is a Fallback that keeps attributes in memory.
+type memFallback map[string]map[string][]byte
+
+func (m memFallback) Get(path, name string, follow bool) ([]byte, error) {
+	v, ok := m[path][name]
+	if !ok {
+		return nil, ENOATTR
+	}
+	return v, nil
+}
+
+func (m memFallback) Set(path, name string, data []byte, flags int, follow bool) error {
+	_, exists := m[path][name]
+	if flags&XATTR_CREATE != 0 && exists {
+		return syscall.EEXIST
+	}
+	if flags&XATTR_REPLACE != 0 && !exists {
+		return ENOATTR
+	}
+	if m[path] == nil {
+		m[path] = map[string][]byte{}
+	}
+	m[path][name] = data
+	return nil
+}
+
+func (m memFallback) Remove(path, name string, follow bool) error {
+	if _, ok := m[path][name]; !ok {
+		return ENOATTR
+	}
+	delete(m[path], name)
+	return nil
+}
+
+func (m memFallback) List(path string, follow bool) ([]string, error) {
+	names := []string{}
+	for name := range m[path] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func TestFallback(t *testing.T) {
+	// procfs has no extended attribute support but lets a process write
+	// its own files.
+	path := "/proc/self/comm"
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+	if err := Set(path, "user.test", []byte("x")); unpackSysErr(err) != ENOTSUP {
+		t.Skipf("want ENOTSUP on %s without a fallback, have %v", path, err)
+	}
+
+	mem := memFallback{}
+	RegisterFallback(mem)
+	defer RegisterFallback(nil)
+
+	err = Set(path, "user.test", []byte("x"))
+	checkIfError(t, err)
+	err = FSetWithFlags(f, "user.test", []byte("y"), XATTR_CREATE)
+	if unpackSysErr(err) != syscall.EEXIST {
+		t.Errorf("want EEXIST from the fallback, have %v", err)
+	}
+	if e, ok := err.(*Error); !ok || e.Op != "xattr.FSetWithFlags" || e.Name != "user.test" {
+		t.Errorf("fallback error not wrapped like native errors: %#v", err)
+	}
+	data, err := LGet(path, "user.test")
+	checkIfError(t, err)
+	if string(data) != "x" {
+		t.Errorf("wrong value from fallback: %q", data)
+	}
+
+	err = Rename(path, "user.test", "user.other", 0)
+	checkIfError(t, err)
+	// procfs lists attributes natively, so List does not reach the
+	// fallback.
+	names, err := List(path)
+	checkIfError(t, err)
+	if len(names) != 0 {
+		t.Errorf("List returned %q", names)
+	}
+	if names, _ := mem.List(path, true); len(names) != 1 || names[0] != "user.other" {
+		t.Errorf("fallback holds %q after Rename", names)
+	}
+
+	err = Remove(path, "user.other")
+	checkIfError(t, err)
+	if _, err := Get(path, "user.other"); unpackSysErr(err) != ENOATTR {
+		t.Errorf("want ENOATTR after Remove, have %v", err)
+	}
+}
+
+// An invalid name fails on a filesystem with extended attribute support,
+// fallback or not.
+func TestFallbackInvalidName(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	mem := memFallback{}
+	RegisterFallback(mem)
+	defer RegisterFallback(nil)
+	if err := Set(tmp.Name(), "nonamespace", []byte("x")); unpackSysErr(err) != ENOTSUP {
+		t.Errorf("want ENOTSUP for a name without a namespace, have %v", err)
+	}
+	if len(mem) != 0 {
+		t.Errorf("invalid name reached the fallback: %v", mem)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Package idmap shifts the user and group IDs stored in extended attributes
+when files move between user namespaces: the root ID of revision 3 file
+capabilities and the named entries of POSIX ACLs. Optionally, it shifts the
+ownership of the files as well.
+
+Mappings have the shape of /proc/<pid>/uid_map: each line maps a range of
+IDs inside the namespace to a range outside of it. Shifting maps IDs from
+inside to outside; use Map.Invert for the other direction.
+
+	uids, _ := idmap.ParseMap(strings.NewReader("0 100000 65536"))
+	gids := uids
+	err := idmap.ShiftTree("/var/lib/images/layer", uids, gids, idmap.Options{Chown: true})
+*/
+package idmap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/caps"
+	"github.com/pkg/xattr/internal/walk"
+	"github.com/pkg/xattr/posixacl"
+)
+
+// ErrUnmapped is returned for IDs that the mapping does not cover.
+var ErrUnmapped = errors.New("idmap: ID is not mapped")
+
+// Range maps Count IDs starting at Inside to the IDs starting at Outside.
+type Range struct {
+	Inside  uint32
+	Outside uint32
+	Count   uint32
+}
+
+// Map is a list of ranges, like the content of /proc/<pid>/uid_map.
+type Map []Range
+
+// ParseMap reads a mapping in the uid_map format: one "inside outside
+// count" triple per line.
+func ParseMap(r io.Reader) (Map, error) {
+	var m Map
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("idmap: line %d: want 3 fields, have %d", line, len(fields))
+		}
+		var nums [3]uint32
+		for i, f := range fields {
+			n, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("idmap: line %d: %v", line, err)
+			}
+			nums[i] = uint32(n)
+		}
+		r := Range{nums[0], nums[1], nums[2]}
+		if r.Count == 0 || uint64(r.Inside)+uint64(r.Count) > 1<<32 || uint64(r.Outside)+uint64(r.Count) > 1<<32 {
+			return nil, fmt.Errorf("idmap: line %d: invalid range", line)
+		}
+		m = append(m, r)
+	}
+	return m, s.Err()
+}
+
+// Map returns the outside ID of the inside ID id.
+func (m Map) Map(id uint32) (uint32, bool) {
+	for _, r := range m {
+		if id >= r.Inside && uint64(id) < uint64(r.Inside)+uint64(r.Count) {
+			return r.Outside + (id - r.Inside), true
+		}
+	}
+	return 0, false
+}
+
+// Invert returns the mapping from outside to inside.
+func (m Map) Invert() Map {
+	inv := make(Map, len(m))
+	for i, r := range m {
+		inv[i] = Range{Inside: r.Outside, Outside: r.Inside, Count: r.Count}
+	}
+	return inv
+}
+
+func (m Map) mustMap(kind string, id uint32) (uint32, error) {
+	mapped, ok := m.Map(id)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s %d", ErrUnmapped, kind, id)
+	}
+	return mapped, nil
+}
+
+// ShiftCaps returns s with its root ID shifted by uids. Revision 1 and 2
+// sets, which are valid for root of the initial namespace, have the root
+// ID 0 and become revision 3 sets unless root maps to 0 again. A revision
+// 3 set whose root ID maps to 0 becomes a revision 2 set, which older
+// kernels and tools understand.
+func ShiftCaps(s *caps.Set, uids Map) (*caps.Set, error) {
+	root := uint32(0)
+	if s.Revision == caps.Revision3 {
+		root = s.RootID
+	}
+	mapped, err := uids.mustMap("uid", root)
+	if err != nil {
+		return nil, err
+	}
+	shifted := *s
+	if mapped == 0 {
+		shifted.RootID = 0
+		if shifted.Revision == caps.Revision3 {
+			shifted.Revision = caps.Revision2
+		}
+		return &shifted, nil
+	}
+	shifted.Revision = caps.Revision3
+	shifted.RootID = mapped
+	return &shifted, nil
+}
+
+// ShiftACL returns acl with the IDs of its named entries shifted.
+func ShiftACL(acl posixacl.ACL, uids, gids Map) (posixacl.ACL, error) {
+	shifted := make(posixacl.ACL, len(acl))
+	for i, e := range acl {
+		var err error
+		switch e.Tag {
+		case posixacl.User:
+			e.ID, err = uids.mustMap("uid", e.ID)
+		case posixacl.Group:
+			e.ID, err = gids.mustMap("gid", e.ID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		shifted[i] = e
+	}
+	return shifted, nil
+}
+
+// Options control Shift and ShiftTree.
+type Options struct {
+	// Chown shifts the owner and group of the files as well.
+	Chown bool
+}
+
+// Shift rewrites the security.capability and POSIX ACL attributes of path,
+// and its ownership if requested, without following a symlink at the end
+// of path. Nothing is changed if an ID is not mapped.
+func Shift(path string, uids, gids Map, opts Options) error {
+	capValue, err := shiftedCaps(path, uids)
+	if err != nil {
+		return err
+	}
+	acls := map[string][]byte{}
+	for _, name := range []string{posixacl.AccessAttr, posixacl.DefaultAttr} {
+		value, err := shiftedACL(path, name, uids, gids)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			acls[name] = value
+		}
+	}
+	if opts.Chown {
+		// chown clears capabilities, so it has to come first.
+		if err := shiftOwner(path, uids, gids); err != nil {
+			return err
+		}
+	}
+	for name, value := range acls {
+		if err := xattr.LSet(path, name, value); err != nil {
+			return err
+		}
+	}
+	if capValue != nil {
+		return xattr.LSet(path, caps.Attr, capValue)
+	}
+	return nil
+}
+
+// shiftedCaps returns the shifted capability value of path, or nil if it
+// has none.
+func shiftedCaps(path string, uids Map) ([]byte, error) {
+	s, err := caps.Get(path)
+	if errors.Is(err, xattr.ENOATTR) || walk.Unsupported(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s, err = ShiftCaps(s, uids); err != nil {
+		return nil, &os.PathError{Op: "idmap.Shift", Path: path, Err: err}
+	}
+	return s.Encode()
+}
+
+// shiftedACL returns the shifted value of the ACL attribute name of path,
+// or nil if it has none.
+func shiftedACL(path, name string, uids, gids Map) ([]byte, error) {
+	acl, err := posixacl.Get(path, name)
+	if errors.Is(err, xattr.ENOATTR) || walk.Unsupported(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if acl, err = ShiftACL(acl, uids, gids); err != nil {
+		return nil, &os.PathError{Op: "idmap.Shift", Path: path, Err: err}
+	}
+	return acl.Encode(), nil
+}
+
+// ShiftTree shifts every file and directory in the tree rooted at root,
+// see Shift.
+func ShiftTree(root string, uids, gids Map, opts Options) error {
+	return walk.Walk(root, func(path, _ string, info os.FileInfo) error {
+		return Shift(path, uids, gids, opts)
+	})
+}
@@ -0,0 +1,106 @@
+package idmap
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/xattr/caps"
+	"github.com/pkg/xattr/internal/xattrtest"
+	"github.com/pkg/xattr/posixacl"
+)
+
+func TestMap(t *testing.T) {
+	m, err := ParseMap(strings.NewReader("         0     100000      65536\n 65536 1000 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[uint32]uint32{0: 100000, 1000: 101000, 65535: 165535, 65536: 1000} {
+		if got, ok := m.Map(in); !ok || got != want {
+			t.Errorf("Map(%d) = %d, %v", in, got, ok)
+		}
+	}
+	if _, ok := m.Map(65537); ok {
+		t.Error("Map(65537) succeeded")
+	}
+	if got, ok := m.Invert().Map(101000); !ok || got != 1000 {
+		t.Errorf("Invert().Map(101000) = %d, %v", got, ok)
+	}
+	for _, bad := range []string{"0 1", "0 1 0", "a 1 1", "4294967295 0 2"} {
+		if _, err := ParseMap(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseMap(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestShiftCaps(t *testing.T) {
+	m := Map{{0, 100000, 65536}}
+	v2 := &caps.Set{Revision: caps.Revision2, Effective: true, Permitted: 1 << caps.CapNetBindService}
+	v3, err := ShiftCaps(v2, m)
+	if err != nil || v3.Revision != caps.Revision3 || v3.RootID != 100000 || v3.Permitted != v2.Permitted {
+		t.Errorf("ShiftCaps(v2) = %+v, %v", v3, err)
+	}
+	back, err := ShiftCaps(v3, m.Invert())
+	if err != nil || *back != *v2 {
+		t.Errorf("ShiftCaps back = %+v, %v", back, err)
+	}
+	if _, err := ShiftCaps(v3, m); !errors.Is(err, ErrUnmapped) {
+		t.Errorf("ShiftCaps with unmapped root = %v", err)
+	}
+}
+
+func TestShiftACL(t *testing.T) {
+	acl, _ := posixacl.ParseText("user::rw-,user:1000:r--,group::r--,group:20:rw-,mask::rw-,other::---")
+	shifted, err := ShiftACL(acl, Map{{0, 100000, 65536}}, Map{{0, 200000, 65536}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := shifted.String(); s != "user::rw-,user:101000:r--,group::r--,group:200020:rw-,mask::rw-,other::---" {
+		t.Errorf("ShiftACL = %s", s)
+	}
+	if _, err := ShiftACL(acl, Map{{0, 1, 10}}, nil); !errors.Is(err, ErrUnmapped) {
+		t.Errorf("ShiftACL with unmapped uid = %v", err)
+	}
+}
+
+func TestShiftTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-idmap-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ping")
+	if err := ioutil.WriteFile(path, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = caps.Put(path, &caps.Set{Revision: caps.Revision2, Effective: true, Permitted: 1 << caps.CapNetRaw})
+	xattrtest.Skip(t, err, "cannot set file capabilities", syscall.EPERM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acl, _ := posixacl.ParseText("user::rwx,user:1000:r-x,group::r-x,mask::r-x,other::r-x")
+	hasACL := posixacl.Set(path, posixacl.AccessAttr, acl) == nil
+
+	// Shift into a user namespace without changing ownership, as the
+	// test may not own the target IDs.
+	m := Map{{0, 100000, 65536}}
+	if err := ShiftTree(dir, m, m, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := caps.Get(path)
+	if err != nil || s.Revision != caps.Revision3 || s.RootID != 100000 {
+		t.Errorf("capabilities after shift: %+v, %v", s, err)
+	}
+	if hasACL {
+		got, err := posixacl.Get(path, posixacl.AccessAttr)
+		want, _ := posixacl.ParseText("user::rwx,user:101000:r-x,group::r-x,mask::r-x,other::r-x")
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ACL after shift: %v, %v", got, err)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !solaris && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!solaris,!dragonfly
+
+package idmap
+
+import (
+	"os"
+
+	"github.com/pkg/xattr"
+)
+
+func shiftOwner(path string, uids, gids Map) error {
+	return &os.PathError{Op: "idmap.Shift", Path: path, Err: xattr.ENOTSUP}
+}
@@ -0,0 +1,34 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || solaris || dragonfly
+// +build linux darwin freebsd netbsd openbsd solaris dragonfly
+
+package idmap
+
+import (
+	"os"
+	"syscall"
+)
+
+// shiftOwner shifts the owner and group of path and restores the setuid
+// and setgid bits, which chown clears.
+func shiftOwner(path string, uids, gids Map) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	uid, err := uids.mustMap("uid", st.Uid)
+	if err != nil {
+		return &os.PathError{Op: "idmap.Shift", Path: path, Err: err}
+	}
+	gid, err := gids.mustMap("gid", st.Gid)
+	if err != nil {
+		return &os.PathError{Op: "idmap.Shift", Path: path, Err: err}
+	}
+	if err := os.Lchown(path, int(uid), int(gid)); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink == 0 && info.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 {
+		return os.Chmod(path, info.Mode())
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+/*
+Package ima reads and writes the security.ima attribute that the Linux
+Integrity Measurement Architecture appraises files against. Two formats
+are supported:
+
+	digest-ng     0x04, hash algorithm, digest of the content
+	signature v2  0x03, 2, hash algorithm, key ID, size, signature
+
+A version 2 signature signs the digest of the content with the hash
+algorithm it names, using RSA PKCS #1 v1.5 or ECDSA. Sign and Verify do
+what "evmctl ima_sign" and "evmctl ima_verify" do for these formats.
+Writing security.* attributes requires CAP_SYS_ADMIN.
+*/
+package ima
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	_ "crypto/md5" // register hash functions
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/pkg/xattr"
+)
+
+// Attr is the attribute name.
+const Attr = "security.ima"
+
+// Type is the first byte of an attribute value.
+type Type byte
+
+// Value types from the kernel's integrity.h.
+const (
+	TypeDigest            Type = 0x01
+	TypeHMAC              Type = 0x02
+	TypeSignature         Type = 0x03
+	TypeDigestNG          Type = 0x04
+	TypePortableSignature Type = 0x05
+	TypeVeritySignature   Type = 0x06
+)
+
+// Algorithm is a hash algorithm as numbered in <linux/hash_info.h>.
+type Algorithm byte
+
+// Hash algorithms.
+const (
+	MD5    Algorithm = 1
+	SHA1   Algorithm = 2
+	SHA256 Algorithm = 4
+	SHA384 Algorithm = 5
+	SHA512 Algorithm = 6
+	SHA224 Algorithm = 7
+)
+
+var hashes = map[Algorithm]crypto.Hash{
+	MD5:    crypto.MD5,
+	SHA1:   crypto.SHA1,
+	SHA256: crypto.SHA256,
+	SHA384: crypto.SHA384,
+	SHA512: crypto.SHA512,
+	SHA224: crypto.SHA224,
+}
+
+// Hash returns the crypto.Hash of a, or 0 if a is not supported.
+func (a Algorithm) Hash() crypto.Hash {
+	return hashes[a]
+}
+
+// Errors.
+var (
+	ErrFormat    = errors.New("ima: invalid security.ima value")
+	ErrAlgorithm = errors.New("ima: unsupported hash algorithm")
+	ErrMismatch  = errors.New("ima: content does not match security.ima")
+	ErrKeyID     = errors.New("ima: signature is from another key")
+	ErrKind      = errors.New("ima: security.ima holds no value of this kind")
+)
+
+const signatureVersion = 2
+
+// sigHeaderSize is the size of struct signature_v2_hdr before the
+// signature.
+const sigHeaderSize = 9
+
+// Hash is a digest value. Values of the older TypeDigest format are
+// always SHA1 digests.
+type Hash struct {
+	Algorithm Algorithm
+	Digest    []byte
+}
+
+// ParseHash decodes a digest-ng or legacy digest value.
+func ParseHash(b []byte) (*Hash, error) {
+	switch {
+	case len(b) == 1+sha1.Size && Type(b[0]) == TypeDigest:
+		return &Hash{Algorithm: SHA1, Digest: append([]byte(nil), b[1:]...)}, nil
+	case len(b) >= 2 && Type(b[0]) == TypeDigestNG:
+		h := &Hash{Algorithm: Algorithm(b[1]), Digest: append([]byte(nil), b[2:]...)}
+		if hf := h.Algorithm.Hash(); hf != 0 && hf.Size() != len(h.Digest) {
+			return nil, ErrFormat
+		}
+		return h, nil
+	}
+	return nil, ErrFormat
+}
+
+// Encode returns the digest-ng value of h.
+func (h *Hash) Encode() []byte {
+	return append([]byte{byte(TypeDigestNG), byte(h.Algorithm)}, h.Digest...)
+}
+
+// Signature is a version 2 signature value.
+type Signature struct {
+	Algorithm Algorithm
+	KeyID     uint32
+	Data      []byte
+}
+
+// ParseSignature decodes a version 2 signature value.
+func ParseSignature(b []byte) (*Signature, error) {
+	if len(b) < sigHeaderSize || Type(b[0]) != TypeSignature {
+		return nil, ErrFormat
+	}
+	if b[1] != signatureVersion {
+		return nil, fmt.Errorf("%w: signature version %d", ErrFormat, b[1])
+	}
+	size := int(binary.BigEndian.Uint16(b[7:]))
+	if size != len(b)-sigHeaderSize {
+		return nil, ErrFormat
+	}
+	return &Signature{
+		Algorithm: Algorithm(b[2]),
+		KeyID:     binary.BigEndian.Uint32(b[3:]),
+		Data:      append([]byte(nil), b[sigHeaderSize:]...),
+	}, nil
+}
+
+// Encode returns the attribute value of s.
+func (s *Signature) Encode() []byte {
+	b := make([]byte, sigHeaderSize, sigHeaderSize+len(s.Data))
+	b[0] = byte(TypeSignature)
+	b[1] = signatureVersion
+	b[2] = byte(s.Algorithm)
+	binary.BigEndian.PutUint32(b[3:], s.KeyID)
+	binary.BigEndian.PutUint16(b[7:], uint16(len(s.Data)))
+	return append(b, s.Data...)
+}
+
+// KeyID returns the key ID of pub as the kernel and evmctl compute it: the
+// last four bytes of the SHA1 digest of the subject public key, which is
+// also the X.509 subject key identifier most certificates carry.
+func KeyID(pub crypto.PublicKey) (uint32, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return 0, err
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return 0, err
+	}
+	sum := sha1.Sum(spki.PublicKey.Bytes)
+	return binary.BigEndian.Uint32(sum[len(sum)-4:]), nil
+}
+
+// SignDigest signs digest, computed with alg, using signer.
+func SignDigest(digest []byte, alg Algorithm, signer crypto.Signer) (*Signature, error) {
+	hf := alg.Hash()
+	if hf == 0 {
+		return nil, ErrAlgorithm
+	}
+	id, err := KeyID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	data, err := signer.Sign(rand.Reader, digest, hf)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0xffff {
+		return nil, fmt.Errorf("ima: signature of %d bytes is too large", len(data))
+	}
+	return &Signature{Algorithm: alg, KeyID: id, Data: data}, nil
+}
+
+// VerifyDigest checks that s is a signature of digest made with the
+// private key of pub.
+func (s *Signature) VerifyDigest(digest []byte, pub crypto.PublicKey) error {
+	hf := s.Algorithm.Hash()
+	if hf == 0 {
+		return ErrAlgorithm
+	}
+	id, err := KeyID(pub)
+	if err != nil {
+		return err
+	}
+	if id != s.KeyID {
+		return fmt.Errorf("%w: key ID %08x, want %08x", ErrKeyID, s.KeyID, id)
+	}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(pub, hf, digest, s.Data) != nil {
+			return ErrMismatch
+		}
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(s.Data, &sig); err != nil || len(rest) != 0 {
+			return ErrMismatch
+		}
+		if !ecdsa.Verify(pub, digest, sig.R, sig.S) {
+			return ErrMismatch
+		}
+	default:
+		return fmt.Errorf("ima: unsupported public key type %T", pub)
+	}
+	return nil
+}
+
+// FileDigest returns the digest IMA computes for the regular file path:
+// the digest of its content.
+func FileDigest(path string, alg Algorithm) ([]byte, error) {
+	hf := alg.Hash()
+	if hf == 0 {
+		return nil, ErrAlgorithm
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("ima: %s is not a regular file", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := hf.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// SetHash computes the digest of path and stores it as a digest-ng value.
+func SetHash(path string, alg Algorithm) error {
+	digest, err := FileDigest(path, alg)
+	if err != nil {
+		return err
+	}
+	return xattr.LSet(path, Attr, (&Hash{Algorithm: alg, Digest: digest}).Encode())
+}
+
+// CheckHash checks that path matches the digest stored in its attribute.
+func CheckHash(path string) error {
+	b, err := xattr.LGet(path, Attr)
+	if err != nil {
+		return err
+	}
+	if len(b) > 0 && Type(b[0]) == TypeSignature {
+		return ErrKind
+	}
+	h, err := ParseHash(b)
+	if err != nil {
+		return err
+	}
+	digest, err := FileDigest(path, h.Algorithm)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, h.Digest) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// Sign computes the digest of path, signs it with signer and stores the
+// signature.
+func Sign(path string, alg Algorithm, signer crypto.Signer) error {
+	digest, err := FileDigest(path, alg)
+	if err != nil {
+		return err
+	}
+	sig, err := SignDigest(digest, alg, signer)
+	if err != nil {
+		return err
+	}
+	return xattr.LSet(path, Attr, sig.Encode())
+}
+
+// Verify checks that the signature stored for path is valid for its
+// content and was made with the private key of pub.
+func Verify(path string, pub crypto.PublicKey) error {
+	b, err := xattr.LGet(path, Attr)
+	if err != nil {
+		return err
+	}
+	if len(b) > 0 && Type(b[0]) != TypeSignature {
+		return ErrKind
+	}
+	sig, err := ParseSignature(b)
+	if err != nil {
+		return err
+	}
+	digest, err := FileDigest(path, sig.Algorithm)
+	if err != nil {
+		return err
+	}
+	return sig.VerifyDigest(digest, pub)
+}
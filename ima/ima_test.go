@@ -0,0 +1,119 @@
+package ima
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestHash(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello"))
+	h := &Hash{Algorithm: SHA256, Digest: sum[:]}
+	b := h.Encode()
+	if !bytes.Equal(b[:2], []byte{0x04, 0x04}) || len(b) != 2+sha256.Size {
+		t.Fatalf("Encode = %x", b)
+	}
+	if got, err := ParseHash(b); err != nil || !reflect.DeepEqual(got, h) {
+		t.Errorf("ParseHash = %+v, %v", got, err)
+	}
+	legacy := append([]byte{0x01}, make([]byte, 20)...)
+	if got, err := ParseHash(legacy); err != nil || got.Algorithm != SHA1 {
+		t.Errorf("ParseHash(legacy) = %+v, %v", got, err)
+	}
+	for _, bad := range [][]byte{nil, {0x04}, b[:10], {0x03, 0x02}} {
+		if _, err := ParseHash(bad); err != ErrFormat {
+			t.Errorf("ParseHash(%x) = %v", bad, err)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("hello"))
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		sig, err := SignDigest(digest[:], SHA256, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := sig.Encode()
+		if b[0] != 0x03 || b[1] != 2 || b[2] != byte(SHA256) {
+			t.Errorf("Encode = %x", b[:sigHeaderSize])
+		}
+		parsed, err := ParseSignature(b)
+		if err != nil || !reflect.DeepEqual(parsed, sig) {
+			t.Fatalf("ParseSignature = %+v, %v", parsed, err)
+		}
+		if err := parsed.VerifyDigest(digest[:], key.Public()); err != nil {
+			t.Errorf("%T: VerifyDigest = %v", key, err)
+		}
+		other := sha256.Sum256([]byte("world"))
+		if err := parsed.VerifyDigest(other[:], key.Public()); err != ErrMismatch {
+			t.Errorf("%T: VerifyDigest(other) = %v", key, err)
+		}
+	}
+	sig, _ := SignDigest(digest[:], SHA256, rsaKey)
+	if err := sig.VerifyDigest(digest[:], ecKey.Public()); !errors.Is(err, ErrKeyID) {
+		t.Errorf("VerifyDigest with another key = %v", err)
+	}
+	if _, err := ParseSignature([]byte{0x03, 1, 4, 0, 0, 0, 0, 0, 0}); !errors.Is(err, ErrFormat) {
+		t.Errorf("ParseSignature(v1) = %v", err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "xattr-ima-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("hello")
+	f.Close()
+	defer os.Remove(f.Name())
+	digest, err := FileDigest(f.Name(), SHA256)
+	if sum := sha256.Sum256([]byte("hello")); err != nil || !bytes.Equal(digest, sum[:]) {
+		t.Errorf("FileDigest = %x, %v", digest, err)
+	}
+
+	err = SetHash(f.Name(), SHA256)
+	xattrtest.Skip(t, err, "cannot write security.ima", os.ErrPermission)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckHash(f.Name()); err != nil {
+		t.Errorf("CheckHash = %v", err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Sign(f.Name(), SHA256, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(f.Name(), key.Public()); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+	if err := CheckHash(f.Name()); err != ErrKind {
+		t.Errorf("CheckHash on a signature = %v", err)
+	}
+	ioutil.WriteFile(f.Name(), []byte("world"), 0600)
+	if err := Verify(f.Name(), key.Public()); err != ErrMismatch {
+		t.Errorf("Verify after change = %v", err)
+	}
+}
@@ -0,0 +1,400 @@
+/*
+Package index maintains a persistent index of the extended attributes in a
+file tree, so that attributes can be looked up by name and value without
+walking the tree.
+
+Build reads the attributes of every file through package xattr and records
+them together with the inode number and change time of the file. Refresh
+brings an index up to date by re-reading only the files whose inode number
+or change time differ; setting or removing an attribute updates the change
+time. Values larger than Options.MaxValue are stored as SHA-256 hashes.
+
+The index is stored in a compact binary format:
+
+	"XATTRIDX" version:uvarint root:string maxValue:varint count:uvarint entry...
+
+	entry = path:string ino:uvarint ctimeSec:varint ctimeNsec:uvarint
+	        nattrs:uvarint attr...
+	attr  = name:string size:uvarint kind:byte data:string
+
+where strings are a uvarint length followed by the bytes, and kind is 0 if
+data holds the value and 1 if it holds the SHA-256 hash of the value.
+*/
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/xattr/internal/walk"
+)
+
+const (
+	magic = "XATTRIDX"
+
+	// Version is the index format version written by this package.
+	Version = 1
+
+	// DefaultMaxValue is the default size above which values are stored as
+	// hashes.
+	DefaultMaxValue = 256
+)
+
+// Options control how an index is built.
+type Options struct {
+	// MaxValue is the largest value in bytes that is stored verbatim.
+	// Larger values are stored as SHA-256 hashes. Zero means
+	// DefaultMaxValue; a negative value stores hashes only.
+	MaxValue int
+}
+
+// Index is the recorded state of a file tree.
+type Index struct {
+	// Root is the root of the indexed tree as passed to Build.
+	Root     string
+	maxValue int
+	entries  []Entry
+	byName   map[string][]int
+}
+
+// Entry records a single file or directory.
+type Entry struct {
+	// Path is slash-separated and relative to Root.
+	Path  string
+	Ino   uint64
+	Ctime time.Time
+	Attrs []Attr
+}
+
+// Attr records a single attribute. Value is nil if the value was too large
+// to store, in which case Hash holds its SHA-256 hash.
+type Attr struct {
+	Name  string
+	Size  int
+	Value []byte
+	Hash  []byte
+}
+
+// Equal reports whether the attribute has the given value.
+func (a *Attr) Equal(value []byte) bool {
+	if a.Hash == nil {
+		return bytes.Equal(a.Value, value)
+	}
+	sum := sha256.Sum256(value)
+	return len(value) == a.Size && bytes.Equal(a.Hash, sum[:])
+}
+
+// Stats reports the work done by Refresh.
+type Stats struct {
+	Files   int // files and directories in the tree
+	Updated int // files whose attributes were read
+	Removed int // files no longer in the tree
+}
+
+// Build indexes the tree rooted at root without following symlinks. Files
+// that cannot be read are left out, as described for Refresh.
+func Build(root string, opts Options) (*Index, error) {
+	maxValue := opts.MaxValue
+	if maxValue == 0 {
+		maxValue = DefaultMaxValue
+	}
+	idx := &Index{Root: root, maxValue: maxValue}
+	_, err := idx.Refresh()
+	if _, ok := err.(walk.Errors); err != nil && !ok {
+		return nil, err
+	}
+	return idx, err
+}
+
+// SetMaxValue changes the size above which values are stored as hashes, with
+// the meaning of Options.MaxValue. Refresh reads the files again whose
+// entries were recorded with a different size.
+func (idx *Index) SetMaxValue(maxValue int) {
+	if maxValue == 0 {
+		maxValue = DefaultMaxValue
+	}
+	idx.maxValue = maxValue
+}
+
+// Refresh brings the index up to date with the tree. Files whose inode
+// number and change time match the index are not read again. Files that
+// cannot be read keep their previous entry, if any, and are read again by
+// the next Refresh; Refresh goes on and returns an error listing them.
+func (idx *Index) Refresh() (Stats, error) {
+	var st Stats
+	old := make(map[string]*Entry, len(idx.entries))
+	for i := range idx.entries {
+		old[idx.entries[i].Path] = &idx.entries[i]
+	}
+	var entries []Entry
+	var errs walk.Errors
+	err := walk.All(idx.Root, &errs, func(path, rel string, info os.FileInfo) error {
+		st.Files++
+		ino, ctime := stat(info)
+		prev, ok := old[rel]
+		if ok {
+			delete(old, rel)
+			if prev.Ino == ino && prev.Ctime.Equal(ctime) && idx.current(prev) {
+				entries = append(entries, *prev)
+				return nil
+			}
+		}
+		attrs, err := walk.Read(path, nil)
+		if err != nil {
+			errs.Add(err)
+			if ok {
+				entries = append(entries, *prev)
+			}
+			return nil
+		}
+		st.Updated++
+		e := Entry{Path: rel, Ino: ino, Ctime: ctime}
+		for _, a := range attrs {
+			e.Attrs = append(e.Attrs, idx.record(a))
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+	st.Removed = len(old)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	idx.entries = entries
+	idx.byName = nil
+	return st, errs.Err()
+}
+
+// current reports whether the values of e are stored as the maximum value
+// size requires.
+func (idx *Index) current(e *Entry) bool {
+	for _, a := range e.Attrs {
+		if (a.Hash != nil) != (a.Size > idx.maxValue) {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *Index) record(a walk.Attr) Attr {
+	r := Attr{Name: a.Name, Size: len(a.Value)}
+	if len(a.Value) > idx.maxValue {
+		sum := sha256.Sum256(a.Value)
+		r.Hash = sum[:]
+	} else {
+		r.Value = a.Value
+		if r.Value == nil {
+			r.Value = []byte{}
+		}
+	}
+	return r
+}
+
+// Entries returns the indexed files sorted by path. The result must not be
+// modified.
+func (idx *Index) Entries() []Entry { return idx.entries }
+
+// Hit is a file that has an attribute looked up in the index.
+type Hit struct {
+	Path string
+	Attr *Attr
+}
+
+// Lookup returns all files that have the attribute name, sorted by path.
+func (idx *Index) Lookup(name string) []Hit {
+	return idx.lookup(name, func(*Attr) bool { return true })
+}
+
+// LookupValue returns all files whose attribute name has the given value,
+// sorted by path.
+func (idx *Index) LookupValue(name string, value []byte) []Hit {
+	return idx.lookup(name, func(a *Attr) bool { return a.Equal(value) })
+}
+
+func (idx *Index) lookup(name string, match func(*Attr) bool) []Hit {
+	if idx.byName == nil {
+		idx.byName = map[string][]int{}
+		for i, e := range idx.entries {
+			for _, a := range e.Attrs {
+				idx.byName[a.Name] = append(idx.byName[a.Name], i)
+			}
+		}
+	}
+	var hits []Hit
+	for _, i := range idx.byName[name] {
+		e := &idx.entries[i]
+		for j := range e.Attrs {
+			if a := &e.Attrs[j]; a.Name == name && match(a) {
+				hits = append(hits, Hit{e.Path, a})
+			}
+		}
+	}
+	return hits
+}
+
+// ErrFormat is returned by Read for data that is not a valid index.
+var ErrFormat = errors.New("index: invalid format")
+
+// WriteTo writes the index to w.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	ew := &encoder{w: bufio.NewWriter(w)}
+	ew.raw([]byte(magic))
+	ew.uvarint(Version)
+	ew.string(idx.Root)
+	ew.varint(int64(idx.maxValue))
+	ew.uvarint(uint64(len(idx.entries)))
+	for _, e := range idx.entries {
+		ew.string(e.Path)
+		ew.uvarint(e.Ino)
+		ew.varint(e.Ctime.Unix())
+		ew.uvarint(uint64(e.Ctime.Nanosecond()))
+		ew.uvarint(uint64(len(e.Attrs)))
+		for _, a := range e.Attrs {
+			ew.string(a.Name)
+			ew.uvarint(uint64(a.Size))
+			if a.Hash != nil {
+				ew.raw([]byte{1})
+				ew.bytes(a.Hash)
+			} else {
+				ew.raw([]byte{0})
+				ew.bytes(a.Value)
+			}
+		}
+	}
+	if ew.err == nil {
+		ew.err = ew.w.Flush()
+	}
+	return ew.n, ew.err
+}
+
+// Read decodes an index previously written with WriteTo.
+func Read(r io.Reader) (*Index, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+	if string(d.raw(len(magic))) != magic {
+		return nil, ErrFormat
+	}
+	if v := d.uvarint(); d.err == nil && v != Version {
+		return nil, fmt.Errorf("index: unsupported version %d", v)
+	}
+	idx := &Index{Root: d.string(), maxValue: int(d.varint())}
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		e := Entry{Path: d.string(), Ino: d.uvarint()}
+		sec, nsec := d.varint(), d.uvarint()
+		e.Ctime = time.Unix(sec, int64(nsec))
+		nattrs := d.count()
+		for j := 0; j < nattrs && d.err == nil; j++ {
+			a := Attr{Name: d.string(), Size: int(d.uvarint())}
+			switch kind := d.raw(1); {
+			case d.err != nil:
+			case kind[0] == 0:
+				a.Value = d.bytes()
+			case kind[0] == 1:
+				a.Hash = d.bytes()
+			default:
+				d.err = ErrFormat
+			}
+			e.Attrs = append(e.Attrs, a)
+		}
+		idx.entries = append(idx.entries, e)
+	}
+	if d.err != nil {
+		if d.err == io.EOF || d.err == io.ErrUnexpectedEOF {
+			return nil, ErrFormat
+		}
+		return nil, d.err
+	}
+	return idx, nil
+}
+
+// encoder writes the primitives of the index format and remembers the
+// first error.
+type encoder struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) raw(b []byte) {
+	if e.err != nil {
+		return
+	}
+	n, err := e.w.Write(b)
+	e.n += int64(n)
+	e.err = err
+}
+
+func (e *encoder) uvarint(v uint64) { e.raw(e.buf[:binary.PutUvarint(e.buf[:], v)]) }
+func (e *encoder) varint(v int64)   { e.raw(e.buf[:binary.PutVarint(e.buf[:], v)]) }
+func (e *encoder) string(s string)  { e.bytes([]byte(s)) }
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.raw(b)
+}
+
+// decoder reads the primitives of the index format and remembers the first
+// error.
+type decoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *decoder) raw(n int) []byte {
+	b := make([]byte, n)
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, b)
+	}
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	d.err = err
+	return v
+}
+
+// count reads a length and guards against lengths that cannot be right,
+// so that corrupt input does not cause huge allocations.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > 1<<31 {
+		d.err = ErrFormat
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if n > 64<<20 {
+		d.err = ErrFormat
+	}
+	if d.err != nil {
+		return nil
+	}
+	return d.raw(n)
+}
+
+func (d *decoder) string() string { return string(d.bytes()) }
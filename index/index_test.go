@@ -0,0 +1,100 @@
+package index
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func paths(hits []Hit) []string {
+	var p []string
+	for _, h := range hits {
+		p = append(p, h.Path)
+	}
+	return p
+}
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-index-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	big := strings.Repeat("x", 100)
+	xattrtest.Set(t, filepath.Join(dir, "a"), "user.owner", "alice")
+	xattrtest.Set(t, filepath.Join(dir, "b"), "user.owner", "bob")
+	xattrtest.Set(t, filepath.Join(dir, "b"), "user.blob", big)
+	xattrtest.Set(t, filepath.Join(dir, "c"), "user.blob", big)
+
+	idx, err := Build(dir, Options{MaxValue: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := idx.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if idx, err = Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := paths(idx.Lookup("user.owner")); !reflect.DeepEqual(p, []string{"a", "b"}) {
+		t.Errorf("Lookup: have %v", p)
+	}
+	if p := paths(idx.LookupValue("user.owner", []byte("bob"))); !reflect.DeepEqual(p, []string{"b"}) {
+		t.Errorf("LookupValue: have %v", p)
+	}
+	hits := idx.LookupValue("user.blob", []byte(big))
+	if p := paths(hits); !reflect.DeepEqual(p, []string{"b", "c"}) {
+		t.Errorf("LookupValue of hashed value: have %v", p)
+	}
+	if hits[0].Attr.Value != nil || len(hits[0].Attr.Hash) != 32 {
+		t.Errorf("large value was not hashed: %+v", hits[0].Attr)
+	}
+
+	xattrtest.Set(t, filepath.Join(dir, "a"), "user.owner", "carol")
+	if err := os.Remove(filepath.Join(dir, "c")); err != nil {
+		t.Fatal(err)
+	}
+	st, err := idx.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Files != 3 || st.Updated != 2 || st.Removed != 1 {
+		t.Errorf("wrong refresh stats: %+v", st)
+	}
+	if p := paths(idx.LookupValue("user.owner", []byte("carol"))); !reflect.DeepEqual(p, []string{"a"}) {
+		t.Errorf("LookupValue after refresh: have %v", p)
+	}
+	if p := paths(idx.Lookup("user.blob")); !reflect.DeepEqual(p, []string{"b"}) {
+		t.Errorf("Lookup after refresh: have %v", p)
+	}
+
+	// Only b holds a value stored differently with the larger size.
+	idx.SetMaxValue(1000)
+	if st, err = idx.Refresh(); err != nil || st.Updated != 1 {
+		t.Errorf("Refresh after SetMaxValue = %+v, %v", st, err)
+	}
+	if hits := idx.Lookup("user.blob"); len(hits) != 1 || string(hits[0].Attr.Value) != big {
+		t.Errorf("value was not stored after SetMaxValue: %+v", hits)
+	}
+}
+
+func TestReadCorrupt(t *testing.T) {
+	for _, s := range []string{"", "XATTRIDX", "XATTRIDX\x01\x00\x00\x05", "NOTANIDX"} {
+		if _, err := Read(strings.NewReader(s)); err == nil {
+			t.Errorf("Read(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package index
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// stat returns the inode number and change time of a file.
+func stat(info os.FileInfo) (uint64, time.Time) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, info.ModTime()
+	}
+	return uint64(st.Ino), time.Unix(int64(st.Ctimespec.Sec), int64(st.Ctimespec.Nsec))
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !freebsd && !openbsd && !solaris && !dragonfly && !darwin && !netbsd
+// +build !linux,!freebsd,!openbsd,!solaris,!dragonfly,!darwin,!netbsd
+
+package index
+
+import (
+	"os"
+	"time"
+)
+
+// stat returns the inode number and change time of a file. Without a change
+// time, the modification time is the best we can do.
+func stat(info os.FileInfo) (uint64, time.Time) {
+	return 0, info.ModTime()
+}
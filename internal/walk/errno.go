@@ -0,0 +1,8 @@
+//go:build !plan9
+// +build !plan9
+
+package walk
+
+import "syscall"
+
+const errOpNotSupp = syscall.EOPNOTSUPP
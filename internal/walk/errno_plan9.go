@@ -0,0 +1,6 @@
+package walk
+
+import "github.com/pkg/xattr"
+
+// The syscall package of plan9 has no EOPNOTSUPP.
+const errOpNotSupp = xattr.ENOTSUP
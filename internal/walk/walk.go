@@ -0,0 +1,152 @@
+// Package walk contains the tree walking logic shared by the tools built on
+// top of package xattr.
+package walk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/xattr"
+)
+
+// Attr is a single extended attribute.
+type Attr struct {
+	Name  string
+	Value []byte
+}
+
+// Func is called by Walk for every file and directory below the root. rel is
+// the slash-separated path relative to the root ("." for the root itself).
+// If fn returns filepath.SkipDir on a directory, its contents are skipped.
+type Func func(path, rel string, info os.FileInfo) error
+
+// Walk walks the file tree rooted at root in lexical order without following
+// symlinks. Errors from reading the tree are returned to the caller.
+func Walk(root string, fn Func) error {
+	return walk(root, nil, fn)
+}
+
+// All is like Walk, but goes on past the parts of the tree it cannot read
+// and adds their errors to errs; only a root that cannot be read is
+// returned. fn adds the errors of the files it cannot process to errs as
+// well; an error returned by fn still stops the walk.
+func All(root string, errs *Errors, fn Func) error {
+	return walk(root, errs, fn)
+}
+
+func walk(root string, errs *Errors, fn Func) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if errs == nil || info == nil && path == root {
+				return err
+			}
+			errs.Add(err)
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, filepath.ToSlash(rel), info)
+	})
+}
+
+// Errors lists the errors for the individual files a walk could not
+// process.
+type Errors []error
+
+// Add appends err to the list.
+func (e *Errors) Add(err error) {
+	*e = append(*e, err)
+}
+
+// Err returns the list as an error, or nil if it is empty.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e Errors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Is reports whether any of the individual errors matches target.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first individual error that matches target.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the sorted names of the extended attributes of path without
+// following a symlink at the end of the path. A filesystem that does not
+// support extended attributes is reported as having none.
+func List(path string) ([]string, error) {
+	names, err := xattr.LList(path)
+	if err != nil {
+		if Unsupported(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Read returns the extended attributes of path for which match returns
+// true, sorted by name. A nil match selects all attributes. Attributes that
+// disappear between listing and reading are skipped.
+func Read(path string, match func(name string) bool) ([]Attr, error) {
+	names, err := List(path)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([]Attr, 0, len(names))
+	for _, name := range names {
+		if match != nil && !match(name) {
+			continue
+		}
+		value, err := xattr.LGet(path, name)
+		if err != nil {
+			if Vanished(err) {
+				continue
+			}
+			return nil, err
+		}
+		attrs = append(attrs, Attr{name, value})
+	}
+	return attrs, nil
+}
+
+// Vanished reports whether err means that the attribute does not exist,
+// typically because it was removed after it was listed.
+func Vanished(err error) bool {
+	return errors.Is(err, xattr.ENOATTR)
+}
+
+// Unsupported reports whether err means that the filesystem or platform does
+// not support extended attributes.
+func Unsupported(err error) bool {
+	return errors.Is(err, xattr.ENOTSUP) || errors.Is(err, errOpNotSupp)
+}
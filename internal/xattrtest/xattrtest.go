@@ -0,0 +1,44 @@
+// Package xattrtest contains the test helpers shared by the packages built
+// on top of package xattr.
+package xattrtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// Check fails the test if err is not nil. If err means that the filesystem
+// does not support extended attributes, the test is skipped instead.
+func Check(t testing.TB, err error) {
+	t.Helper()
+	Skip(t, err, "filesystem does not support extended attributes")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Skip skips the test with reason if err means that the filesystem does not
+// support extended attributes or matches one of also.
+func Skip(t testing.TB, err error, reason string, also ...error) {
+	t.Helper()
+	if err == nil {
+		return
+	}
+	skip := walk.Unsupported(err)
+	for _, target := range also {
+		skip = skip || errors.Is(err, target)
+	}
+	if skip {
+		t.Skip(reason)
+	}
+}
+
+// Set sets the attribute name of path to value, following symlinks, and
+// checks the result with Check.
+func Set(t testing.TB, path, name, value string) {
+	t.Helper()
+	Check(t, xattr.Set(path, name, []byte(value)))
+}
@@ -0,0 +1,254 @@
+/*
+Package lint checks the names and values of extended attributes across a
+file tree and reports questionable ones.
+
+A Linter runs a set of checks against every attribute. The built-in checks
+catch names that are not valid UTF-8, names longer than XATTR_NAME_MAX,
+names that consist of a namespace prefix only, values close to the size
+limit of common filesystems and deprecated names. Additional checks can be
+supplied in the Config. Findings may carry a Fix that Apply performs.
+*/
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+const (
+	// NameMax is the maximum length of an attribute name in bytes
+	// (XATTR_NAME_MAX on Linux).
+	NameMax = 255
+
+	// DefaultMaxSize is the default size in bytes of name plus value above
+	// which the size check reports an attribute. ext4 stores all attributes
+	// of an inode in a single 4 KB block.
+	DefaultMaxSize = 3584
+)
+
+// Severity ranks findings.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Fix is a suggested fix for a finding. An empty NewName means the
+// attribute should be removed.
+type Fix struct {
+	NewName string
+}
+
+func (f *Fix) String() string {
+	if f.NewName == "" {
+		return "remove"
+	}
+	return fmt.Sprintf("rename to %q", f.NewName)
+}
+
+// Check tests a single attribute. Test returns an empty message if the
+// attribute passes, and may suggest a fix otherwise.
+type Check struct {
+	ID       string
+	Severity Severity
+	Test     func(name string, value []byte) (msg string, fix *Fix)
+}
+
+// Finding is a check that failed for an attribute.
+type Finding struct {
+	Path     string
+	Name     string
+	Check    string
+	Severity Severity
+	Message  string
+	Fix      *Fix
+}
+
+func (f Finding) String() string {
+	s := fmt.Sprintf("%s: %s: %s: %q: %s", f.Path, f.Severity, f.Check, f.Name, f.Message)
+	if f.Fix != nil {
+		s += " (fix: " + f.Fix.String() + ")"
+	}
+	return s
+}
+
+// Config selects and parameterizes the checks of a Linter.
+type Config struct {
+	// MaxSize is the size of name plus value above which an attribute is
+	// reported. Zero means DefaultMaxSize.
+	MaxSize int
+	// Deprecated maps deprecated attribute names to their replacement. An
+	// empty replacement suggests removing the attribute.
+	Deprecated map[string]string
+	// Disable lists the IDs of built-in checks to skip.
+	Disable []string
+	// Checks are run in addition to the built-in checks.
+	Checks []Check
+}
+
+// Linter runs checks against extended attributes.
+type Linter struct {
+	checks []Check
+}
+
+// New returns a Linter for the given configuration.
+func New(cfg Config) *Linter {
+	maxSize := cfg.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+	builtin := []Check{
+		{"name-utf8", Error, checkUTF8},
+		{"name-length", Error, checkLength},
+		{"name-empty", Error, checkEmpty},
+		{"value-size", Warning, sizeCheck(maxSize)},
+		{"deprecated", Warning, deprecatedCheck(cfg.Deprecated)},
+	}
+	l := &Linter{}
+	for _, c := range builtin {
+		if !contains(cfg.Disable, c.ID) {
+			l.checks = append(l.checks, c)
+		}
+	}
+	l.checks = append(l.checks, cfg.Checks...)
+	return l
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func checkUTF8(name string, value []byte) (string, *Fix) {
+	if utf8.ValidString(name) {
+		return "", nil
+	}
+	return "name is not valid UTF-8", &Fix{strings.ToValidUTF8(name, "_")}
+}
+
+func checkLength(name string, value []byte) (string, *Fix) {
+	if len(name) <= NameMax {
+		return "", nil
+	}
+	return fmt.Sprintf("name is %d bytes long, the limit is %d", len(name), NameMax), nil
+}
+
+func checkEmpty(name string, value []byte) (string, *Fix) {
+	i := strings.IndexByte(name, '.')
+	if name != "" && i != len(name)-1 {
+		return "", nil
+	}
+	return "name has no key after the namespace", &Fix{}
+}
+
+func sizeCheck(maxSize int) func(string, []byte) (string, *Fix) {
+	return func(name string, value []byte) (string, *Fix) {
+		if size := len(name) + len(value); size > maxSize {
+			return fmt.Sprintf("name and value take %d bytes, more than %d", size, maxSize), nil
+		}
+		return "", nil
+	}
+}
+
+func deprecatedCheck(deprecated map[string]string) func(string, []byte) (string, *Fix) {
+	return func(name string, value []byte) (string, *Fix) {
+		repl, ok := deprecated[name]
+		if !ok {
+			return "", nil
+		}
+		if repl == "" {
+			return "name is deprecated", &Fix{}
+		}
+		return fmt.Sprintf("name is deprecated in favor of %q", repl), &Fix{repl}
+	}
+}
+
+// File checks the extended attributes of path. Symlinks are not followed.
+func (l *Linter) File(path string) ([]Finding, error) {
+	attrs, err := walk.Read(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, a := range attrs {
+		for _, c := range l.checks {
+			msg, fix := c.Test(a.Name, a.Value)
+			if msg == "" {
+				continue
+			}
+			findings = append(findings, Finding{
+				Path:     path,
+				Name:     a.Name,
+				Check:    c.ID,
+				Severity: c.Severity,
+				Message:  msg,
+				Fix:      fix,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// Tree checks every file and directory in the tree rooted at root and calls
+// report for each finding. If report returns an error, Tree stops and
+// returns that error. Files that cannot be read are skipped; once done,
+// Tree returns an error listing them.
+func (l *Linter) Tree(root string, report func(Finding) error) error {
+	var errs walk.Errors
+	err := walk.All(root, &errs, func(path, rel string, info os.FileInfo) error {
+		findings, err := l.File(path)
+		if err != nil {
+			errs.Add(err)
+			return nil
+		}
+		for _, f := range findings {
+			if err := report(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errs.Err()
+}
+
+// ErrNoFix is returned by Apply for findings without a fix.
+var ErrNoFix = errors.New("lint: finding has no fix")
+
+// Apply performs the fix suggested by f. A rename does not overwrite an
+// existing attribute of the new name.
+func Apply(f Finding) error {
+	if f.Fix == nil {
+		return ErrNoFix
+	}
+	if f.Fix.NewName != "" {
+		return xattr.LRename(f.Path, f.Name, f.Fix.NewName, 0)
+	}
+	return xattr.LRemove(f.Path, f.Name)
+}
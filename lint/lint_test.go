@@ -0,0 +1,78 @@
+package lint
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestChecks(t *testing.T) {
+	l := New(Config{
+		MaxSize:    300,
+		Deprecated: map[string]string{"user.old": "user.new", "user.gone": ""},
+	})
+	tests := []struct {
+		name  string
+		value string
+		check string
+		fix   *Fix
+	}{
+		{"user.ok", "value", "", nil},
+		{"user.\xffbad", "", "name-utf8", &Fix{"user._bad"}},
+		{"user." + strings.Repeat("x", 251), "", "name-length", nil},
+		{"user.", "", "name-empty", &Fix{}},
+		{"user.big", strings.Repeat("v", 300), "value-size", nil},
+		{"user.old", "", "deprecated", &Fix{"user.new"}},
+		{"user.gone", "", "deprecated", &Fix{}},
+	}
+	for _, tt := range tests {
+		var found []string
+		for _, c := range l.checks {
+			msg, fix := c.Test(tt.name, []byte(tt.value))
+			if msg == "" {
+				continue
+			}
+			found = append(found, c.ID)
+			if c.ID == tt.check && !reflect.DeepEqual(fix, tt.fix) {
+				t.Errorf("%q: fix is %v, want %v", tt.name, fix, tt.fix)
+			}
+		}
+		if tt.check == "" && len(found) != 0 || tt.check != "" && (len(found) != 1 || found[0] != tt.check) {
+			t.Errorf("%q: checks %v failed, want %q", tt.name, found, tt.check)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "xattr-lint-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	path := tmp.Name()
+
+	xattrtest.Set(t, path, "user.old", "v")
+	l := New(Config{Deprecated: map[string]string{"user.old": "user.new"}})
+	findings, err := l.File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("want one finding, have %v", findings)
+	}
+	if err := Apply(findings[0]); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := xattr.Get(path, "user.new"); err != nil || string(v) != "v" {
+		t.Errorf("renamed attribute: have %q, %v", v, err)
+	}
+	if _, err := xattr.Get(path, "user.old"); err == nil {
+		t.Error("old attribute still exists")
+	}
+}
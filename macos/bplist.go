@@ -0,0 +1,481 @@
+package macos
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"unicode/utf16"
+)
+
+// Binary property lists are decoded to and encoded from these Go types:
+//
+//	nil                      null
+//	bool                     boolean
+//	int64                    integer (uint64 above math.MaxInt64)
+//	float64                  real
+//	time.Time                date
+//	[]byte                   data
+//	string                   string
+//	UID                      uid, as used by NSKeyedArchiver
+//	[]interface{}            array
+//	map[string]interface{}   dictionary
+//
+// Marshal also accepts the other integer types and []string.
+
+// UID is a reference to an object in a keyed archive.
+type UID uint64
+
+// ErrPlist is returned for data that is not a valid binary property list.
+var ErrPlist = errors.New("macos: invalid binary property list")
+
+const bplistMagic = "bplist00"
+
+// appleEpoch is the reference date of property list dates.
+var appleEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// maxPlistDepth limits the nesting of containers.
+const maxPlistDepth = 512
+
+// States of an object while decoding.
+const (
+	objectPending = iota
+	objectDecoding
+	objectDone
+)
+
+type plistDecoder struct {
+	data    []byte
+	offsets []uint64
+	refSize int
+	state   []uint8
+	cache   []interface{}
+}
+
+// Unmarshal decodes a binary property list ("bplist00").
+func Unmarshal(data []byte) (interface{}, error) {
+	if len(data) < len(bplistMagic)+32 || string(data[:len(bplistMagic)]) != bplistMagic {
+		return nil, ErrPlist
+	}
+	trailer := data[len(data)-32:]
+	offSize, refSize := int(trailer[6]), int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	tableOff := binary.BigEndian.Uint64(trailer[24:])
+	if offSize < 1 || offSize > 8 || refSize < 1 || refSize > 8 || top >= numObjects ||
+		tableOff >= uint64(len(data)) || numObjects > (uint64(len(data))-tableOff)/uint64(offSize) {
+		return nil, ErrPlist
+	}
+	d := &plistDecoder{
+		data:    data,
+		refSize: refSize,
+		offsets: make([]uint64, numObjects),
+		state:   make([]uint8, numObjects),
+		cache:   make([]interface{}, numObjects),
+	}
+	for i := range d.offsets {
+		d.offsets[i] = readUint(data[tableOff+uint64(i*offSize):], offSize)
+	}
+	return d.object(top, 0)
+}
+
+func readUint(b []byte, n int) uint64 {
+	var v uint64
+	for _, c := range b[:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// bytes returns n bytes at off, or an error if they are out of range.
+func (d *plistDecoder) bytes(off, n uint64) ([]byte, error) {
+	if off > uint64(len(d.data)) || n > uint64(len(d.data))-off {
+		return nil, ErrPlist
+	}
+	return d.data[off : off+n], nil
+}
+
+// count returns the object count encoded in the low nibble of the marker at
+// off, and the offset of the object body.
+func (d *plistDecoder) count(off uint64) (uint64, uint64, error) {
+	n := uint64(d.data[off] & 0x0f)
+	off++
+	if n != 0x0f {
+		return n, off, nil
+	}
+	b, err := d.bytes(off, 1)
+	if err != nil || b[0]&0xf0 != 0x10 {
+		return 0, 0, ErrPlist
+	}
+	size := uint64(1) << (b[0] & 0x0f)
+	v, err := d.bytes(off+1, size)
+	if err != nil || size > 8 {
+		return 0, 0, ErrPlist
+	}
+	return readUint(v, int(size)), off + 1 + size, nil
+}
+
+func (d *plistDecoder) refs(off, n uint64) ([]uint64, error) {
+	if n > uint64(len(d.data)) {
+		return nil, ErrPlist
+	}
+	b, err := d.bytes(off, n*uint64(d.refSize))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readUint(b[i*d.refSize:], d.refSize)
+	}
+	return refs, nil
+}
+
+// object returns the decoded object ref. Every object is decoded once, so
+// that crafted lists referencing the same containers over and over cannot
+// blow up; all references to an object share the decoded value. A
+// reference to an object that is still being decoded is a cycle.
+func (d *plistDecoder) object(ref uint64, depth int) (interface{}, error) {
+	if ref >= uint64(len(d.offsets)) || depth > maxPlistDepth {
+		return nil, ErrPlist
+	}
+	switch d.state[ref] {
+	case objectDone:
+		return d.cache[ref], nil
+	case objectDecoding:
+		return nil, ErrPlist
+	}
+	d.state[ref] = objectDecoding
+	v, err := d.decode(ref, depth)
+	if err != nil {
+		return nil, err
+	}
+	d.state[ref], d.cache[ref] = objectDone, v
+	return v, nil
+}
+
+func (d *plistDecoder) decode(ref uint64, depth int) (interface{}, error) {
+	off := d.offsets[ref]
+	if off >= uint64(len(d.data)) {
+		return nil, ErrPlist
+	}
+	marker := d.data[off]
+	switch marker >> 4 {
+	case 0x0:
+		switch marker {
+		case 0x00:
+			return nil, nil
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+	case 0x1:
+		size := uint64(1) << (marker & 0x0f)
+		b, err := d.bytes(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		switch size {
+		case 1, 2, 4:
+			return int64(readUint(b, int(size))), nil
+		case 8:
+			return int64(readUint(b, 8)), nil
+		case 16:
+			// Unsigned 64-bit values are stored in 16 bytes.
+			v := readUint(b[8:], 8)
+			if v > math.MaxInt64 {
+				return v, nil
+			}
+			return int64(v), nil
+		}
+	case 0x2:
+		size := uint64(1) << (marker & 0x0f)
+		b, err := d.bytes(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		switch size {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+	case 0x3:
+		if marker != 0x33 {
+			break
+		}
+		b, err := d.bytes(off+1, 8)
+		if err != nil {
+			return nil, err
+		}
+		secs := math.Float64frombits(binary.BigEndian.Uint64(b))
+		return appleEpoch.Add(time.Duration(secs * float64(time.Second))), nil
+	case 0x4, 0x5, 0x6:
+		n, body, err := d.count(off)
+		if err != nil {
+			return nil, err
+		}
+		if marker>>4 == 0x6 {
+			if n > uint64(len(d.data)) {
+				return nil, ErrPlist
+			}
+			b, err := d.bytes(body, 2*n)
+			if err != nil {
+				return nil, err
+			}
+			u := make([]uint16, n)
+			for i := range u {
+				u[i] = binary.BigEndian.Uint16(b[2*i:])
+			}
+			return string(utf16.Decode(u)), nil
+		}
+		b, err := d.bytes(body, n)
+		if err != nil {
+			return nil, err
+		}
+		if marker>>4 == 0x4 {
+			return append([]byte{}, b...), nil
+		}
+		return string(b), nil
+	case 0x8:
+		size := uint64(marker&0x0f) + 1
+		b, err := d.bytes(off+1, size)
+		if err != nil || size > 8 {
+			return nil, ErrPlist
+		}
+		return UID(readUint(b, int(size))), nil
+	case 0xa:
+		n, body, err := d.count(off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := d.refs(body, n)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, n)
+		for i, r := range refs {
+			if array[i], err = d.object(r, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case 0xd:
+		n, body, err := d.count(off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := d.refs(body, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := d.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, ErrPlist
+			}
+			if dict[key], err = d.object(refs[n+i], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+	return nil, ErrPlist
+}
+
+type plistEncoder struct {
+	objects [][]byte
+	refs    [][]int // references of each object, patched in at the end
+}
+
+// Marshal encodes v as a binary property list. Dictionary keys are written
+// in sorted order, so the output is deterministic.
+func Marshal(v interface{}) ([]byte, error) {
+	e := &plistEncoder{}
+	if _, err := e.add(v); err != nil {
+		return nil, err
+	}
+	refSize := sizeFor(uint64(len(e.objects)))
+
+	out := []byte(bplistMagic)
+	offsets := make([]uint64, len(e.objects))
+	for i, obj := range e.objects {
+		offsets[i] = uint64(len(out))
+		out = append(out, obj...)
+		for _, r := range e.refs[i] {
+			out = appendUint(out, uint64(r), refSize)
+		}
+	}
+	tableOff := uint64(len(out))
+	offSize := sizeFor(tableOff)
+	for _, off := range offsets {
+		out = appendUint(out, off, offSize)
+	}
+	var trailer [32]byte
+	trailer[6] = byte(offSize)
+	trailer[7] = byte(refSize)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(e.objects)))
+	binary.BigEndian.PutUint64(trailer[24:], tableOff)
+	return append(out, trailer[:]...), nil
+}
+
+// sizeFor returns the number of bytes needed for values up to max.
+func sizeFor(max uint64) int {
+	switch {
+	case max <= math.MaxUint8:
+		return 1
+	case max <= math.MaxUint16:
+		return 2
+	case max <= math.MaxUint32:
+		return 4
+	}
+	return 8
+}
+
+func appendUint(b []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*uint(i))))
+	}
+	return b
+}
+
+// header returns the marker of a variable-length object of kind with n
+// elements.
+func header(kind byte, n int) []byte {
+	if n < 0x0f {
+		return []byte{kind<<4 | byte(n)}
+	}
+	return appendInt([]byte{kind<<4 | 0x0f}, int64(n))
+}
+
+func appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendUint(append(b, 0x13), uint64(v), 8)
+	}
+	size := sizeFor(uint64(v))
+	marker := byte(0x10)
+	for n := size; n > 1; n >>= 1 {
+		marker++
+	}
+	return appendUint(append(b, marker), uint64(v), size)
+}
+
+// add appends v and everything it contains and returns its reference.
+func (e *plistEncoder) add(v interface{}) (int, error) {
+	ref := len(e.objects)
+	e.objects = append(e.objects, nil)
+	e.refs = append(e.refs, nil)
+	var obj []byte
+	switch v := v.(type) {
+	case nil:
+		obj = []byte{0x00}
+	case bool:
+		obj = []byte{0x08}
+		if v {
+			obj[0] = 0x09
+		}
+	case int:
+		obj = appendInt(nil, int64(v))
+	case int8:
+		obj = appendInt(nil, int64(v))
+	case int16:
+		obj = appendInt(nil, int64(v))
+	case int32:
+		obj = appendInt(nil, int64(v))
+	case int64:
+		obj = appendInt(nil, v)
+	case uint8:
+		obj = appendInt(nil, int64(v))
+	case uint16:
+		obj = appendInt(nil, int64(v))
+	case uint32:
+		obj = appendInt(nil, int64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			obj = appendUint(append([]byte{0x14}, make([]byte, 8)...), v, 8)
+		} else {
+			obj = appendInt(nil, int64(v))
+		}
+	case float32:
+		obj = appendUint([]byte{0x22}, uint64(math.Float32bits(v)), 4)
+	case float64:
+		obj = appendUint([]byte{0x23}, math.Float64bits(v), 8)
+	case time.Time:
+		secs := v.Sub(appleEpoch).Seconds()
+		obj = appendUint([]byte{0x33}, math.Float64bits(secs), 8)
+	case []byte:
+		obj = append(header(0x4, len(v)), v...)
+	case string:
+		obj = encodeString(v)
+	case UID:
+		size := sizeFor(uint64(v))
+		obj = appendUint([]byte{0x80 | byte(size-1)}, uint64(v), size)
+	case []string:
+		values := make([]interface{}, len(v))
+		for i, s := range v {
+			values[i] = s
+		}
+		return ref, e.container(ref, header(0xa, len(v)), values)
+	case []interface{}:
+		return ref, e.container(ref, header(0xa, len(v)), v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, 0, 2*len(v))
+		for _, k := range keys {
+			values = append(values, k)
+		}
+		for _, k := range keys {
+			values = append(values, v[k])
+		}
+		return ref, e.container(ref, header(0xd, len(v)), values)
+	default:
+		return 0, fmt.Errorf("macos: cannot encode %T in a property list", v)
+	}
+	e.objects[ref] = obj
+	return ref, nil
+}
+
+func (e *plistEncoder) container(ref int, hdr []byte, values []interface{}) error {
+	e.objects[ref] = hdr
+	refs := make([]int, len(values))
+	for i, v := range values {
+		r, err := e.add(v)
+		if err != nil {
+			return err
+		}
+		refs[i] = r
+	}
+	e.refs[ref] = refs
+	return nil
+}
+
+// encodeString writes ASCII strings as such and all others as UTF-16.
+func encodeString(s string) []byte {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return append(header(0x5, len(s)), s...)
+	}
+	u := utf16.Encode([]rune(s))
+	b := header(0x6, len(u))
+	for _, c := range u {
+		b = appendUint(b, uint64(c), 2)
+	}
+	return b
+}
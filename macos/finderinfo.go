@@ -0,0 +1,97 @@
+package macos
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Finder flags.
+const (
+	FlagIsOnDesk      = 0x0001
+	FlagColor         = 0x000e
+	FlagIsShared      = 0x0040
+	FlagHasNoINITs    = 0x0080
+	FlagHasBeenInited = 0x0100
+	FlagHasCustomIcon = 0x0400
+	FlagIsStationery  = 0x0800
+	FlagNameLocked    = 0x1000
+	FlagHasBundle     = 0x2000
+	FlagIsInvisible   = 0x4000
+	FlagIsAlias       = 0x8000
+)
+
+const (
+	finderInfoSize      = 32
+	finderFlagsOffset   = 8
+	extendedFlagsOffset = 24
+)
+
+// ErrFinderInfo is returned for values that are not 32 bytes long.
+var ErrFinderInfo = errors.New("macos: Finder info must be 32 bytes")
+
+// FinderInfo is a com.apple.FinderInfo value. The fields are those of the
+// FileInfo and ExtendedFileInfo records of files; for directories, Type
+// and Creator hold the window rectangle. Fields without accessors are
+// preserved.
+type FinderInfo struct {
+	raw [finderInfoSize]byte
+}
+
+// DecodeFinderInfo decodes a Finder info value.
+func DecodeFinderInfo(data []byte) (*FinderInfo, error) {
+	if len(data) != finderInfoSize {
+		return nil, ErrFinderInfo
+	}
+	fi := &FinderInfo{}
+	copy(fi.raw[:], data)
+	return fi, nil
+}
+
+// Encode returns the 32 byte value.
+func (fi *FinderInfo) Encode() []byte {
+	return append([]byte{}, fi.raw[:]...)
+}
+
+// Type returns the four-character file type, such as "TEXT".
+func (fi *FinderInfo) Type() string { return string(fi.raw[0:4]) }
+
+// Creator returns the four-character creator code, such as "ttxt".
+func (fi *FinderInfo) Creator() string { return string(fi.raw[4:8]) }
+
+// SetType sets the file type and creator code. Longer codes are truncated
+// and shorter ones padded with spaces.
+func (fi *FinderInfo) SetType(typ, creator string) {
+	copy(fi.raw[0:4], pad4(typ))
+	copy(fi.raw[4:8], pad4(creator))
+}
+
+func pad4(s string) string {
+	return (s + "    ")[:4]
+}
+
+// Flags returns the Finder flags.
+func (fi *FinderInfo) Flags() uint16 {
+	return binary.BigEndian.Uint16(fi.raw[finderFlagsOffset:])
+}
+
+// SetFlags sets the Finder flags.
+func (fi *FinderInfo) SetFlags(flags uint16) {
+	binary.BigEndian.PutUint16(fi.raw[finderFlagsOffset:], flags)
+}
+
+// Label returns the color label, one of the Color constants. Labels
+// predate tags; the Finder keeps the label in sync with the first colored
+// tag.
+func (fi *FinderInfo) Label() int {
+	return int(fi.Flags()&FlagColor) >> 1
+}
+
+// SetLabel sets the color label.
+func (fi *FinderInfo) SetLabel(color int) {
+	fi.SetFlags(fi.Flags()&^FlagColor | uint16(color<<1)&FlagColor)
+}
+
+// ExtendedFlags returns the extended Finder flags.
+func (fi *FinderInfo) ExtendedFlags() uint16 {
+	return binary.BigEndian.Uint16(fi.raw[extendedFlagsOffset:])
+}
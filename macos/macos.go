@@ -0,0 +1,110 @@
+/*
+Package macos decodes and encodes the extended attributes macOS attaches to
+files, which survive on Linux storage when files arrive over SMB, in
+archives or as AppleDouble files:
+
+	com.apple.quarantine                    download quarantine record
+	com.apple.metadata:kMDItemWhereFroms    download origin URLs
+	com.apple.metadata:_kMDItemUserTags     Finder tags
+	com.apple.FinderInfo                    Finder flags, type and creator
+
+The metadata attributes are binary property lists, which Unmarshal and
+Marshal read and write. The Get functions read the attributes with
+xattr.Get under their name on the current platform, for example
+"user.com.apple.quarantine" on Linux.
+*/
+package macos
+
+import (
+	"github.com/pkg/xattr"
+)
+
+// Canonical names of the attributes, see xattr.CanonicalName.
+const (
+	QuarantineAttr = "user.com.apple.quarantine"
+	WhereFromsAttr = "user.com.apple.metadata:kMDItemWhereFroms"
+	UserTagsAttr   = "user.com.apple.metadata:_kMDItemUserTags"
+	FinderInfoAttr = "user.com.apple.FinderInfo"
+)
+
+// get reads the attribute with the canonical name from path.
+func get(path, name string) ([]byte, error) {
+	native, err := xattr.Native(name)
+	if err != nil {
+		return nil, err
+	}
+	return xattr.Get(path, native)
+}
+
+// set writes the attribute with the canonical name to path.
+func set(path, name string, data []byte) error {
+	native, err := xattr.Native(name)
+	if err != nil {
+		return err
+	}
+	return xattr.Set(path, native, data)
+}
+
+// GetQuarantine returns the quarantine record of path.
+func GetQuarantine(path string) (*Quarantine, error) {
+	data, err := get(path, QuarantineAttr)
+	if err != nil {
+		return nil, err
+	}
+	return ParseQuarantine(data)
+}
+
+// SetQuarantine sets the quarantine record of path.
+func SetQuarantine(path string, q *Quarantine) error {
+	return set(path, QuarantineAttr, []byte(q.String()))
+}
+
+// GetWhereFroms returns the URLs path was downloaded from.
+func GetWhereFroms(path string) ([]string, error) {
+	data, err := get(path, WhereFromsAttr)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeWhereFroms(data)
+}
+
+// SetWhereFroms sets the URLs path was downloaded from.
+func SetWhereFroms(path string, urls []string) error {
+	data, err := EncodeWhereFroms(urls)
+	if err != nil {
+		return err
+	}
+	return set(path, WhereFromsAttr, data)
+}
+
+// GetUserTags returns the Finder tags of path.
+func GetUserTags(path string) ([]Tag, error) {
+	data, err := get(path, UserTagsAttr)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeUserTags(data)
+}
+
+// SetUserTags sets the Finder tags of path.
+func SetUserTags(path string, tags []Tag) error {
+	data, err := EncodeUserTags(tags)
+	if err != nil {
+		return err
+	}
+	return set(path, UserTagsAttr, data)
+}
+
+// GetFinderInfo returns the Finder info of path.
+func GetFinderInfo(path string) (*FinderInfo, error) {
+	data, err := get(path, FinderInfoAttr)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeFinderInfo(data)
+}
+
+// SetFinderInfo sets the Finder info of path.
+func SetFinderInfo(path string, fi *FinderInfo) error {
+	return set(path, FinderInfoAttr, fi.Encode())
+}
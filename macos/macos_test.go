@@ -0,0 +1,205 @@
+package macos
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestMarshalLayout(t *testing.T) {
+	want := []byte("bplist00" +
+		"\xa1\x01" + "\x51a" + // array with one reference, string "a"
+		"\x08\x0a" + // offset table
+		"\x00\x00\x00\x00\x00\x00\x01\x01" +
+		"\x00\x00\x00\x00\x00\x00\x00\x02" +
+		"\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x00\x00\x00\x00\x00\x00\x00\x0c")
+	got, err := Marshal([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Marshal = %q, want %q", got, want)
+	}
+	v, err := Unmarshal(want)
+	if err != nil || !reflect.DeepEqual(v, []interface{}{"a"}) {
+		t.Errorf("Unmarshal = %v, %v", v, err)
+	}
+}
+
+func TestPlistRoundTrip(t *testing.T) {
+	date := time.Date(2020, 7, 26, 12, 30, 0, 0, time.UTC)
+	long := strings.Repeat("x", 300)
+	in := map[string]interface{}{
+		"null":    nil,
+		"true":    true,
+		"false":   false,
+		"small":   int64(7),
+		"big":     int64(1) << 40,
+		"neg":     int64(-3),
+		"huge":    uint64(1) << 63,
+		"real":    2.5,
+		"date":    date,
+		"data":    []byte{0, 1, 2},
+		"ascii":   long,
+		"unicode": "Grüße ✓",
+		"uid":     UID(300),
+		"array":   []interface{}{"a", int64(1), []interface{}{}},
+		"dict":    map[string]interface{}{},
+	}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip:\n got %v\nwant %v", out, in)
+	}
+
+	if _, err := Marshal(struct{}{}); err == nil {
+		t.Error("Marshal of struct succeeded")
+	}
+	for i := 0; i < len(data); i++ {
+		// Truncated or corrupted input must fail cleanly.
+		Unmarshal(data[:i])
+		bad := append([]byte{}, data...)
+		bad[i] ^= 0xff
+		Unmarshal(bad)
+	}
+}
+
+// plist builds a binary list from objects, each at most 255 bytes, with
+// one-byte offsets and references.
+func plist(objects ...[]byte) []byte {
+	data := []byte("bplist00")
+	var offsets []byte
+	for _, o := range objects {
+		offsets = append(offsets, byte(len(data)))
+		data = append(data, o...)
+	}
+	table := len(data)
+	data = append(data, offsets...)
+	trailer := make([]byte, 32)
+	trailer[6], trailer[7] = 1, 1
+	trailer[15] = byte(len(objects))
+	trailer[31] = byte(table)
+	return append(data, trailer...)
+}
+
+func TestPlistSharedRefs(t *testing.T) {
+	// Each array references the next one twice; decoding every reference
+	// separately would take 2^60 steps.
+	const n = 60
+	var objects [][]byte
+	for i := 1; i < n; i++ {
+		objects = append(objects, []byte{0xa2, byte(i), byte(i)})
+	}
+	objects = append(objects, []byte{0x08})
+	v, err := Unmarshal(plist(objects...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < n; i++ {
+		a, ok := v.([]interface{})
+		if !ok || len(a) != 2 {
+			t.Fatalf("level %d: %v", i, v)
+		}
+		v = a[1]
+	}
+	if v != false {
+		t.Errorf("leaf = %v", v)
+	}
+
+	// An array containing itself, directly or through a dictionary.
+	for _, objects := range [][][]byte{
+		{{0xa1, 0}},
+		{{0xa1, 1}, {0xd1, 2, 0}, {0x51, 'k'}},
+	} {
+		if _, err := Unmarshal(plist(objects...)); err != ErrPlist {
+			t.Errorf("cycle %v: err = %v", objects, err)
+		}
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	q, err := ParseQuarantine([]byte("0083;5f1d6b2c;Safari;D3A5C2B4-1F0E-4C9B-9E6A-0123456789AB"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Flags != 0x83 || q.Flags&QuarantineDownload == 0 || q.Agent != "Safari" ||
+		q.EventID != "D3A5C2B4-1F0E-4C9B-9E6A-0123456789AB" || q.Time.Unix() != 0x5f1d6b2c {
+		t.Errorf("ParseQuarantine = %+v", q)
+	}
+	if s := q.String(); s != "0083;5f1d6b2c;Safari;D3A5C2B4-1F0E-4C9B-9E6A-0123456789AB" {
+		t.Errorf("String = %q", s)
+	}
+	if q, err := ParseQuarantine([]byte("0001")); err != nil || q.Flags != 1 || !q.Time.IsZero() {
+		t.Errorf("short record: %+v, %v", q, err)
+	}
+	if _, err := ParseQuarantine([]byte("junk;1")); err != ErrQuarantine {
+		t.Errorf("ParseQuarantine(junk) = %v", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	tags := []Tag{{"Red", ColorRed}, {"Project X", ColorNone}}
+	data, err := EncodeUserTags(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := Unmarshal(data)
+	if !reflect.DeepEqual(v, []interface{}{"Red\n6", "Project X"}) {
+		t.Errorf("encoded tags = %q", v)
+	}
+	if got, err := DecodeUserTags(data); err != nil || !reflect.DeepEqual(got, tags) {
+		t.Errorf("DecodeUserTags = %v, %v", got, err)
+	}
+
+	data, _ = Marshal(map[string]interface{}{})
+	if _, err := DecodeWhereFroms(data); err != ErrMetadata {
+		t.Errorf("DecodeWhereFroms of dict = %v", err)
+	}
+}
+
+func TestFinderInfo(t *testing.T) {
+	if _, err := DecodeFinderInfo(make([]byte, 31)); err != ErrFinderInfo {
+		t.Errorf("DecodeFinderInfo of short value = %v", err)
+	}
+	fi, _ := DecodeFinderInfo(make([]byte, 32))
+	fi.SetType("TEXT", "ttx")
+	fi.SetFlags(FlagIsInvisible)
+	fi.SetLabel(ColorBlue)
+	if fi.Type() != "TEXT" || fi.Creator() != "ttx " || fi.Label() != ColorBlue || fi.Flags() != FlagIsInvisible|ColorBlue<<1 {
+		t.Errorf("FinderInfo = % x", fi.Encode())
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	f, err := ioutil.TempFile("", "xattr-macos-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	urls := []string{"https://example.com/file.zip", "https://example.com/"}
+	xattrtest.Check(t, SetWhereFroms(f.Name(), urls))
+	if got, err := GetWhereFroms(f.Name()); err != nil || !reflect.DeepEqual(got, urls) {
+		t.Errorf("GetWhereFroms = %q, %v", got, err)
+	}
+	q := &Quarantine{Flags: QuarantineDownload, Time: time.Unix(1600000000, 0).UTC(), Agent: "curl"}
+	if err := SetQuarantine(f.Name(), q); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := GetQuarantine(f.Name()); err != nil || !reflect.DeepEqual(got, q) {
+		t.Errorf("GetQuarantine = %+v, %v", got, err)
+	}
+}
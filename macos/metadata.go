@@ -0,0 +1,95 @@
+package macos
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrMetadata is returned for property lists that do not have the expected
+// structure.
+var ErrMetadata = errors.New("macos: unexpected metadata value")
+
+// Finder tag colors.
+const (
+	ColorNone = iota
+	ColorGray
+	ColorGreen
+	ColorPurple
+	ColorBlue
+	ColorYellow
+	ColorRed
+	ColorOrange
+)
+
+// Tag is a Finder tag.
+type Tag struct {
+	Name  string
+	Color int
+}
+
+// String returns the tag as it is stored: the name, followed by a newline
+// and the color if there is one.
+func (t Tag) String() string {
+	if t.Color == ColorNone {
+		return t.Name
+	}
+	return t.Name + "\n" + strconv.Itoa(t.Color)
+}
+
+// decodeStrings decodes a property list that holds an array of strings.
+func decodeStrings(data []byte) ([]string, error) {
+	v, err := Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	array, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrMetadata
+	}
+	strs := make([]string, len(array))
+	for i, e := range array {
+		if strs[i], ok = e.(string); !ok {
+			return nil, ErrMetadata
+		}
+	}
+	return strs, nil
+}
+
+// DecodeWhereFroms decodes a kMDItemWhereFroms value, which usually holds
+// the download URL followed by the URL of the referring page.
+func DecodeWhereFroms(data []byte) ([]string, error) {
+	return decodeStrings(data)
+}
+
+// EncodeWhereFroms encodes a kMDItemWhereFroms value.
+func EncodeWhereFroms(urls []string) ([]byte, error) {
+	return Marshal(urls)
+}
+
+// DecodeUserTags decodes a _kMDItemUserTags value.
+func DecodeUserTags(data []byte) ([]Tag, error) {
+	strs, err := decodeStrings(data)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]Tag, len(strs))
+	for i, s := range strs {
+		tags[i].Name = s
+		if j := strings.LastIndexByte(s, '\n'); j >= 0 {
+			if color, err := strconv.Atoi(s[j+1:]); err == nil {
+				tags[i] = Tag{s[:j], color}
+			}
+		}
+	}
+	return tags, nil
+}
+
+// EncodeUserTags encodes a _kMDItemUserTags value.
+func EncodeUserTags(tags []Tag) ([]byte, error) {
+	strs := make([]string, len(tags))
+	for i, t := range tags {
+		strs[i] = t.String()
+	}
+	return Marshal(strs)
+}
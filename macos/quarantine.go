@@ -0,0 +1,67 @@
+package macos
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quarantine flags.
+const (
+	QuarantineDownload     = 0x0001
+	QuarantineSandbox      = 0x0002
+	QuarantineHard         = 0x0004
+	QuarantineUserApproved = 0x0040
+)
+
+// ErrQuarantine is returned for values that are not quarantine records.
+var ErrQuarantine = errors.New("macos: invalid quarantine record")
+
+// Quarantine is a com.apple.quarantine record, which macOS writes as
+// "flags;timestamp;agent;event id" with hexadecimal flags and timestamp.
+type Quarantine struct {
+	Flags uint16
+	// Time is when the file was quarantined, at second resolution.
+	Time time.Time
+	// Agent is the name of the application that downloaded the file.
+	Agent string
+	// EventID is the UUID of the entry in the quarantine events database.
+	// It may be empty.
+	EventID string
+}
+
+// ParseQuarantine decodes a quarantine record. Trailing fields may be
+// missing.
+func ParseQuarantine(data []byte) (*Quarantine, error) {
+	fields := strings.SplitN(strings.TrimRight(string(data), "\x00\n"), ";", 4)
+	flags, err := strconv.ParseUint(fields[0], 16, 16)
+	if err != nil {
+		return nil, ErrQuarantine
+	}
+	q := &Quarantine{Flags: uint16(flags)}
+	if len(fields) > 1 && fields[1] != "" {
+		secs, err := strconv.ParseInt(fields[1], 16, 64)
+		if err != nil {
+			return nil, ErrQuarantine
+		}
+		q.Time = time.Unix(secs, 0).UTC()
+	}
+	if len(fields) > 2 {
+		q.Agent = fields[2]
+	}
+	if len(fields) > 3 {
+		q.EventID = fields[3]
+	}
+	return q, nil
+}
+
+// String returns the record in the format macOS writes.
+func (q *Quarantine) String() string {
+	var secs int64
+	if !q.Time.IsZero() {
+		secs = q.Time.Unix()
+	}
+	return fmt.Sprintf("%04x;%08x;%s;%s", q.Flags, secs, q.Agent, q.EventID)
+}
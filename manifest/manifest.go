@@ -0,0 +1,255 @@
+/*
+Package manifest records the extended attributes of a file tree and verifies
+that they have not changed since.
+
+A manifest lists every file and directory below a root together with its
+extended attributes. In digest mode, values are replaced by their SHA-256
+digest and size, which keeps the manifest small while still detecting any
+change to a value.
+
+	m, err := manifest.Generate("/release", manifest.Options{Digest: true})
+	...
+	changes, err := manifest.Verify("/release", m)
+*/
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// Version is the manifest format version written by this package.
+const Version = 1
+
+// Manifest is the recorded state of a file tree.
+type Manifest struct {
+	Version int `json:"version"`
+	// Digest is true if attribute values were recorded as digests.
+	Digest bool `json:"digest,omitempty"`
+	// Prefixes are the name prefixes of the recorded attributes. If empty,
+	// all attributes were recorded.
+	Prefixes []string `json:"prefixes,omitempty"`
+	Files    []File   `json:"files"`
+}
+
+// File records the extended attributes of a single file or directory.
+type File struct {
+	// Path is slash-separated and relative to the root of the tree.
+	Path  string `json:"path"`
+	Attrs []Attr `json:"attrs,omitempty"`
+}
+
+// Attr records a single extended attribute. Either Value or Digest is set,
+// depending on the mode the manifest was generated in.
+type Attr struct {
+	Name   string `json:"name"`
+	Value  []byte `json:"value,omitempty"`
+	Digest string `json:"sha256,omitempty"`
+	Size   int    `json:"size"`
+}
+
+// Options control which attributes are recorded and how.
+type Options struct {
+	// Digest records the SHA-256 digest of every value instead of the value.
+	Digest bool
+	// Prefixes selects the attributes to record by name prefix, such as
+	// "user.". If empty, all attributes are recorded.
+	Prefixes []string
+}
+
+// Generate walks the tree rooted at root and records the extended
+// attributes of every file and directory. Symlinks are not followed. Files
+// that cannot be read are left out: Generate goes on and returns the
+// manifest of the rest together with an error listing every failure.
+func Generate(root string, opts Options) (*Manifest, error) {
+	m := &Manifest{Version: Version, Digest: opts.Digest, Prefixes: opts.Prefixes, Files: []File{}}
+	match := prefixMatch(opts.Prefixes)
+	var errs walk.Errors
+	err := walk.All(root, &errs, func(path, rel string, info os.FileInfo) error {
+		attrs, err := walk.Read(path, match)
+		if err != nil {
+			errs.Add(err)
+			return nil
+		}
+		m.Files = append(m.Files, File{Path: rel, Attrs: record(attrs, opts.Digest)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortFiles(m.Files)
+	return m, errs.Err()
+}
+
+// prefixMatch returns a function that matches names starting with one of
+// prefixes, or nil if there are none.
+func prefixMatch(prefixes []string) func(name string) bool {
+	if len(prefixes) == 0 {
+		return nil
+	}
+	return func(name string) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(name, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// sortFiles sorts files by path. The walk order is not quite the same, since
+// "a/b" is visited before "a.txt".
+func sortFiles(files []File) {
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+}
+
+func record(attrs []walk.Attr, digest bool) []Attr {
+	out := make([]Attr, 0, len(attrs))
+	for _, a := range attrs {
+		r := Attr{Name: a.Name, Size: len(a.Value)}
+		if digest {
+			sum := sha256.Sum256(a.Value)
+			r.Digest = hex.EncodeToString(sum[:])
+		} else {
+			r.Value = a.Value
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Read decodes a manifest previously written with WriteTo.
+func Read(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("manifest: unsupported version %d", m.Version)
+	}
+	sortFiles(m.Files)
+	for _, f := range m.Files {
+		sort.SliceStable(f.Attrs, func(i, j int) bool { return f.Attrs[i].Name < f.Attrs[j].Name })
+	}
+	return &m, nil
+}
+
+// WriteTo writes the manifest to w as indented JSON.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// ChangeKind describes how a file or attribute differs from the manifest.
+type ChangeKind int
+
+const (
+	AttrAdded ChangeKind = iota
+	AttrRemoved
+	AttrModified
+	FileMissing
+	FileExtra
+)
+
+var changeKindNames = [...]string{
+	AttrAdded:    "added",
+	AttrRemoved:  "removed",
+	AttrModified: "modified",
+	FileMissing:  "missing",
+	FileExtra:    "extra",
+}
+
+func (k ChangeKind) String() string {
+	if int(k) < len(changeKindNames) {
+		return changeKindNames[k]
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a single difference between a tree and its manifest. Name is
+// empty for FileMissing and FileExtra.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	Name string
+}
+
+func (c Change) String() string {
+	if c.Name == "" {
+		return c.Kind.String() + " " + c.Path
+	}
+	return c.Kind.String() + " " + c.Path + " " + c.Name
+}
+
+// Verify walks the tree rooted at root and reports every difference from m,
+// comparing the attributes selected by m.Prefixes. Changes are ordered by path, then by attribute name. An empty result
+// means the tree matches the manifest. Files that cannot be read are
+// reported as missing, and the error lists the failures.
+func Verify(root string, m *Manifest) ([]Change, error) {
+	current, err := Generate(root, Options{Digest: m.Digest, Prefixes: m.Prefixes})
+	if current == nil {
+		return nil, err
+	}
+	return Compare(m, current), err
+}
+
+// Compare reports the differences between the manifests want and have, both
+// of which must be sorted by path as Generate produces them.
+func Compare(want, have *Manifest) []Change {
+	var changes []Change
+	i, j := 0, 0
+	for i < len(want.Files) || j < len(have.Files) {
+		switch {
+		case j == len(have.Files) || i < len(want.Files) && want.Files[i].Path < have.Files[j].Path:
+			changes = append(changes, Change{Kind: FileMissing, Path: want.Files[i].Path})
+			i++
+		case i == len(want.Files) || have.Files[j].Path < want.Files[i].Path:
+			changes = append(changes, Change{Kind: FileExtra, Path: have.Files[j].Path})
+			j++
+		default:
+			changes = compareAttrs(changes, want.Files[i], have.Files[j])
+			i++
+			j++
+		}
+	}
+	return changes
+}
+
+func compareAttrs(changes []Change, want, have File) []Change {
+	path := want.Path
+	i, j := 0, 0
+	for i < len(want.Attrs) || j < len(have.Attrs) {
+		switch {
+		case j == len(have.Attrs) || i < len(want.Attrs) && want.Attrs[i].Name < have.Attrs[j].Name:
+			changes = append(changes, Change{AttrRemoved, path, want.Attrs[i].Name})
+			i++
+		case i == len(want.Attrs) || have.Attrs[j].Name < want.Attrs[i].Name:
+			changes = append(changes, Change{AttrAdded, path, have.Attrs[j].Name})
+			j++
+		default:
+			if !sameValue(want.Attrs[i], have.Attrs[j]) {
+				changes = append(changes, Change{AttrModified, path, want.Attrs[i].Name})
+			}
+			i++
+			j++
+		}
+	}
+	return changes
+}
+
+func sameValue(a, b Attr) bool {
+	return a.Size == b.Size && a.Digest == b.Digest && bytes.Equal(a.Value, b.Value)
+}
@@ -0,0 +1,133 @@
+package manifest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func setup(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "xattr-manifest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "a/b", "c"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	xattrtest.Set(t, filepath.Join(dir, "a.txt"), "user.one", "1")
+	xattrtest.Set(t, filepath.Join(dir, "a/b"), "user.two", "2")
+	xattrtest.Set(t, filepath.Join(dir, "c"), "user.three", "3")
+	return dir
+}
+
+func TestVerify(t *testing.T) {
+	for _, digest := range []bool{false, true} {
+		dir := setup(t)
+		defer os.RemoveAll(dir)
+
+		m, err := Generate(dir, Options{Digest: digest})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := m.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if m, err = Read(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		changes, err := Verify(dir, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 0 {
+			t.Fatalf("unchanged tree reported changes: %v", changes)
+		}
+
+		xattrtest.Set(t, filepath.Join(dir, "a.txt"), "user.one", "one")
+		xattrtest.Set(t, filepath.Join(dir, "a.txt"), "user.new", "")
+		if err := xattr.Remove(filepath.Join(dir, "a/b"), "user.two"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(dir, "c")); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "d"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		changes, err = Verify(dir, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []Change{
+			{AttrAdded, "a.txt", "user.new"},
+			{AttrModified, "a.txt", "user.one"},
+			{AttrRemoved, "a/b", "user.two"},
+			{FileMissing, "c", ""},
+			{FileExtra, "d", ""},
+		}
+		if !reflect.DeepEqual(changes, want) {
+			t.Errorf("digest=%v: wrong changes\nhave %v\nwant %v", digest, changes, want)
+		}
+	}
+}
+
+func TestDigestOmitsValues(t *testing.T) {
+	dir := setup(t)
+	defer os.RemoveAll(dir)
+
+	m, err := Generate(dir, Options{Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range m.Files {
+		for _, a := range f.Attrs {
+			if a.Value != nil || len(a.Digest) != 64 {
+				t.Errorf("%s %s: want digest only, have %+v", f.Path, a.Name, a)
+			}
+		}
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	dir := setup(t)
+	defer os.RemoveAll(dir)
+
+	m, err := Generate(dir, Options{Prefixes: []string{"user.one", "user.two"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = Read(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the recorded prefixes are compared.
+	xattrtest.Set(t, filepath.Join(dir, "c"), "user.three", "changed")
+	xattrtest.Set(t, filepath.Join(dir, "a/b"), "user.two", "changed")
+	changes, err := Verify(dir, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{{AttrModified, "a/b", "user.two"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("have %v, want %v", changes, want)
+	}
+}
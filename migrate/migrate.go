@@ -0,0 +1,192 @@
+/*
+Package migrate renames extended attribute keys across a file tree.
+
+A Mapping lists old names and the names that replace them. Tree applies it
+to every file and directory using xattr.LRename, which never leaves a file
+without one of the two names. Progress is recorded in a journal file so
+that an interrupted migration can be resumed where it stopped.
+*/
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// Mapping maps old attribute names to new ones.
+type Mapping map[string]string
+
+// ParseMapping reads a mapping table with one "old new" pair per line.
+// Blank lines and lines starting with # are ignored.
+func ParseMapping(r io.Reader) (Mapping, error) {
+	m := Mapping{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("migrate: line %d: want \"old new\", have %q", n, line)
+		}
+		m[f[0]] = f[1]
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return m, m.Validate()
+}
+
+// Validate checks that no new name is also an old name, as the result of
+// such chains would depend on the order of the renames.
+func (m Mapping) Validate() error {
+	for oldName, newName := range m {
+		if oldName == newName {
+			return fmt.Errorf("migrate: %q is mapped to itself", oldName)
+		}
+		if _, ok := m[newName]; ok {
+			return fmt.Errorf("migrate: %q is both an old and a new name", newName)
+		}
+	}
+	return nil
+}
+
+// Options control a migration.
+type Options struct {
+	// Replace overwrites existing attributes of the new name. Otherwise a
+	// file with both names stops the migration with EEXIST, unless both
+	// have the same value, which is what an interrupted rename leaves
+	// behind.
+	Replace bool
+	// Journal is the path of a file recording the files that have been
+	// migrated. Files listed in an existing journal are skipped.
+	Journal string
+}
+
+// Stats reports the work done by Tree.
+type Stats struct {
+	Files   int // files and directories visited
+	Skipped int // files skipped because the journal lists them
+	Renamed int // attributes renamed
+}
+
+// Tree applies m to every file and directory in the tree rooted at root
+// without following symlinks. It stops at the first error; running it again
+// with the same journal resumes the migration.
+func Tree(root string, m Mapping, opts Options) (Stats, error) {
+	var st Stats
+	if err := m.Validate(); err != nil {
+		return st, err
+	}
+	done := map[string]bool{}
+	var journal *os.File
+	if opts.Journal != "" {
+		var err error
+		if done, err = readJournal(opts.Journal); err != nil {
+			return st, err
+		}
+		journal, err = os.OpenFile(opts.Journal, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return st, err
+		}
+		defer journal.Close()
+	}
+	flags := 0
+	if opts.Replace {
+		flags = xattr.XATTR_REPLACE
+	}
+
+	err := walk.Walk(root, func(path, rel string, info os.FileInfo) error {
+		st.Files++
+		if done[rel] {
+			st.Skipped++
+			return nil
+		}
+		n, err := File(path, m, flags)
+		st.Renamed += n
+		if err != nil {
+			return err
+		}
+		if journal != nil {
+			if _, err := fmt.Fprintln(journal, strconv.Quote(rel)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return st, err
+}
+
+// File applies m to the attributes of path without following a symlink at
+// the end of the path and returns the number of attributes renamed. flags
+// are passed to xattr.LRename, except that XATTR_REPLACE only takes effect
+// where the new name already exists: attributes whose new name is missing
+// are renamed as usual.
+func File(path string, m Mapping, flags int) (int, error) {
+	names, err := walk.List(path)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, oldName := range names {
+		newName, ok := m[oldName]
+		if !ok {
+			continue
+		}
+		err := xattr.LRename(path, oldName, newName, flags&^xattr.XATTR_REPLACE)
+		if errors.Is(err, syscall.EEXIST) {
+			switch {
+			case sameValue(path, oldName, newName):
+				err = xattr.LRemove(path, oldName)
+			case flags&xattr.XATTR_REPLACE != 0:
+				err = xattr.LRename(path, oldName, newName, flags)
+			}
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func sameValue(path, a, b string) bool {
+	va, err := xattr.LGet(path, a)
+	if err != nil {
+		return false
+	}
+	vb, err := xattr.LGet(path, b)
+	return err == nil && bytes.Equal(va, vb)
+}
+
+// readJournal returns the set of paths listed in the journal, or an empty
+// set if it does not exist. A partially written last line is ignored.
+func readJournal(name string) (map[string]bool, error) {
+	done := map[string]bool{}
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if rel, err := strconv.Unquote(s.Text()); err == nil {
+			done[rel] = true
+		}
+	}
+	return done, s.Err()
+}
@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestParseMapping(t *testing.T) {
+	m, err := ParseMapping(strings.NewReader("# comment\nuser.a user.b\n\n  user.c   user.d\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m["user.a"] != "user.b" || m["user.c"] != "user.d" {
+		t.Errorf("wrong mapping: %v", m)
+	}
+	for _, s := range []string{"user.a", "user.a user.b user.c", "user.a user.b\nuser.b user.c", "user.a user.a"} {
+		if _, err := ParseMapping(strings.NewReader(s)); err == nil {
+			t.Errorf("ParseMapping(%q) succeeded", s)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-migrate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for _, path := range []string{a, b} {
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	xattrtest.Set(t, a, "user.app.owner", "alice")
+	xattrtest.Set(t, a, "user.app.other", "x")
+	// b looks like an interrupted rename.
+	xattrtest.Set(t, b, "user.app.owner", "bob")
+	xattrtest.Set(t, b, "user.acme.owner", "bob")
+
+	m := Mapping{"user.app.owner": "user.acme.owner"}
+	journal := dir + ".journal"
+	defer os.Remove(journal)
+	st, err := Tree(dir, m, Options{Journal: journal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Files != 3 || st.Renamed != 2 || st.Skipped != 0 {
+		t.Errorf("wrong stats: %+v", st)
+	}
+	for path, want := range map[string]string{a: "alice", b: "bob"} {
+		if v, err := xattr.Get(path, "user.acme.owner"); err != nil || string(v) != want {
+			t.Errorf("%s: new attribute is %q, %v", path, v, err)
+		}
+		if _, err := xattr.Get(path, "user.app.owner"); err == nil {
+			t.Errorf("%s: old attribute still exists", path)
+		}
+	}
+	if v, _ := xattr.Get(a, "user.app.other"); string(v) != "x" {
+		t.Error("unmapped attribute was changed")
+	}
+
+	// Resuming skips what the journal lists.
+	xattrtest.Set(t, a, "user.app.owner", "again")
+	st, err = Tree(dir, m, Options{Journal: journal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Skipped != 3 || st.Renamed != 0 {
+		t.Errorf("wrong stats on resume: %+v", st)
+	}
+
+	// A conflicting value stops the migration.
+	if _, err := Tree(dir, m, Options{}); err == nil {
+		t.Error("conflicting rename succeeded")
+	}
+	// Replace overwrites the new name where it exists and renames as
+	// usual where it does not.
+	c := filepath.Join(dir, "c")
+	if err := ioutil.WriteFile(c, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	xattrtest.Set(t, c, "user.app.owner", "carol")
+	st, err = Tree(dir, m, Options{Replace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Renamed != 2 {
+		t.Errorf("wrong stats with Replace: %+v", st)
+	}
+	for path, want := range map[string]string{a: "again", b: "bob", c: "carol"} {
+		if v, err := xattr.Get(path, "user.acme.owner"); err != nil || string(v) != want {
+			t.Errorf("%s: new attribute with Replace is %q, %v", path, v, err)
+		}
+		if _, err := xattr.Get(path, "user.app.owner"); err == nil {
+			t.Errorf("%s: old attribute still exists after Replace", path)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package xattr
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+)
+
+// userNamespace is the prefix of the canonical names of attributes on
+// platforms without namespaces.
+const userNamespace = "user."
+
+// ErrNamespace is returned when a name has a namespace that the target
+// platform cannot represent.
+var ErrNamespace = errors.New("namespace not supported on target platform")
+
+// namespaceFree reports whether goos has no namespaces, so that all names
+// live in the user namespace.
+func namespaceFree(goos string) bool {
+	switch goos {
+	case "darwin", "ios", "solaris", "illumos":
+		return true
+	}
+	return false
+}
+
+// extattrPlatform reports whether goos uses the extattr syscalls, which
+// only know the user and the system namespace.
+func extattrPlatform(goos string) bool {
+	return goos == "freebsd" || goos == "netbsd"
+}
+
+// linuxNamespaces are the namespaces Linux knows. It rejects names outside
+// of them with EOPNOTSUPP, even on filesystems with extended attribute
+// support.
+var linuxNamespaces = []string{"security.", "system.", "trusted.", userNamespace}
+
+// knownNamespace reports whether goos accepts the namespace of name, so
+// that an EOPNOTSUPP for it means the filesystem lacks support.
+func knownNamespace(goos, name string) bool {
+	if goos != "linux" && goos != "android" {
+		return true
+	}
+	for _, ns := range linuxNamespaces {
+		if strings.HasPrefix(name, ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// NativeName converts a canonical attribute name to its native form on
+// goos, a value of runtime.GOOS.
+func NativeName(goos, name string) (string, error) {
+	if extattrPlatform(goos) {
+		if ns, _ := splitExtattrName(name); ns == extattrNamespaceUser && !strings.HasPrefix(name, userNamespace) {
+			return "", &Error{"xattr.NativeName", "", name, ErrNamespace}
+		}
+		return name, nil
+	}
+	if !namespaceFree(goos) {
+		return name, nil
+	}
+	if !strings.HasPrefix(name, userNamespace) || len(name) == len(userNamespace) {
+		return "", &Error{"xattr.NativeName", "", name, ErrNamespace}
+	}
+	return name[len(userNamespace):], nil
+}
+
+// CanonicalName converts a native attribute name on goos, a value of
+// runtime.GOOS, to its canonical form.
+func CanonicalName(goos, name string) string {
+	if extattrPlatform(goos) {
+		// Unprefixed names are in the user namespace.
+		ns, attrname := splitExtattrName(name)
+		return extattrPrefixes[ns] + attrname
+	}
+	if !namespaceFree(goos) {
+		return name
+	}
+	return userNamespace + name
+}
+
+// TranslateName converts a native attribute name on the platform from to
+// the native name on the platform to. Both are values of runtime.GOOS.
+func TranslateName(from, to, name string) (string, error) {
+	native, err := NativeName(to, CanonicalName(from, name))
+	if err != nil {
+		err.(*Error).Op = "xattr.TranslateName"
+		err.(*Error).Name = name
+	}
+	return native, err
+}
+
+// Native converts a canonical attribute name to the native form of the
+// current platform.
+func Native(name string) (string, error) {
+	return NativeName(runtime.GOOS, name)
+}
+
+// Canonical converts an attribute name of the current platform to its
+// canonical form.
+func Canonical(name string) string {
+	return CanonicalName(runtime.GOOS, name)
+}
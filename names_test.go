@@ -0,0 +1,54 @@
+package xattr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTranslateName(t *testing.T) {
+	tests := []struct {
+		from, to, name, want string
+	}{
+		{"darwin", "linux", "com.apple.quarantine", "user.com.apple.quarantine"},
+		{"linux", "darwin", "user.com.apple.quarantine", "com.apple.quarantine"},
+		{"linux", "freebsd", "user.mime_type", "user.mime_type"},
+		{"linux", "netbsd", "system.posix1e.acl_access", "system.posix1e.acl_access"},
+		{"freebsd", "darwin", "user.foo", "foo"},
+		{"netbsd", "solaris", "foo", "foo"},
+		{"netbsd", "linux", "foo", "user.foo"},
+		{"linux", "linux", "trusted.overlay.opaque", "trusted.overlay.opaque"},
+		{"darwin", "windows", "com.apple.FinderInfo", "user.com.apple.FinderInfo"},
+	}
+	for _, tt := range tests {
+		have, err := TranslateName(tt.from, tt.to, tt.name)
+		if err != nil || have != tt.want {
+			t.Errorf("TranslateName(%q, %q, %q) = %q, %v; want %q", tt.from, tt.to, tt.name, have, err, tt.want)
+		}
+	}
+
+	for _, to := range []string{"darwin", "freebsd"} {
+		for _, name := range []string{"trusted.overlay.opaque", "security.selinux"} {
+			_, err := TranslateName("linux", to, name)
+			if !errors.Is(err, ErrNamespace) {
+				t.Errorf("TranslateName(linux, %s, %q): want ErrNamespace, have %v", to, name, err)
+			}
+		}
+	}
+	if _, err := TranslateName("linux", "darwin", "user."); !errors.Is(err, ErrNamespace) {
+		t.Errorf("TranslateName(linux, darwin, \"user.\"): want ErrNamespace, have %v", err)
+	}
+}
+
+func TestNameRoundTrip(t *testing.T) {
+	for _, goos := range []string{"linux", "darwin", "freebsd", "netbsd", "solaris"} {
+		for _, name := range []string{"user.a", "user.com.apple.quarantine"} {
+			native, err := NativeName(goos, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if back := CanonicalName(goos, native); back != name {
+				t.Errorf("%s: %q -> %q -> %q", goos, name, native, back)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Package nfs4acl decodes and encodes NFSv4 access control lists as the Linux
+NFS client exposes them in the system.nfs4_acl attribute, converts them to
+and from the text form of nfs4_getfacl and nfs4_setfacl, such as
+"A::OWNER@:rwatTnNcCy", and maps them to and from POSIX ACLs.
+*/
+package nfs4acl
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/pkg/xattr"
+)
+
+// Attr is the attribute name.
+const Attr = "system.nfs4_acl"
+
+// Type is the type of an ACE.
+type Type uint32
+
+// ACE types.
+const (
+	Allow Type = 0
+	Deny  Type = 1
+	Audit Type = 2
+	Alarm Type = 3
+)
+
+// Flag holds the inheritance and principal flags of an ACE.
+type Flag uint32
+
+// ACE flags.
+const (
+	FileInherit        Flag = 0x01
+	DirectoryInherit   Flag = 0x02
+	NoPropagateInherit Flag = 0x04
+	InheritOnly        Flag = 0x08
+	SuccessfulAccess   Flag = 0x10
+	FailedAccess       Flag = 0x20
+	IdentifierGroup    Flag = 0x40
+	Inherited          Flag = 0x80
+)
+
+// Mask is an access mask.
+type Mask uint32
+
+// Access mask bits. Some bits have a second name for directories.
+const (
+	ReadData           Mask = 0x00000001
+	ListDirectory      Mask = 0x00000001
+	WriteData          Mask = 0x00000002
+	AddFile            Mask = 0x00000002
+	AppendData         Mask = 0x00000004
+	AddSubdirectory    Mask = 0x00000004
+	ReadNamedAttrs     Mask = 0x00000008
+	WriteNamedAttrs    Mask = 0x00000010
+	Execute            Mask = 0x00000020
+	DeleteChild        Mask = 0x00000040
+	ReadAttributes     Mask = 0x00000080
+	WriteAttributes    Mask = 0x00000100
+	WriteRetention     Mask = 0x00000200
+	WriteRetentionHold Mask = 0x00000400
+	Delete             Mask = 0x00010000
+	ReadACL            Mask = 0x00020000
+	WriteACL           Mask = 0x00040000
+	WriteOwner         Mask = 0x00080000
+	Synchronize        Mask = 0x00100000
+)
+
+// Special principals.
+const (
+	Owner    = "OWNER@"
+	Group    = "GROUP@"
+	Everyone = "EVERYONE@"
+)
+
+// ErrFormat is returned for values and text that are not valid ACLs.
+var ErrFormat = errors.New("nfs4acl: invalid ACL")
+
+// ACE is an access control entry. Who is a special principal or a user or
+// group name such as "alice@example.com"; IdentifierGroup marks group
+// names.
+type ACE struct {
+	Type  Type
+	Flags Flag
+	Mask  Mask
+	Who   string
+}
+
+// ACL is a list of entries, evaluated in order.
+type ACL []ACE
+
+// minACESize is the encoded size of an ACE with an empty principal.
+const minACESize = 16
+
+// Parse decodes an attribute value: the XDR encoding of an array of
+// nfsace4.
+func Parse(b []byte) (ACL, error) {
+	if len(b) < 4 {
+		return nil, ErrFormat
+	}
+	n := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if uint64(n) > uint64(len(b)/minACESize) {
+		return nil, ErrFormat
+	}
+	acl := make(ACL, 0, n)
+	for i := uint32(0); i < n; i++ {
+		if len(b) < minACESize {
+			return nil, ErrFormat
+		}
+		ace := ACE{
+			Type:  Type(binary.BigEndian.Uint32(b)),
+			Flags: Flag(binary.BigEndian.Uint32(b[4:])),
+			Mask:  Mask(binary.BigEndian.Uint32(b[8:])),
+		}
+		size := binary.BigEndian.Uint32(b[12:])
+		b = b[minACESize:]
+		padded := (uint64(size) + 3) &^ 3
+		if padded > uint64(len(b)) {
+			return nil, ErrFormat
+		}
+		ace.Who = string(b[:size])
+		b = b[padded:]
+		acl = append(acl, ace)
+	}
+	if len(b) != 0 {
+		return nil, ErrFormat
+	}
+	return acl, nil
+}
+
+// Encode returns the attribute value of acl.
+func (acl ACL) Encode() []byte {
+	size := 4
+	for _, ace := range acl {
+		size += minACESize + (len(ace.Who)+3)&^3
+	}
+	b := make([]byte, 4, size)
+	binary.BigEndian.PutUint32(b, uint32(len(acl)))
+	for _, ace := range acl {
+		var hdr [minACESize]byte
+		binary.BigEndian.PutUint32(hdr[:], uint32(ace.Type))
+		binary.BigEndian.PutUint32(hdr[4:], uint32(ace.Flags))
+		binary.BigEndian.PutUint32(hdr[8:], uint32(ace.Mask))
+		binary.BigEndian.PutUint32(hdr[12:], uint32(len(ace.Who)))
+		b = append(b, hdr[:]...)
+		b = append(b, ace.Who...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+// Get reads the ACL of path, without following a symlink at the end of
+// path.
+func Get(path string) (ACL, error) {
+	b, err := xattr.LGet(path, Attr)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Set stores acl as the ACL of path. The server may reorder or rewrite the
+// entries.
+func Set(path string, acl ACL) error {
+	return xattr.LSet(path, Attr, acl.Encode())
+}
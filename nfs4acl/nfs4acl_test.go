@@ -0,0 +1,94 @@
+package nfs4acl
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/xattr/posixacl"
+)
+
+func TestCodec(t *testing.T) {
+	acl := ACL{
+		{Type: Allow, Mask: ReadData | WriteData | ReadACL, Who: Owner},
+		{Type: Deny, Flags: IdentifierGroup, Mask: WriteData, Who: "staff@example.com"},
+		{Type: Allow, Flags: FileInherit | DirectoryInherit, Mask: ReadData, Who: Everyone},
+	}
+	b := acl.Encode()
+	if len(b)%4 != 0 || !bytes.Equal(b[:8], []byte{0, 0, 0, 3, 0, 0, 0, 0}) {
+		t.Fatalf("Encode = %x", b)
+	}
+	got, err := Parse(b)
+	if err != nil || !reflect.DeepEqual(got, acl) {
+		t.Errorf("Parse = %v, %v", got, err)
+	}
+	for _, bad := range [][]byte{nil, {0, 0, 0, 1}, append(b, 0), b[:len(b)-1], {0xff, 0xff, 0xff, 0xff}} {
+		if _, err := Parse(bad); err != ErrFormat {
+			t.Errorf("Parse(%x) = %v", bad, err)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	const text = "A::OWNER@:rwatTnNcCy\nD:g:GROUP@:wa\nA:fdig:staff@example.com:rxtncy\n"
+	acl, err := ParseText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl[2].Flags != FileInherit|DirectoryInherit|InheritOnly|IdentifierGroup || acl[2].Who != "staff@example.com" {
+		t.Errorf("ParseText = %+v", acl[2])
+	}
+	if s := acl.String(); s != text {
+		t.Errorf("String = %q", s)
+	}
+	if acl, err := ParseText("A::EVERYONE@:RX # comment"); err != nil || acl[0].Mask != genericRead|genericExecute {
+		t.Errorf("aliases = %v, %v", acl, err)
+	}
+	if acl, err := ParseText("A::EVERYONE@:r # read, not write\nA::OWNER@:w"); err != nil || len(acl) != 2 {
+		t.Errorf("comment with comma = %v, %v", acl, err)
+	}
+	for _, bad := range []string{"A::OWNER@", "Z::OWNER@:r", "A:q:OWNER@:r", "A::OWNER@:q", "A:::r"} {
+		if _, err := ParseText(bad); !errors.Is(err, ErrFormat) {
+			t.Errorf("ParseText(%q) = %v", bad, err)
+		}
+	}
+}
+
+func TestPOSIX(t *testing.T) {
+	access, _ := posixacl.ParseText("u::rw-,u:1000:r--,g::r-x,g:2000:rw-,m::rwx,o::r--")
+	def, _ := posixacl.ParseText("u::rwx,g::r-x,o::---")
+	opts := &Options{Dir: true}
+	acl, err := FromPOSIX(access, def, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl[0] != (ACE{Type: Allow, Mask: ReadData | WriteData | AppendData | DeleteChild | alwaysAllowed | ownerAllowed, Who: Owner}) ||
+		acl[1] != (ACE{Type: Deny, Mask: Execute, Who: Owner}) {
+		t.Errorf("owner entries = %v", acl[:2])
+	}
+	gotAccess, gotDef, err := ToPOSIX(acl, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotAccess.String() != access.String() || gotDef.String() != def.String() {
+		t.Errorf("ToPOSIX = %v; %v", gotAccess, gotDef)
+	}
+
+	// A group deny reaches named users that appear later.
+	acl, _ = ParseText("A::OWNER@:rw,D:g:GROUP@:w,A::1000:rw,A::EVERYONE@:r")
+	gotAccess, gotDef, err = ToPOSIX(acl, &Options{})
+	if err != nil || gotDef != nil || gotAccess.String() != "user::rw-,user:1000:r--,group::r--,mask::r--,other::r--" {
+		t.Errorf("ToPOSIX = %v; %v; %v", gotAccess, gotDef, err)
+	}
+
+	if _, _, err := ToPOSIX(ACL{{Type: Allow, Who: "alice@example.com"}}, nil); !errors.Is(err, ErrUnmappable) {
+		t.Errorf("named principal = %v", err)
+	}
+	if _, _, err := ToPOSIX(ACL{{Type: Allow, Who: "INTERACTIVE@"}}, nil); !errors.Is(err, ErrUnmappable) {
+		t.Errorf("special principal = %v", err)
+	}
+	if _, err := FromPOSIX(access, def, nil); !errors.Is(err, ErrUnmappable) {
+		t.Errorf("default ACL on a file = %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+package nfs4acl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/xattr/posixacl"
+)
+
+// ErrUnmappable is returned for entries that have no POSIX equivalent.
+var ErrUnmappable = errors.New("nfs4acl: entry cannot be mapped to a POSIX ACL")
+
+// Options control the mapping between NFSv4 and POSIX ACLs.
+type Options struct {
+	// Dir is set for directories. Write permission then includes
+	// DeleteChild, and default ACLs are mapped.
+	Dir bool
+	// Name returns the principal of a uid or gid. If nil, the decimal ID
+	// is used, as servers do without ID mapping.
+	Name func(id uint32, group bool) string
+	// ID returns the uid or gid of a principal. If nil, only decimal IDs
+	// are accepted.
+	ID func(who string, group bool) (uint32, error)
+}
+
+func (o *Options) name(id uint32, group bool) string {
+	if o.Name != nil {
+		return o.Name(id, group)
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func (o *Options) id(who string, group bool) (uint32, error) {
+	if o.ID != nil {
+		return o.ID(who, group)
+	}
+	id, err := strconv.ParseUint(who, 10, 32)
+	if err != nil || id == posixacl.UndefinedID {
+		return 0, fmt.Errorf("%w: principal %q", ErrUnmappable, who)
+	}
+	return uint32(id), nil
+}
+
+// Bits granted to everyone with any access, and additionally to the owner.
+const (
+	alwaysAllowed = ReadAttributes | ReadNamedAttrs | ReadACL | Synchronize
+	ownerAllowed  = WriteAttributes | WriteNamedAttrs | WriteACL
+)
+
+func maskFromPerm(perm uint16, dir bool) Mask {
+	var m Mask
+	if perm&posixacl.Read != 0 {
+		m |= ReadData
+	}
+	if perm&posixacl.Write != 0 {
+		m |= WriteData | AppendData
+		if dir {
+			m |= DeleteChild
+		}
+	}
+	if perm&posixacl.Execute != 0 {
+		m |= Execute
+	}
+	return m
+}
+
+func permFromMask(m Mask) uint16 {
+	var perm uint16
+	if m&ReadData != 0 {
+		perm |= posixacl.Read
+	}
+	if m&WriteData != 0 {
+		perm |= posixacl.Write
+	}
+	if m&Execute != 0 {
+		perm |= posixacl.Execute
+	}
+	return perm
+}
+
+// FromPOSIX maps a POSIX access ACL and, for directories, a default ACL
+// to an NFSv4 ACL the way the Linux NFS server does. Each class gets an
+// Allow entry followed by a Deny entry for the read, write and execute
+// bits it lacks, so that a later EVERYONE@ entry does not grant them. The
+// default ACL is appended as inherit-only entries. def may be nil.
+func FromPOSIX(access, def posixacl.ACL, opts *Options) (ACL, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if !access.Valid() {
+		return nil, posixacl.ErrFormat
+	}
+	acl := fromPOSIX(access, 0, opts)
+	if len(def) > 0 {
+		if !def.Valid() {
+			return nil, posixacl.ErrFormat
+		}
+		if !opts.Dir {
+			return nil, fmt.Errorf("%w: default ACL on a file", ErrUnmappable)
+		}
+		acl = append(acl, fromPOSIX(def, FileInherit|DirectoryInherit|InheritOnly, opts)...)
+	}
+	return acl, nil
+}
+
+func fromPOSIX(p posixacl.ACL, flags Flag, opts *Options) ACL {
+	sorted := append(posixacl.ACL{}, p...)
+	sorted.Sort()
+	mask := uint16(posixacl.Read | posixacl.Write | posixacl.Execute)
+	if e, ok := sorted.Find(posixacl.Mask, 0); ok {
+		mask = e.Perm
+	}
+	full := maskFromPerm(posixacl.Read|posixacl.Write|posixacl.Execute, opts.Dir)
+	var acl ACL
+	add := func(t Type, f Flag, who string, m Mask) {
+		if m != 0 {
+			acl = append(acl, ACE{Type: t, Flags: flags | f, Mask: m, Who: who})
+		}
+	}
+	var groups []ACE
+	for _, e := range sorted {
+		switch e.Tag {
+		case posixacl.UserObj:
+			m := maskFromPerm(e.Perm, opts.Dir)
+			add(Allow, 0, Owner, m|alwaysAllowed|ownerAllowed)
+			add(Deny, 0, Owner, full&^m)
+		case posixacl.User:
+			m := maskFromPerm(e.Perm&mask, opts.Dir)
+			who := opts.name(e.ID, false)
+			add(Allow, 0, who, m|alwaysAllowed)
+			add(Deny, 0, who, full&^m)
+		case posixacl.GroupObj, posixacl.Group:
+			who := Group
+			if e.Tag == posixacl.Group {
+				who = opts.name(e.ID, true)
+			}
+			m := maskFromPerm(e.Perm&mask, opts.Dir)
+			add(Allow, IdentifierGroup, who, m|alwaysAllowed)
+			groups = append(groups, ACE{Mask: m, Who: who})
+		case posixacl.Other:
+			// The group class is denied first: POSIX does not fall back
+			// to the other entry for members of a matching group.
+			for _, g := range groups {
+				add(Deny, IdentifierGroup, g.Who, full&^g.Mask)
+			}
+			groups = nil
+			add(Allow, 0, Everyone, maskFromPerm(e.Perm, opts.Dir)|alwaysAllowed)
+		}
+	}
+	return acl
+}
+
+// bits tracks what an ACE walk has allowed and denied to one class, where
+// the first decision for a bit wins.
+type bits struct {
+	allow, deny Mask
+}
+
+func (b *bits) allowBits(m Mask) { b.allow |= m &^ b.deny }
+func (b *bits) denyBits(m Mask)  { b.deny |= m &^ b.allow }
+
+type named struct {
+	id uint32
+	bits
+}
+
+type posixState struct {
+	owner, group, other, everyone bits
+	users, groups                 []*named
+	empty                         bool
+}
+
+func (s *posixState) find(list *[]*named, id uint32) *named {
+	for _, n := range *list {
+		if n.id == id {
+			return n
+		}
+	}
+	// A principal first mentioned late has been affected by all
+	// EVERYONE@ entries so far.
+	n := &named{id: id, bits: s.everyone}
+	*list = append(*list, n)
+	return n
+}
+
+func (s *posixState) all() []*bits {
+	l := []*bits{&s.owner, &s.group, &s.other, &s.everyone}
+	for _, n := range s.users {
+		l = append(l, &n.bits)
+	}
+	for _, n := range s.groups {
+		l = append(l, &n.bits)
+	}
+	return l
+}
+
+func (s *posixState) apply(ace ACE, opts *Options) error {
+	allow := ace.Type == Allow
+	switch {
+	case ace.Who == Owner:
+		if allow {
+			s.owner.allowBits(ace.Mask)
+		} else {
+			s.owner.denyBits(ace.Mask)
+		}
+	case ace.Who == Everyone:
+		for _, b := range s.all() {
+			if allow {
+				b.allowBits(ace.Mask)
+			} else {
+				b.denyBits(ace.Mask)
+			}
+		}
+	case ace.Who == Group || ace.Flags&IdentifierGroup != 0:
+		b := &s.group
+		if ace.Who != Group {
+			id, err := opts.id(ace.Who, true)
+			if err != nil {
+				return err
+			}
+			b = &s.find(&s.groups, id).bits
+		}
+		if allow {
+			b.allowBits(ace.Mask)
+			break
+		}
+		// A group deny may apply to anyone, as any user can be a member.
+		b.denyBits(ace.Mask)
+		for _, o := range s.all() {
+			if o != &s.other {
+				o.denyBits(b.deny & ace.Mask)
+			}
+		}
+	case len(ace.Who) > 0 && ace.Who[len(ace.Who)-1] == '@':
+		return fmt.Errorf("%w: principal %q", ErrUnmappable, ace.Who)
+	default:
+		id, err := opts.id(ace.Who, false)
+		if err != nil {
+			return err
+		}
+		n := s.find(&s.users, id)
+		if allow {
+			n.allowBits(ace.Mask)
+		} else {
+			n.denyBits(ace.Mask)
+			// The named user may be the owner.
+			s.owner.denyBits(n.deny & ace.Mask)
+		}
+	}
+	s.empty = false
+	return nil
+}
+
+func (s *posixState) acl() posixacl.ACL {
+	acl := posixacl.ACL{
+		{Tag: posixacl.UserObj, Perm: permFromMask(s.owner.allow), ID: posixacl.UndefinedID},
+		{Tag: posixacl.GroupObj, Perm: permFromMask(s.group.allow), ID: posixacl.UndefinedID},
+		{Tag: posixacl.Other, Perm: permFromMask(s.other.allow), ID: posixacl.UndefinedID},
+	}
+	mask := permFromMask(s.group.allow)
+	for _, n := range s.users {
+		perm := permFromMask(n.allow)
+		mask |= perm
+		acl = append(acl, posixacl.Entry{Tag: posixacl.User, Perm: perm, ID: n.id})
+	}
+	for _, n := range s.groups {
+		perm := permFromMask(n.allow)
+		mask |= perm
+		acl = append(acl, posixacl.Entry{Tag: posixacl.Group, Perm: perm, ID: n.id})
+	}
+	if len(s.users)+len(s.groups) > 0 {
+		acl = append(acl, posixacl.Entry{Tag: posixacl.Mask, Perm: mask, ID: posixacl.UndefinedID})
+	}
+	acl.Sort()
+	return acl
+}
+
+// ToPOSIX maps acl to a POSIX access ACL and, if opts.Dir is set and acl
+// has inheritable entries, a default ACL, following the algorithm of the
+// Linux NFS server. The mapping is lossy:
+//
+//   - Only the read, write and execute bits are kept; write requires
+//     WriteData. All other mask bits are dropped.
+//   - Allow and Deny entries are flattened to the permissions each class
+//     ends up with. A group Deny is applied to every class except other,
+//     since any user may be a member of the group, which can take away
+//     more than the server would.
+//   - Audit and Alarm entries are ignored.
+//   - Entries inheritable only by files or only by directories both go
+//     to the default ACL, and NoPropagateInherit is ignored.
+//   - Special principals other than OWNER@, GROUP@ and EVERYONE@ fail
+//     with ErrUnmappable, as do principals opts.ID cannot resolve.
+func ToPOSIX(acl ACL, opts *Options) (access, def posixacl.ACL, err error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	a, d := &posixState{empty: true}, &posixState{empty: true}
+	for _, ace := range acl {
+		if ace.Type != Allow && ace.Type != Deny {
+			continue
+		}
+		if ace.Flags&InheritOnly == 0 {
+			if err := a.apply(ace, opts); err != nil {
+				return nil, nil, err
+			}
+		}
+		if opts.Dir && ace.Flags&(FileInherit|DirectoryInherit) != 0 {
+			if err := d.apply(ace, opts); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	access = a.acl()
+	if !d.empty {
+		def = d.acl()
+	}
+	return access, def, nil
+}
@@ -0,0 +1,180 @@
+package nfs4acl
+
+import (
+	"fmt"
+	"strings"
+)
+
+var typeLetters = []struct {
+	t Type
+	c byte
+}{
+	{Allow, 'A'},
+	{Deny, 'D'},
+	{Audit, 'U'},
+	{Alarm, 'L'},
+}
+
+var flagLetters = []struct {
+	f Flag
+	c byte
+}{
+	{FileInherit, 'f'},
+	{DirectoryInherit, 'd'},
+	{NoPropagateInherit, 'n'},
+	{InheritOnly, 'i'},
+	{SuccessfulAccess, 'S'},
+	{FailedAccess, 'F'},
+	{IdentifierGroup, 'g'},
+	{Inherited, 'I'},
+}
+
+// maskLetters is in the order nfs4_getfacl prints permissions.
+var maskLetters = []struct {
+	m Mask
+	c byte
+}{
+	{ReadData, 'r'},
+	{WriteData, 'w'},
+	{AppendData, 'a'},
+	{DeleteChild, 'D'},
+	{Delete, 'd'},
+	{Execute, 'x'},
+	{ReadAttributes, 't'},
+	{WriteAttributes, 'T'},
+	{ReadNamedAttrs, 'n'},
+	{WriteNamedAttrs, 'N'},
+	{ReadACL, 'c'},
+	{WriteACL, 'C'},
+	{WriteOwner, 'o'},
+	{Synchronize, 'y'},
+}
+
+// Permission aliases accepted by nfs4_setfacl.
+const (
+	genericRead    = ReadData | ReadNamedAttrs | ReadAttributes | ReadACL | Synchronize
+	genericWrite   = WriteData | AppendData | WriteNamedAttrs | WriteAttributes | ReadACL | WriteACL | Synchronize
+	genericExecute = Execute | ReadAttributes | ReadACL | Synchronize
+)
+
+// MaskString returns m in the letter form of nfs4_getfacl. Bits without a
+// letter, such as the retention bits, are left out.
+func MaskString(m Mask) string {
+	var b []byte
+	for _, l := range maskLetters {
+		if m&l.m != 0 {
+			b = append(b, l.c)
+		}
+	}
+	return string(b)
+}
+
+// String returns ace in the form "type:flags:principal:permissions".
+func (ace ACE) String() string {
+	var b strings.Builder
+	typ := fmt.Sprint(uint32(ace.Type))
+	for _, l := range typeLetters {
+		if ace.Type == l.t {
+			typ = string(l.c)
+		}
+	}
+	b.WriteString(typ)
+	b.WriteByte(':')
+	for _, l := range flagLetters {
+		if ace.Flags&l.f != 0 {
+			b.WriteByte(l.c)
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(ace.Who)
+	b.WriteByte(':')
+	b.WriteString(MaskString(ace.Mask))
+	return b.String()
+}
+
+// String returns acl in the text form of nfs4_getfacl, one entry per line.
+func (acl ACL) String() string {
+	var b strings.Builder
+	for _, ace := range acl {
+		b.WriteString(ace.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// ParseText parses the text form. Entries are separated by commas or
+// newlines, and comments start with '#'. The permission aliases R, W and X
+// of nfs4_setfacl are accepted.
+func ParseText(s string) (ACL, error) {
+	var acl ACL
+	for _, line := range strings.Split(s, "\n") {
+		// A comment runs to the end of the line, commas included.
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		for _, f := range strings.Split(line, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			ace, err := parseACE(f)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrFormat, f)
+			}
+			acl = append(acl, ace)
+		}
+	}
+	return acl, nil
+}
+
+func parseACE(s string) (ACE, error) {
+	var ace ACE
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 || len(parts[0]) != 1 || parts[2] == "" {
+		return ace, ErrFormat
+	}
+	found := false
+	for _, l := range typeLetters {
+		if parts[0][0] == l.c {
+			ace.Type, found = l.t, true
+		}
+	}
+	if !found {
+		return ace, ErrFormat
+	}
+	for i := 0; i < len(parts[1]); i++ {
+		found = false
+		for _, l := range flagLetters {
+			if parts[1][i] == l.c {
+				ace.Flags |= l.f
+				found = true
+			}
+		}
+		if !found {
+			return ace, ErrFormat
+		}
+	}
+	ace.Who = parts[2]
+	for i := 0; i < len(parts[3]); i++ {
+		switch c := parts[3][i]; c {
+		case 'R':
+			ace.Mask |= genericRead
+		case 'W':
+			ace.Mask |= genericWrite
+		case 'X':
+			ace.Mask |= genericExecute
+		default:
+			found = false
+			for _, l := range maskLetters {
+				if c == l.c {
+					ace.Mask |= l.m
+					found = true
+				}
+			}
+			if !found {
+				return ace, ErrFormat
+			}
+		}
+	}
+	return ace, nil
+}
@@ -0,0 +1,408 @@
+/*
+Package overlay decodes the extended attributes overlayfs keeps in its upper
+and work directories, and translates them between the two namespaces
+overlayfs uses:
+
+	trusted.overlay.*    the default, which requires CAP_SYS_ADMIN
+	user.overlay.*       with the userxattr mount option, for rootless use
+
+The attributes live on directories, whiteouts and copied-up files, and are
+read and written with LGet and LSet so that symlinks are never followed.
+
+ToOverlay and ToOCI convert a directory tree between the overlayfs form of
+deletions and opaque directories and the whiteout files of OCI image
+layers; ToOverlayTar and ToOCITar do the same for tar streams.
+
+overlayfs marks a deleted file with a whiteout: a character device with
+device number 0/0, which needs CAP_MKNOD to create, or, since Linux 6.7, an
+empty regular file with the whiteout attribute. The directory holding such
+an xattr whiteout carries the opaque attribute with the value "x" unless it
+is opaque, and the whiteouts attribute that the first kernels with this
+feature looked for. ToOverlayXattr creates this form, which works without
+privileges in the user namespace.
+*/
+package overlay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// Namespace is the prefix of the overlayfs attributes.
+type Namespace string
+
+const (
+	Trusted Namespace = "trusted.overlay."
+	User    Namespace = "user.overlay."
+)
+
+// Attribute names without the namespace prefix.
+const (
+	Opaque    = "opaque"
+	Redirect  = "redirect"
+	Origin    = "origin"
+	Metacopy  = "metacopy"
+	Impure    = "impure"
+	NLink     = "nlink"
+	Upper     = "upper"
+	UUID      = "uuid"
+	Protattr  = "protattr"
+	Whiteout  = "whiteout"
+	Whiteouts = "whiteouts"
+)
+
+// ErrFormat is returned for attribute values that cannot be decoded.
+var ErrFormat = errors.New("overlay: invalid attribute value")
+
+// Attrs are the decoded overlayfs attributes of a file or directory.
+type Attrs struct {
+	// Opaque is set on directories that hide the lower directories of the
+	// same name.
+	Opaque bool
+	// Redirect is the path of the lower directory a renamed directory
+	// merges with, either absolute from the layer root or a plain name
+	// relative to the parent.
+	Redirect string
+	// Origin identifies the lower file a file was copied up from. It is
+	// nil if the attribute is not set; an empty origin without a file
+	// handle means the lower file could not be encoded.
+	Origin *FileHandle
+	// Metacopy is non-nil for files whose data is still in a lower layer.
+	Metacopy *MetacopyInfo
+	// Impure is set on directories that contain copied-up or redirected
+	// entries.
+	Impure bool
+	// NLink is the raw nlink accounting value, such as "U+1".
+	NLink string
+	// Whiteout is set on empty files that are xattr whiteouts.
+	Whiteout bool
+	// Whiteouts is set on directories that contain xattr whiteouts.
+	Whiteouts bool
+}
+
+// Name returns the full attribute name of name in the namespace.
+func (ns Namespace) Name(name string) string {
+	return string(ns) + name
+}
+
+// Read decodes the overlayfs attributes of path in the namespace ns.
+// Attributes that are not set are left at their zero value.
+func Read(path string, ns Namespace) (*Attrs, error) {
+	a := &Attrs{}
+	get := func(name string) ([]byte, bool, error) {
+		value, err := xattr.LGet(path, ns.Name(name))
+		if errors.Is(err, xattr.ENOATTR) || walk.Unsupported(err) {
+			return nil, false, nil
+		}
+		return value, err == nil, err
+	}
+	value, ok, err := get(Opaque)
+	if err != nil {
+		return nil, err
+	}
+	a.Opaque = ok && string(value) == "y"
+	a.Whiteouts = ok && string(value) == opaqueWhiteouts
+	if value, _, err = get(Redirect); err != nil {
+		return nil, err
+	}
+	a.Redirect = string(value)
+	if value, ok, err = get(Origin); err != nil {
+		return nil, err
+	}
+	if ok {
+		if a.Origin, err = ParseFileHandle(value); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok, err = get(Metacopy); err != nil {
+		return nil, err
+	}
+	if ok {
+		if a.Metacopy, err = ParseMetacopy(value); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok, err = get(Impure); err != nil {
+		return nil, err
+	}
+	a.Impure = ok && string(value) == "y"
+	if value, _, err = get(NLink); err != nil {
+		return nil, err
+	}
+	a.NLink = string(value)
+	if _, ok, err = get(Whiteout); err != nil {
+		return nil, err
+	}
+	a.Whiteout = ok
+	if _, ok, err = get(Whiteouts); err != nil {
+		return nil, err
+	}
+	a.Whiteouts = a.Whiteouts || ok
+	return a, nil
+}
+
+// SetOpaque marks the directory path as opaque.
+func SetOpaque(path string, ns Namespace) error {
+	return xattr.LSet(path, ns.Name(Opaque), []byte("y"))
+}
+
+// IsOpaque reports whether the directory path is marked as opaque.
+func IsOpaque(path string, ns Namespace) (bool, error) {
+	value, err := xattr.LGet(path, ns.Name(Opaque))
+	if errors.Is(err, xattr.ENOATTR) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return string(value) == "y", nil
+}
+
+// opaqueWhiteouts is the value of the opaque attribute of a directory that
+// is not opaque but contains xattr whiteouts.
+const opaqueWhiteouts = "x"
+
+// MakeWhiteout creates an xattr whiteout at path and marks its parent
+// directory as containing one.
+func MakeWhiteout(path string, ns Namespace) error {
+	if err := touch(path); err != nil {
+		return err
+	}
+	if err := xattr.LSet(path, ns.Name(Whiteout), nil); err != nil {
+		os.Remove(path)
+		return err
+	}
+	dir := filepath.Dir(path)
+	if _, err := xattr.LGet(dir, ns.Name(Opaque)); errors.Is(err, xattr.ENOATTR) {
+		if err := xattr.LSet(dir, ns.Name(Opaque), []byte(opaqueWhiteouts)); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return xattr.LSet(dir, ns.Name(Whiteouts), nil)
+}
+
+// IsWhiteout reports whether path is a whiteout of either form.
+func IsWhiteout(path string, ns Namespace) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+	return whiteoutInfo(path, info, ns)
+}
+
+func whiteoutInfo(path string, info os.FileInfo, ns Namespace) (bool, error) {
+	if isWhiteout(info) {
+		return true, nil
+	}
+	if !info.Mode().IsRegular() || info.Size() != 0 {
+		return false, nil
+	}
+	_, err := xattr.LGet(path, ns.Name(Whiteout))
+	if errors.Is(err, xattr.ENOATTR) || walk.Unsupported(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetRedirect sets the redirect of the directory path.
+func SetRedirect(path string, ns Namespace, redirect string) error {
+	return xattr.LSet(path, ns.Name(Redirect), []byte(redirect))
+}
+
+// File handle flags.
+const (
+	FHBigEndian = 1 << 0
+	FHAnyEndian = 1 << 1
+	FHPathUpper = 1 << 2
+)
+
+const (
+	fhVersion    = 0
+	fhMagic      = 0xfb
+	fhHeaderSize = 21
+)
+
+// FileHandle is the encoded file handle of the origin and upper
+// attributes, struct ovl_fb in the kernel.
+type FileHandle struct {
+	Flags uint8
+	// Type is the file handle type of the underlying filesystem.
+	Type uint8
+	// UUID identifies the filesystem of the file.
+	UUID [16]byte
+	// FID is the filesystem specific file identifier.
+	FID []byte
+}
+
+// ParseFileHandle decodes a file handle. An empty value, which overlayfs
+// writes when it cannot encode the lower file, yields an empty handle.
+func ParseFileHandle(b []byte) (*FileHandle, error) {
+	if len(b) == 0 {
+		return &FileHandle{}, nil
+	}
+	if len(b) < fhHeaderSize || b[0] != fhVersion || b[1] != fhMagic || int(b[2]) != len(b) {
+		return nil, ErrFormat
+	}
+	fh := &FileHandle{Flags: b[3], Type: b[4], FID: append([]byte{}, b[fhHeaderSize:]...)}
+	copy(fh.UUID[:], b[5:21])
+	return fh, nil
+}
+
+// Empty reports whether fh is the empty handle of a lower file that could
+// not be encoded.
+func (fh *FileHandle) Empty() bool {
+	return fh.FID == nil && fh.Type == 0 && fh.UUID == [16]byte{}
+}
+
+// Encode returns the attribute value of fh.
+func (fh *FileHandle) Encode() []byte {
+	if fh.Empty() {
+		return []byte{}
+	}
+	b := []byte{fhVersion, fhMagic, byte(fhHeaderSize + len(fh.FID)), fh.Flags, fh.Type}
+	b = append(b, fh.UUID[:]...)
+	return append(b, fh.FID...)
+}
+
+// Inode returns the inode number and generation of a FID of type
+// FILEID_INO32_GEN (1) or FILEID_INO32_GEN_PARENT (2), as used by ext4
+// and many other filesystems. The byte order follows the flags.
+func (fh *FileHandle) Inode() (ino, gen uint32, ok bool) {
+	if (fh.Type != 1 && fh.Type != 2) || len(fh.FID) < 8 {
+		return 0, 0, false
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if fh.Flags&FHBigEndian != 0 {
+		order = binary.BigEndian
+	}
+	return order.Uint32(fh.FID), order.Uint32(fh.FID[4:]), true
+}
+
+// fs-verity digest algorithms of the metacopy attribute.
+const (
+	DigestSHA256 = 1
+	DigestSHA512 = 2
+)
+
+const metacopyHeaderSize = 4
+
+// MetacopyInfo is the value of the metacopy attribute, struct ovl_metacopy
+// in the kernel. Older kernels write an empty value, which decodes to an
+// info without digest.
+type MetacopyInfo struct {
+	Flags uint8
+	// Algorithm is the fs-verity digest algorithm, or 0 if there is no
+	// digest.
+	Algorithm uint8
+	// Digest is the fs-verity digest of the lower data file.
+	Digest []byte
+}
+
+// ParseMetacopy decodes a metacopy value.
+func ParseMetacopy(b []byte) (*MetacopyInfo, error) {
+	if len(b) == 0 {
+		return &MetacopyInfo{}, nil
+	}
+	if len(b) < metacopyHeaderSize || b[0] != 0 || int(b[1]) != len(b) {
+		return nil, ErrFormat
+	}
+	m := &MetacopyInfo{Flags: b[2], Algorithm: b[3]}
+	if len(b) > metacopyHeaderSize {
+		m.Digest = append([]byte{}, b[metacopyHeaderSize:]...)
+	}
+	if digestSize(m.Algorithm) != len(m.Digest) {
+		return nil, ErrFormat
+	}
+	return m, nil
+}
+
+func digestSize(alg uint8) int {
+	switch alg {
+	case DigestSHA256:
+		return 32
+	case DigestSHA512:
+		return 64
+	}
+	return 0
+}
+
+// Encode returns the attribute value of m.
+func (m *MetacopyInfo) Encode() []byte {
+	if m.Algorithm == 0 && m.Flags == 0 && len(m.Digest) == 0 {
+		return []byte{}
+	}
+	b := []byte{0, byte(metacopyHeaderSize + len(m.Digest)), m.Flags, m.Algorithm}
+	return append(b, m.Digest...)
+}
+
+// TranslateName converts an attribute name from the namespace from to the
+// namespace to. It returns false if name is not in from.
+func TranslateName(name string, from, to Namespace) (string, bool) {
+	if !strings.HasPrefix(name, string(from)) {
+		return "", false
+	}
+	return string(to) + name[len(from):], true
+}
+
+// Translate moves the overlayfs attributes of path from the namespace from
+// to the namespace to. Existing attributes in to are replaced.
+func Translate(path string, from, to Namespace) error {
+	names, err := walk.List(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		newName, ok := TranslateName(name, from, to)
+		if !ok {
+			continue
+		}
+		value, err := xattr.LGet(path, name)
+		if err != nil {
+			if walk.Vanished(err) {
+				continue
+			}
+			return err
+		}
+		if err := xattr.LSet(path, newName, value); err != nil {
+			return err
+		}
+		if err := xattr.LRemove(path, name); err != nil && !walk.Vanished(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// TranslateTree translates the overlayfs attributes of every file and
+// directory in the tree rooted at root, for example when moving a layer
+// between a privileged and a rootless setup. It returns the number of
+// files that had attributes in from.
+func TranslateTree(root string, from, to Namespace) (int, error) {
+	if from == to {
+		return 0, fmt.Errorf("overlay: cannot translate %s to itself", from)
+	}
+	n := 0
+	err := walk.Walk(root, func(path, _ string, info os.FileInfo) error {
+		names, err := walk.List(path)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, string(from)) {
+				n++
+				return Translate(path, from, to)
+			}
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,123 @@
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+// origin is an ext4 file handle of type FILEID_INO32_GEN for inode 12,
+// generation 0x1234.
+var origin = []byte{
+	0x00, 0xfb, 0x1d, 0x00, 0x01,
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10,
+	0x0c, 0x00, 0x00, 0x00, 0x34, 0x12, 0x00, 0x00,
+}
+
+func TestFileHandle(t *testing.T) {
+	fh, err := ParseFileHandle(origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fh.Type != 1 || fh.UUID[0] != 1 || fh.UUID[15] != 0x10 || len(fh.FID) != 8 {
+		t.Errorf("ParseFileHandle = %+v", fh)
+	}
+	if ino, gen, ok := fh.Inode(); !ok || ino != 12 || gen != 0x1234 {
+		t.Errorf("Inode = %d, %#x, %v", ino, gen, ok)
+	}
+	if !bytes.Equal(fh.Encode(), origin) {
+		t.Errorf("Encode = % x", fh.Encode())
+	}
+	if fh, err := ParseFileHandle(nil); err != nil || !fh.Empty() || len(fh.Encode()) != 0 {
+		t.Errorf("empty origin: %+v, %v", fh, err)
+	}
+	for _, bad := range [][]byte{origin[:20], origin[:25], append([]byte{1}, origin[1:]...)} {
+		if _, err := ParseFileHandle(bad); err != ErrFormat {
+			t.Errorf("ParseFileHandle(% x) = %v", bad, err)
+		}
+	}
+}
+
+func TestMetacopy(t *testing.T) {
+	m := &MetacopyInfo{Algorithm: DigestSHA256, Digest: bytes.Repeat([]byte{0xab}, 32)}
+	b := m.Encode()
+	if len(b) != 36 || b[1] != 36 || b[3] != DigestSHA256 {
+		t.Errorf("Encode = % x", b)
+	}
+	if got, err := ParseMetacopy(b); err != nil || !reflect.DeepEqual(got, m) {
+		t.Errorf("ParseMetacopy = %+v, %v", got, err)
+	}
+	if got, err := ParseMetacopy(nil); err != nil || got.Algorithm != 0 || len(got.Encode()) != 0 {
+		t.Errorf("empty metacopy: %+v, %v", got, err)
+	}
+	if _, err := ParseMetacopy(b[:20]); err != ErrFormat {
+		t.Errorf("ParseMetacopy of truncated value = %v", err)
+	}
+}
+
+func TestTranslateName(t *testing.T) {
+	if name, ok := TranslateName("trusted.overlay.opaque", Trusted, User); !ok || name != "user.overlay.opaque" {
+		t.Errorf("TranslateName = %q, %v", name, ok)
+	}
+	if _, ok := TranslateName("user.other", User, Trusted); ok {
+		t.Error("TranslateName accepted a name outside the namespace")
+	}
+}
+
+func TestReadTranslate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-overlay-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	xattrtest.Check(t, SetOpaque(sub, User))
+	if err := SetRedirect(sub, User, "/old/sub"); err != nil {
+		t.Fatal(err)
+	}
+	if err := xattr.LSet(sub, User.Name(Origin), origin); err != nil {
+		t.Fatal(err)
+	}
+	if err := xattr.LSet(sub, "user.unrelated", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Read(sub, User)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Opaque || a.Redirect != "/old/sub" || a.Origin == nil || a.Origin.Type != 1 || a.Metacopy != nil || a.Impure {
+		t.Errorf("Read = %+v", a)
+	}
+	if a, err := Read(dir, User); err != nil || !reflect.DeepEqual(a, &Attrs{}) {
+		t.Errorf("Read of plain directory = %+v, %v", a, err)
+	}
+
+	n, err := TranslateTree(dir, User, Trusted)
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("trusted namespace needs CAP_SYS_ADMIN")
+	}
+	if err != nil || n != 1 {
+		t.Fatalf("TranslateTree = %d, %v", n, err)
+	}
+	if opaque, err := IsOpaque(sub, Trusted); err != nil || !opaque {
+		t.Errorf("IsOpaque = %v, %v", opaque, err)
+	}
+	names, _ := walk.List(sub)
+	want := []string{"trusted.overlay.opaque", "trusted.overlay.origin", "trusted.overlay.redirect", "user.unrelated"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("attributes after translation: %q", names)
+	}
+}
@@ -0,0 +1,270 @@
+package overlay
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// OCI layers mark deleted files with an empty ".wh.<name>" file and opaque
+// directories with an empty ".wh..wh..opq" file inside them. overlayfs uses
+// a character device with device number 0/0 in place of the deleted file,
+// and the opaque attribute on the directory.
+const (
+	WhiteoutPrefix = ".wh."
+	OpaqueWhiteout = WhiteoutPrefix + WhiteoutPrefix + ".opq"
+)
+
+// paxXattr is the prefix of PAX records holding extended attributes.
+const paxXattr = "SCHILY.xattr."
+
+// ErrWhiteouts is returned on platforms where overlayfs whiteouts cannot be
+// created or detected.
+var ErrWhiteouts = errors.New("overlay: whiteout devices are not supported on this platform")
+
+// Stats counts the whiteouts and opaque directories a conversion handled.
+type Stats struct {
+	Whiteouts int
+	Opaque    int
+}
+
+// ToOverlay converts the OCI whiteout files in the tree rooted at root to
+// their overlayfs form, marking opaque directories with the opaque
+// attribute in the namespace ns. Creating whiteout devices requires
+// CAP_MKNOD.
+func ToOverlay(root string, ns Namespace) (Stats, error) {
+	return toOverlay(root, ns, mkWhiteout)
+}
+
+// ToOverlayXattr is like ToOverlay but creates xattr whiteouts, which need
+// no privileges with the User namespace.
+func ToOverlayXattr(root string, ns Namespace) (Stats, error) {
+	return toOverlay(root, ns, func(p string) error {
+		return MakeWhiteout(p, ns)
+	})
+}
+
+func toOverlay(root string, ns Namespace, mk func(path string) error) (Stats, error) {
+	var st Stats
+	err := walk.Walk(root, func(p, _ string, info os.FileInfo) error {
+		name := filepath.Base(p)
+		if info.IsDir() || !strings.HasPrefix(name, WhiteoutPrefix) {
+			return nil
+		}
+		dir := filepath.Dir(p)
+		if name == OpaqueWhiteout {
+			if err := SetOpaque(dir, ns); err != nil {
+				return err
+			}
+			st.Opaque++
+		} else {
+			if err := mk(filepath.Join(dir, name[len(WhiteoutPrefix):])); err != nil {
+				return err
+			}
+			st.Whiteouts++
+		}
+		return os.Remove(p)
+	})
+	return st, err
+}
+
+// ToOCI converts the overlayfs whiteouts of either form and the opaque
+// directories in the tree rooted at root, with the attributes in the
+// namespace ns, to OCI whiteout files.
+func ToOCI(root string, ns Namespace) (Stats, error) {
+	var st Stats
+	err := walk.Walk(root, func(p, _ string, info os.FileInfo) error {
+		if info.IsDir() {
+			value, err := xattr.LGet(p, ns.Name(Opaque))
+			if errors.Is(err, xattr.ENOATTR) || walk.Vanished(err) || walk.Unsupported(err) {
+				return removeAttr(p, ns.Name(Whiteouts))
+			}
+			if err != nil {
+				return err
+			}
+			if string(value) == "y" {
+				if err := touch(filepath.Join(p, OpaqueWhiteout)); err != nil {
+					return err
+				}
+				st.Opaque++
+			}
+			if err := removeAttr(p, ns.Name(Opaque)); err != nil {
+				return err
+			}
+			return removeAttr(p, ns.Name(Whiteouts))
+		}
+		whiteout, err := whiteoutInfo(p, info, ns)
+		if err != nil || !whiteout {
+			return err
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		st.Whiteouts++
+		return touch(filepath.Join(filepath.Dir(p), WhiteoutPrefix+filepath.Base(p)))
+	})
+	return st, err
+}
+
+// removeAttr removes the attribute name of p if it is set.
+func removeAttr(p, name string) error {
+	err := xattr.LRemove(p, name)
+	if walk.Vanished(err) || walk.Unsupported(err) {
+		return nil
+	}
+	return err
+}
+
+func touch(p string) error {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ToOverlayTar copies the tar stream r to w, converting OCI whiteout files
+// to whiteout devices and opaque directory markers to the opaque attribute
+// of the directory in the namespace ns. As the marker may follow the
+// directory entry, the directory is written again with the attribute.
+func ToOverlayTar(r io.Reader, w io.Writer, ns Namespace) (Stats, error) {
+	var st Stats
+	dirs := map[string]*tar.Header{}
+	err := copyTar(r, w, func(tw *tar.Writer, hdr *tar.Header) (bool, error) {
+		if hdr.Typeflag == tar.TypeDir {
+			dirs[path.Clean(hdr.Name)] = hdr
+			return false, nil
+		}
+		dir, name := path.Split(hdr.Name)
+		if !strings.HasPrefix(name, WhiteoutPrefix) {
+			return false, nil
+		}
+		if name == OpaqueWhiteout {
+			dirHdr := &tar.Header{Typeflag: tar.TypeDir, Mode: 0755, ModTime: hdr.ModTime, Format: tar.FormatPAX}
+			if h, ok := dirs[path.Clean(dir)]; ok {
+				copied := *h
+				dirHdr = &copied
+			}
+			dirHdr.Name = dir
+			if dir == "" {
+				dirHdr.Name = "./"
+			}
+			dirHdr.PAXRecords = copyRecords(dirHdr.PAXRecords)
+			dirHdr.PAXRecords[paxXattr+ns.Name(Opaque)] = "y"
+			dirHdr.Format = tar.FormatPAX
+			st.Opaque++
+			return true, tw.WriteHeader(dirHdr)
+		}
+		st.Whiteouts++
+		return true, tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeChar,
+			Name:     dir + name[len(WhiteoutPrefix):],
+			ModTime:  hdr.ModTime,
+			Uid:      hdr.Uid,
+			Gid:      hdr.Gid,
+			Format:   hdr.Format,
+		})
+	})
+	return st, err
+}
+
+// ToOCITar copies the tar stream r to w, converting whiteouts of either
+// form to OCI whiteout files and the opaque attribute in the namespace ns
+// to an opaque marker after the directory entry.
+func ToOCITar(r io.Reader, w io.Writer, ns Namespace) (Stats, error) {
+	var st Stats
+	key := paxXattr + ns.Name(Opaque)
+	whiteoutsKey := paxXattr + ns.Name(Whiteouts)
+	err := copyTar(r, w, func(tw *tar.Writer, hdr *tar.Header) (bool, error) {
+		if _, marked := hdr.PAXRecords[whiteoutsKey]; marked || hdr.PAXRecords[key] == opaqueWhiteouts {
+			// OCI layers have no use for the markers of directories
+			// containing xattr whiteouts.
+			hdr.PAXRecords = copyRecords(hdr.PAXRecords)
+			delete(hdr.PAXRecords, whiteoutsKey)
+			if hdr.PAXRecords[key] == opaqueWhiteouts {
+				delete(hdr.PAXRecords, key)
+			}
+			hdr.Xattrs = nil
+		}
+		_, xattrWhiteout := hdr.PAXRecords[paxXattr+ns.Name(Whiteout)]
+		switch {
+		case hdr.Typeflag == tar.TypeDir && hdr.PAXRecords[key] == "y":
+			hdr.PAXRecords = copyRecords(hdr.PAXRecords)
+			delete(hdr.PAXRecords, key)
+			// The writer merges the deprecated Xattrs field into the PAX
+			// records; the reader fills in both.
+			hdr.Xattrs = nil
+			if err := tw.WriteHeader(hdr); err != nil {
+				return true, err
+			}
+			st.Opaque++
+			return true, tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     path.Join(hdr.Name, OpaqueWhiteout),
+				Mode:     0644,
+				ModTime:  hdr.ModTime,
+				Format:   hdr.Format,
+			})
+		case hdr.Typeflag == tar.TypeChar && hdr.Devmajor == 0 && hdr.Devminor == 0,
+			hdr.Typeflag == tar.TypeReg && hdr.Size == 0 && xattrWhiteout:
+			dir, name := path.Split(strings.TrimSuffix(hdr.Name, "/"))
+			st.Whiteouts++
+			return true, tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     dir + WhiteoutPrefix + name,
+				Mode:     0644,
+				ModTime:  hdr.ModTime,
+				Uid:      hdr.Uid,
+				Gid:      hdr.Gid,
+				Format:   hdr.Format,
+			})
+		}
+		return false, nil
+	})
+	return st, err
+}
+
+// copyTar copies the entries of r to w. convert may write its own
+// replacement for an entry and return true, in which case the entry and its
+// content are dropped.
+func copyTar(r io.Reader, w io.Writer, convert func(tw *tar.Writer, hdr *tar.Header) (bool, error)) error {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		done, err := convert(tw, hdr)
+		if err != nil {
+			return err
+		}
+		if done {
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func copyRecords(records map[string]string) map[string]string {
+	c := make(map[string]string, len(records)+1)
+	for k, v := range records {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package overlay
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// mkWhiteout creates an overlayfs whiteout device at path.
+func mkWhiteout(path string) error {
+	if err := unix.Mknod(path, unix.S_IFCHR, 0); err != nil {
+		return &os.PathError{Op: "mknod", Path: path, Err: err}
+	}
+	return nil
+}
+
+// isWhiteout reports whether info describes an overlayfs whiteout device.
+func isWhiteout(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && info.Mode()&os.ModeCharDevice != 0 && st.Rdev == 0
+}
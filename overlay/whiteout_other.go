@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package overlay
+
+import "os"
+
+func mkWhiteout(path string) error {
+	return &os.PathError{Op: "mknod", Path: path, Err: ErrWhiteouts}
+}
+
+func isWhiteout(info os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,236 @@
+package overlay
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/xattr/internal/walk"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func writeTar(t *testing.T, hdrs ...*tar.Header) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range hdrs {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			tw.Write(bytes.Repeat([]byte("x"), int(hdr.Size)))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// entry is the part of a tar header the tests look at.
+type entry struct {
+	Name     string
+	Type     byte
+	Opaque   string
+	Contents string
+}
+
+func readTar(t *testing.T, r io.Reader) []entry {
+	var entries []entry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(tr)
+		entries = append(entries, entry{hdr.Name, hdr.Typeflag, hdr.PAXRecords[paxXattr+"user.overlay.opaque"], string(data)})
+	}
+}
+
+func TestTar(t *testing.T) {
+	mtime := time.Unix(1600000000, 0)
+	oci := writeTar(t,
+		&tar.Header{Typeflag: tar.TypeDir, Name: "etc/", Mode: 0700, ModTime: mtime},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "etc/.wh..wh..opq", ModTime: mtime},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "etc/passwd", Size: 3, ModTime: mtime},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "usr/.wh.old", ModTime: mtime},
+	)
+	var ovl bytes.Buffer
+	st, err := ToOverlayTar(bytes.NewReader(oci.Bytes()), &ovl, User)
+	if err != nil || st != (Stats{Whiteouts: 1, Opaque: 1}) {
+		t.Fatalf("ToOverlayTar = %+v, %v", st, err)
+	}
+	want := []entry{
+		{"etc/", tar.TypeDir, "", ""},
+		{"etc/", tar.TypeDir, "y", ""},
+		{"etc/passwd", tar.TypeReg, "", "xxx"},
+		{"usr/old", tar.TypeChar, "", ""},
+	}
+	if got := readTar(t, bytes.NewReader(ovl.Bytes())); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToOverlayTar:\n got %v\nwant %v", got, want)
+	}
+
+	var back bytes.Buffer
+	st, err = ToOCITar(&ovl, &back, User)
+	if err != nil || st != (Stats{Whiteouts: 1, Opaque: 1}) {
+		t.Fatalf("ToOCITar = %+v, %v", st, err)
+	}
+	want = []entry{
+		{"etc/", tar.TypeDir, "", ""},
+		{"etc/", tar.TypeDir, "", ""},
+		{"etc/.wh..wh..opq", tar.TypeReg, "", ""},
+		{"etc/passwd", tar.TypeReg, "", "xxx"},
+		{"usr/.wh.old", tar.TypeReg, "", ""},
+	}
+	if got := readTar(t, &back); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToOCITar:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-whiteout-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	etc := filepath.Join(dir, "etc")
+	if err := os.Mkdir(etc, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"etc/.wh..wh..opq", "etc/passwd", ".wh.old"} {
+		if err := touch(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := ToOverlay(dir, User)
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, ErrWhiteouts) {
+		t.Skip("cannot create whiteout devices")
+	}
+	xattrtest.Check(t, err)
+	if st != (Stats{Whiteouts: 1, Opaque: 1}) {
+		t.Errorf("ToOverlay = %+v", st)
+	}
+	if opaque, _ := IsOpaque(etc, User); !opaque {
+		t.Error("etc is not opaque")
+	}
+	info, err := os.Lstat(filepath.Join(dir, "old"))
+	if err != nil || !isWhiteout(info) {
+		t.Errorf("old is not a whiteout: %v", err)
+	}
+
+	st, err = ToOCI(dir, User)
+	if err != nil || st != (Stats{Whiteouts: 1, Opaque: 1}) {
+		t.Fatalf("ToOCI = %+v, %v", st, err)
+	}
+	var names []string
+	walk.Walk(dir, func(_, rel string, _ os.FileInfo) error {
+		names = append(names, rel)
+		return nil
+	})
+	if want := []string{".", ".wh.old", "etc", "etc/.wh..wh..opq", "etc/passwd"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("tree after ToOCI: %q", names)
+	}
+	if opaque, _ := IsOpaque(etc, User); opaque {
+		t.Error("etc is still opaque")
+	}
+}
+
+func TestXattrWhiteoutTar(t *testing.T) {
+	mtime := time.Unix(1600000000, 0)
+	ovl := writeTar(t,
+		&tar.Header{Typeflag: tar.TypeDir, Name: "usr/", Mode: 0755, ModTime: mtime, PAXRecords: map[string]string{
+			paxXattr + "user.overlay.opaque":    "x",
+			paxXattr + "user.overlay.whiteouts": "",
+		}},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "usr/old", ModTime: mtime, PAXRecords: map[string]string{
+			paxXattr + "user.overlay.whiteout": "",
+		}},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "usr/empty", ModTime: mtime},
+	)
+	var oci bytes.Buffer
+	st, err := ToOCITar(ovl, &oci, User)
+	if err != nil || st != (Stats{Whiteouts: 1}) {
+		t.Fatalf("ToOCITar = %+v, %v", st, err)
+	}
+	tr := tar.NewReader(&oci)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hdr.PAXRecords) != 0 {
+			t.Errorf("%s keeps %v", hdr.Name, hdr.PAXRecords)
+		}
+		names = append(names, hdr.Name)
+	}
+	if want := []string{"usr/", "usr/.wh.old", "usr/empty"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ToOCITar wrote %q", names)
+	}
+}
+
+func TestXattrWhiteoutTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-whiteout-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	usr := filepath.Join(dir, "usr")
+	if err := os.Mkdir(usr, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"usr/.wh.old", "usr/empty"} {
+		if err := touch(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := ToOverlayXattr(dir, User)
+	xattrtest.Check(t, err)
+	if st != (Stats{Whiteouts: 1}) {
+		t.Fatalf("ToOverlayXattr = %+v", st)
+	}
+	old := filepath.Join(usr, "old")
+	if a, err := Read(old, User); err != nil || !a.Whiteout {
+		t.Errorf("Read(old) = %+v, %v", a, err)
+	}
+	if a, err := Read(usr, User); err != nil || !a.Whiteouts || a.Opaque {
+		t.Errorf("Read(usr) = %+v, %v", a, err)
+	}
+	for name, want := range map[string]bool{"old": true, "empty": false} {
+		if ok, err := IsWhiteout(filepath.Join(usr, name), User); err != nil || ok != want {
+			t.Errorf("IsWhiteout(%s) = %v, %v", name, ok, err)
+		}
+	}
+
+	st, err = ToOCI(dir, User)
+	if err != nil || st != (Stats{Whiteouts: 1}) {
+		t.Fatalf("ToOCI = %+v, %v", st, err)
+	}
+	var names []string
+	walk.Walk(dir, func(_, rel string, _ os.FileInfo) error {
+		names = append(names, rel)
+		return nil
+	})
+	if want := []string{".", "usr", "usr/.wh.old", "usr/empty"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("tree after ToOCI: %q", names)
+	}
+	if a, err := Read(usr, User); err != nil || !reflect.DeepEqual(a, &Attrs{}) {
+		t.Errorf("markers left on usr: %+v, %v", a, err)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Package overridestat reads and writes the ownership and mode overrides that
+rootless container storage keeps in extended attributes, because an
+unprivileged user cannot chown files:
+
+	user.containers.override_stat     containers/storage and fuse-overlayfs
+	user.fuseoverlayfs.override_stat  older fuse-overlayfs versions
+
+The value has the form "uid:gid:mode", with the mode in octal, optionally
+followed by ":type" where type is "file", "dir", "symlink", "pipe",
+"socket", "block-<major>-<minor>" or "char-<major>-<minor>".
+
+RecordTree turns the real ownership of a tree into overrides, so that a
+layer built as root can be used rootless; ApplyTree does the reverse.
+*/
+package overridestat
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// Attribute names, in the order Get looks them up.
+const (
+	ContainersAttr    = "user.containers.override_stat"
+	FuseOverlayfsAttr = "user.fuseoverlayfs.override_stat"
+)
+
+// ErrFormat is returned for values that cannot be parsed.
+var ErrFormat = errors.New("overridestat: invalid override_stat value")
+
+// Stat is the ownership and mode recorded for a file.
+type Stat struct {
+	UID int
+	GID int
+	// Mode holds the permission bits, including the setuid, setgid and
+	// sticky bits, as in chmod(2).
+	Mode uint32
+	// Type is the optional file type field, or "" if it is absent.
+	Type string
+}
+
+// Parse parses an override_stat value.
+func Parse(value string) (Stat, error) {
+	fields := strings.Split(strings.TrimRight(value, "\x00"), ":")
+	if len(fields) != 3 && len(fields) != 4 {
+		return Stat{}, ErrFormat
+	}
+	uid, err1 := strconv.ParseUint(fields[0], 10, 32)
+	gid, err2 := strconv.ParseUint(fields[1], 10, 32)
+	mode, err3 := strconv.ParseUint(fields[2], 8, 32)
+	if err1 != nil || err2 != nil || err3 != nil || mode&^07777 != 0 {
+		return Stat{}, ErrFormat
+	}
+	st := Stat{UID: int(uid), GID: int(gid), Mode: uint32(mode)}
+	if len(fields) == 4 {
+		if fields[3] == "" {
+			return Stat{}, ErrFormat
+		}
+		st.Type = fields[3]
+	}
+	return st, nil
+}
+
+// String returns st in the override_stat format.
+func (st Stat) String() string {
+	s := fmt.Sprintf("%d:%d:0%o", st.UID, st.GID, st.Mode)
+	if st.Type != "" {
+		s += ":" + st.Type
+	}
+	return s
+}
+
+// FileMode returns the permission bits of st as an os.FileMode.
+func (st Stat) FileMode() os.FileMode {
+	m := os.FileMode(st.Mode & 0777)
+	if st.Mode&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if st.Mode&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if st.Mode&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+// unixMode converts the permission bits of m to chmod(2) bits.
+func unixMode(m os.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+	return mode
+}
+
+// typeName returns the type field for info, which includes the device
+// number for devices.
+func typeName(info os.FileInfo) string {
+	m := info.Mode()
+	switch {
+	case m.IsDir():
+		return "dir"
+	case m&os.ModeSymlink != 0:
+		return "symlink"
+	case m&os.ModeNamedPipe != 0:
+		return "pipe"
+	case m&os.ModeSocket != 0:
+		return "socket"
+	case m&os.ModeDevice != 0:
+		kind := "block"
+		if m&os.ModeCharDevice != 0 {
+			kind = "char"
+		}
+		major, minor, _ := device(info)
+		return fmt.Sprintf("%s-%d-%d", kind, major, minor)
+	}
+	return "file"
+}
+
+// Get returns the override of path, looking at ContainersAttr first and
+// FuseOverlayfsAttr second. It fails with ENOATTR if neither is set.
+func Get(path string) (Stat, error) {
+	var err error
+	for _, name := range []string{ContainersAttr, FuseOverlayfsAttr} {
+		var value []byte
+		value, err = xattr.LGet(path, name)
+		if err == nil {
+			return Parse(string(value))
+		}
+		if !errors.Is(err, xattr.ENOATTR) {
+			return Stat{}, err
+		}
+	}
+	return Stat{}, err
+}
+
+// Set records st as the override of path in ContainersAttr.
+func Set(path string, st Stat) error {
+	return xattr.LSet(path, ContainersAttr, []byte(st.String()))
+}
+
+// Real returns the ownership and mode of path from the filesystem, without
+// following a symlink at the end of path.
+func Real(path string) (Stat, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Stat{}, err
+	}
+	return fromInfo(path, info)
+}
+
+func fromInfo(path string, info os.FileInfo) (Stat, error) {
+	uid, gid, ok := owner(info)
+	if !ok {
+		return Stat{}, &os.PathError{Op: "overridestat", Path: path, Err: xattr.ENOTSUP}
+	}
+	return Stat{UID: uid, GID: gid, Mode: unixMode(info.Mode()), Type: typeName(info)}, nil
+}
+
+// Effective returns the override of path if it has one, and its real
+// ownership and mode otherwise.
+func Effective(path string) (Stat, error) {
+	st, err := Get(path)
+	if errors.Is(err, xattr.ENOATTR) || walk.Unsupported(err) {
+		return Real(path)
+	}
+	return st, err
+}
+
+// Options control RecordTree.
+type Options struct {
+	// Chown changes the owner of every file to UID and GID after
+	// recording its real ownership. This requires privileges unless the
+	// files already belong to UID and GID.
+	Chown    bool
+	UID, GID int
+}
+
+// RecordTree records the real ownership and mode of every regular file and
+// directory in the tree rooted at root as its override. Existing overrides
+// are replaced. Other entries, such as symlinks and device nodes, get no
+// override, since Linux does not allow user.* attributes on them; with
+// Options.Chown they are chowned all the same, without following symlinks,
+// so that no entry keeps its old owner. It returns the number of files
+// recorded.
+func RecordTree(root string, opts Options) (int, error) {
+	n := 0
+	err := walk.Walk(root, func(path, _ string, info os.FileInfo) error {
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			if opts.Chown {
+				return os.Lchown(path, opts.UID, opts.GID)
+			}
+			return nil
+		}
+		st, err := fromInfo(path, info)
+		if err != nil {
+			return err
+		}
+		if err := Set(path, st); err != nil {
+			return err
+		}
+		if opts.Chown {
+			if err := os.Lchown(path, opts.UID, opts.GID); err != nil {
+				return err
+			}
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// ApplyTree gives every file and directory in the tree rooted at root that
+// has an override the recorded owner and mode, and removes the override.
+// Modes are not applied to symlinks. It returns the number of files
+// changed.
+func ApplyTree(root string) (int, error) {
+	n := 0
+	err := walk.Walk(root, func(path, _ string, info os.FileInfo) error {
+		st, err := Get(path)
+		if errors.Is(err, xattr.ENOATTR) || walk.Unsupported(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.Lchown(path, st.UID, st.GID); err != nil {
+			return err
+		}
+		// chown clears the setuid and setgid bits, so chmod comes last.
+		if info.Mode()&os.ModeSymlink == 0 {
+			if err := os.Chmod(path, st.FileMode()); err != nil {
+				return err
+			}
+		}
+		for _, name := range []string{ContainersAttr, FuseOverlayfsAttr} {
+			if err := xattr.LRemove(path, name); err != nil && !errors.Is(err, xattr.ENOATTR) {
+				return err
+			}
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
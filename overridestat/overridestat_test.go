@@ -0,0 +1,143 @@
+package overridestat
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestParse(t *testing.T) {
+	for value, want := range map[string]Stat{
+		"0:0:0755":          {0, 0, 0755, ""},
+		"1000:100:644":      {1000, 100, 0644, ""},
+		"0:0:04755:file":    {0, 0, 04755, "file"},
+		"0:5:0620:char-4-1": {0, 5, 0620, "char-4-1"},
+	} {
+		st, err := Parse(value)
+		if err != nil || st != want {
+			t.Errorf("Parse(%q) = %+v, %v", value, st, err)
+		}
+	}
+	if s := (Stat{0, 0, 04755, "file"}).String(); s != "0:0:04755:file" {
+		t.Errorf("String = %q", s)
+	}
+	if m := (Stat{Mode: 01777}).FileMode(); m != os.ModeSticky|0777 {
+		t.Errorf("FileMode = %v", m)
+	}
+	for _, bad := range []string{"", "0:0", "0:0:0:", "a:0:0", "0:0:9", "0:0:0100000", "-1:0:0"} {
+		if _, err := Parse(bad); err != ErrFormat {
+			t.Errorf("Parse(%q) = %v", bad, err)
+		}
+	}
+}
+
+func TestRecordApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-overridestat-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(file, 0640); err != nil {
+		t.Fatal(err)
+	}
+	orig, err := Real(file)
+	if err != nil {
+		t.Skip(err)
+	}
+	if orig.Mode != 0640 || orig.Type != "file" {
+		t.Errorf("Real = %+v", orig)
+	}
+
+	// Symlinks cannot carry user.* attributes and get no override.
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := RecordTree(dir, Options{})
+	xattrtest.Check(t, err)
+	if n != 2 {
+		t.Errorf("RecordTree recorded %d files", n)
+	}
+	if _, err := Get(link); err == nil {
+		t.Error("RecordTree recorded the symlink")
+	}
+	if st, err := Get(file); err != nil || st != orig {
+		t.Errorf("Get = %+v, %v", st, err)
+	}
+
+	// Change the recorded mode and apply it.
+	if err := Set(file, Stat{orig.UID, orig.GID, 0600, "file"}); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := Effective(file); st.Mode != 0600 {
+		t.Errorf("Effective mode = %o", st.Mode)
+	}
+	if n, err := ApplyTree(dir); err != nil || n != 2 {
+		t.Fatalf("ApplyTree = %d, %v", n, err)
+	}
+	info, _ := os.Stat(file)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode after ApplyTree = %v", info.Mode())
+	}
+	if _, err := Get(file); !errors.Is(err, xattr.ENOATTR) {
+		t.Errorf("override not removed: %v", err)
+	}
+	if st, err := Effective(file); err != nil || st.Mode != 0600 {
+		t.Errorf("Effective without override = %+v, %v", st, err)
+	}
+
+	// The older attribute name is read as well.
+	if err := xattr.LSet(file, FuseOverlayfsAttr, []byte("1:2:0700")); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := Get(file); err != nil || st != (Stat{1, 2, 0700, ""}) {
+		t.Errorf("Get of fuse-overlayfs attribute = %+v, %v", st, err)
+	}
+}
+
+func TestRecordChown(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("chown requires root")
+	}
+	dir, err := ioutil.TempDir("", "xattr-overridestat-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatal(err)
+	}
+	orig, err := Real(file)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	n, err := RecordTree(dir, Options{Chown: true, UID: 1234, GID: 5678})
+	xattrtest.Check(t, err)
+	if n != 2 {
+		t.Errorf("RecordTree recorded %d files", n)
+	}
+	for _, path := range []string{dir, file, link} {
+		if st, err := Real(path); err != nil || st.UID != 1234 || st.GID != 5678 {
+			t.Errorf("Real(%s) = %+v, %v", path, st, err)
+		}
+	}
+	if st, err := Get(file); err != nil || st.UID != orig.UID || st.GID != orig.GID {
+		t.Errorf("Get = %+v, %v", st, err)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || solaris || dragonfly
+// +build linux darwin freebsd netbsd openbsd solaris dragonfly
+
+package overridestat
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func owner(info os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+func device(info os.FileInfo) (major, minor uint32, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return unix.Major(uint64(st.Rdev)), unix.Minor(uint64(st.Rdev)), true
+}
@@ -0,0 +1,177 @@
+package posixacl
+
+import "os"
+
+// File holds what the access check needs to know about a file. It can be
+// filled from data captured on another machine.
+type File struct {
+	UID, GID uint32
+	Mode     os.FileMode
+	// ACL is the access ACL, or nil if the file has none and only Mode
+	// applies. When a file has an ACL, the kernel keeps the permission
+	// bits of Mode in sync with it, and the ACL is used.
+	ACL ACL
+}
+
+// Credentials identify the process asking for access.
+type Credentials struct {
+	UID uint32
+	// Groups are the primary and supplementary group IDs.
+	Groups []uint32
+}
+
+func (c Credentials) inGroup(gid uint32) bool {
+	for _, g := range c.Groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// FromMode returns the minimal ACL that is equivalent to the permission
+// bits of mode.
+func FromMode(mode os.FileMode) ACL {
+	return ACL{
+		{Tag: UserObj, Perm: uint16(mode>>6) & 7, ID: UndefinedID},
+		{Tag: GroupObj, Perm: uint16(mode>>3) & 7, ID: UndefinedID},
+		{Tag: Other, Perm: uint16(mode) & 7, ID: UndefinedID},
+	}
+}
+
+// Mode returns the permission bits the kernel derives from acl: the owner
+// entry, the mask entry or, without one, the owning group entry, and the
+// other entry.
+func (acl ACL) Mode() os.FileMode {
+	var user, group, mask, other uint16
+	hasMask := false
+	for _, e := range acl {
+		switch e.Tag {
+		case UserObj:
+			user = e.Perm
+		case GroupObj:
+			group = e.Perm
+		case Mask:
+			mask, hasMask = e.Perm, true
+		case Other:
+			other = e.Perm
+		}
+	}
+	if hasMask {
+		group = mask
+	}
+	return os.FileMode(user&7)<<6 | os.FileMode(group&7)<<3 | os.FileMode(other&7)
+}
+
+// Minimal reports whether acl has only the owner, owning group and other
+// entries, so that the permission bits express it fully.
+func (acl ACL) Minimal() bool {
+	for _, e := range acl {
+		if e.Tag != UserObj && e.Tag != GroupObj && e.Tag != Other {
+			return false
+		}
+	}
+	return true
+}
+
+// Access reports whether c is granted all permission bits in want (a
+// combination of Read, Write and Execute), following the check of the
+// Linux kernel:
+//
+//   - The owner gets the owner entry.
+//   - A user with a named entry gets it, limited by the mask.
+//   - Otherwise, if the user is in the owning group or a named group, one
+//     of those entries that grants all of want is used, limited by the
+//     mask. If none does, access is denied, even if the other entry would
+//     allow it.
+//   - Everyone else gets the other entry.
+//
+// Capabilities such as CAP_DAC_OVERRIDE, which let root bypass the check,
+// are not considered.
+func (f *File) Access(c Credentials, want uint16) bool {
+	want &= Read | Write | Execute
+	acl := f.ACL
+	if acl == nil {
+		acl = FromMode(f.Mode)
+	}
+	sorted := append(ACL{}, acl...)
+	sorted.Sort()
+	mask := uint16(Read | Write | Execute)
+	if e, ok := sorted.Find(Mask, 0); ok {
+		mask = e.Perm
+	}
+	found := false
+	for _, e := range sorted {
+		switch e.Tag {
+		case UserObj:
+			if c.UID == f.UID {
+				return e.Perm&want == want
+			}
+		case User:
+			if c.UID == e.ID {
+				return e.Perm&mask&want == want
+			}
+		case GroupObj, Group:
+			gid := e.ID
+			if e.Tag == GroupObj {
+				gid = f.GID
+			}
+			if c.inGroup(gid) {
+				found = true
+				if e.Perm&want == want {
+					return e.Perm&mask&want == want
+				}
+			}
+		case Other:
+			if found {
+				return false
+			}
+			return e.Perm&want == want
+		}
+	}
+	return false
+}
+
+// Inherit returns the mode and ACLs of a file created with mode in a
+// directory with the default ACL def, as the kernel computes them.
+//
+// Without a default ACL, umask is applied to mode and the new file gets
+// no ACLs. Otherwise umask is ignored: the default ACL becomes the access
+// ACL, with the owner, other and mask entries (or the owning group entry,
+// if there is no mask) limited by the corresponding bits of mode, and the
+// resulting permission bits are taken from those entries. access is nil if
+// the result is minimal, since the permission bits then express it. A new
+// directory also gets def as its default ACL.
+func Inherit(def ACL, mode, umask os.FileMode, dir bool) (newMode os.FileMode, access, newDef ACL) {
+	if len(def) == 0 {
+		return mode &^ (umask & os.ModePerm), nil, nil
+	}
+	access = append(ACL{}, def...)
+	var group, mask *Entry
+	for i := range access {
+		e := &access[i]
+		switch e.Tag {
+		case UserObj:
+			e.Perm &= uint16(mode>>6) & 7
+		case GroupObj:
+			group = e
+		case Mask:
+			mask = e
+		case Other:
+			e.Perm &= uint16(mode) & 7
+		}
+	}
+	if mask != nil {
+		mask.Perm &= uint16(mode>>3) & 7
+	} else if group != nil {
+		group.Perm &= uint16(mode>>3) & 7
+	}
+	newMode = mode&^os.ModePerm | access.Mode()
+	if access.Minimal() {
+		access = nil
+	}
+	if dir {
+		newDef = append(ACL{}, def...)
+	}
+	return newMode, access, newDef
+}
@@ -0,0 +1,287 @@
+/*
+Package posixacl decodes and encodes POSIX access control lists as Linux
+stores them in the system.posix_acl_access and system.posix_acl_default
+attributes, and converts them to and from the short text form of
+getfacl and setfacl, such as "user::rw-,user:1000:r--,group::r--,mask::r--,other::---".
+
+File.Access evaluates an ACL the way the kernel checks access, and Inherit
+computes the ACL a new file gets from the default ACL of its directory.
+Both work on plain values, so they can be used on data captured elsewhere.
+*/
+package posixacl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/xattr"
+)
+
+// Attribute names.
+const (
+	AccessAttr  = "system.posix_acl_access"
+	DefaultAttr = "system.posix_acl_default"
+)
+
+// Tag is the type of an ACL entry.
+type Tag uint16
+
+// Entry tags, in the order entries are sorted.
+const (
+	UserObj  Tag = 0x01
+	User     Tag = 0x02
+	GroupObj Tag = 0x04
+	Group    Tag = 0x08
+	Mask     Tag = 0x10
+	Other    Tag = 0x20
+)
+
+// Permission bits.
+const (
+	Execute = 0x1
+	Write   = 0x2
+	Read    = 0x4
+)
+
+// UndefinedID is the ID of entries that do not name a user or group.
+const UndefinedID = 0xffffffff
+
+const (
+	version    = 2
+	headerSize = 4
+	entrySize  = 8
+)
+
+// ErrFormat is returned for values and text that are not valid ACLs.
+var ErrFormat = errors.New("posixacl: invalid ACL")
+
+// Entry is a single ACL entry. ID is the uid or gid for User and Group
+// entries and UndefinedID otherwise.
+type Entry struct {
+	Tag  Tag
+	Perm uint16
+	ID   uint32
+}
+
+// ACL is a list of entries.
+type ACL []Entry
+
+// Parse decodes an attribute value.
+func Parse(b []byte) (ACL, error) {
+	if len(b) < headerSize || (len(b)-headerSize)%entrySize != 0 || binary.LittleEndian.Uint32(b) != version {
+		return nil, ErrFormat
+	}
+	acl := make(ACL, 0, (len(b)-headerSize)/entrySize)
+	for p := b[headerSize:]; len(p) > 0; p = p[entrySize:] {
+		acl = append(acl, Entry{
+			Tag:  Tag(binary.LittleEndian.Uint16(p)),
+			Perm: binary.LittleEndian.Uint16(p[2:]),
+			ID:   binary.LittleEndian.Uint32(p[4:]),
+		})
+	}
+	return acl, nil
+}
+
+// Encode returns the attribute value of acl, with the entries sorted as the
+// kernel expects them.
+func (acl ACL) Encode() []byte {
+	sorted := append(ACL{}, acl...)
+	sorted.Sort()
+	b := make([]byte, headerSize, headerSize+len(sorted)*entrySize)
+	binary.LittleEndian.PutUint32(b, version)
+	for _, e := range sorted {
+		var buf [entrySize]byte
+		binary.LittleEndian.PutUint16(buf[:], uint16(e.Tag))
+		binary.LittleEndian.PutUint16(buf[2:], e.Perm)
+		binary.LittleEndian.PutUint32(buf[4:], e.ID)
+		b = append(b, buf[:]...)
+	}
+	return b
+}
+
+// Sort sorts the entries by tag, and named entries by ID.
+func (acl ACL) Sort() {
+	sort.SliceStable(acl, func(i, j int) bool {
+		if acl[i].Tag != acl[j].Tag {
+			return acl[i].Tag < acl[j].Tag
+		}
+		return acl[i].ID < acl[j].ID
+	})
+}
+
+// Find returns the entry with the tag and, for User and Group, the ID.
+func (acl ACL) Find(tag Tag, id uint32) (Entry, bool) {
+	for _, e := range acl {
+		if e.Tag == tag && (tag != User && tag != Group || e.ID == id) {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Valid reports whether acl is well formed: exactly one UserObj, GroupObj
+// and Other entry, no duplicate named entries, and a Mask entry if there
+// are named entries.
+func (acl ACL) Valid() bool {
+	counts := map[Tag]int{}
+	named := map[Entry]bool{}
+	for _, e := range acl {
+		switch e.Tag {
+		case User, Group:
+			key := Entry{Tag: e.Tag, ID: e.ID}
+			if named[key] {
+				return false
+			}
+			named[key] = true
+		case UserObj, GroupObj, Mask, Other:
+			counts[e.Tag]++
+		default:
+			return false
+		}
+		if e.Perm&^(Read|Write|Execute) != 0 {
+			return false
+		}
+	}
+	return counts[UserObj] == 1 && counts[GroupObj] == 1 && counts[Other] == 1 &&
+		counts[Mask] <= 1 && (len(named) == 0 || counts[Mask] == 1)
+}
+
+var tagNames = map[Tag]string{
+	UserObj:  "user",
+	User:     "user",
+	GroupObj: "group",
+	Group:    "group",
+	Mask:     "mask",
+	Other:    "other",
+}
+
+// PermString returns perm in the "rwx" form.
+func PermString(perm uint16) string {
+	b := []byte("---")
+	if perm&Read != 0 {
+		b[0] = 'r'
+	}
+	if perm&Write != 0 {
+		b[1] = 'w'
+	}
+	if perm&Execute != 0 {
+		b[2] = 'x'
+	}
+	return string(b)
+}
+
+// String returns acl in the short text form with numeric IDs.
+func (acl ACL) String() string {
+	parts := make([]string, len(acl))
+	for i, e := range acl {
+		qualifier := ""
+		if e.Tag == User || e.Tag == Group {
+			qualifier = strconv.FormatUint(uint64(e.ID), 10)
+		}
+		name, ok := tagNames[e.Tag]
+		if !ok {
+			name = fmt.Sprintf("tag%#x", uint16(e.Tag))
+		}
+		parts[i] = name + ":" + qualifier + ":" + PermString(e.Perm)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseText parses the short or long text form with numeric IDs. Entries
+// are separated by commas or newlines; comments and the single-letter tag
+// abbreviations of setfacl are accepted.
+func ParseText(s string) (ACL, error) {
+	var acl ACL
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, f := range fields {
+		if i := strings.IndexByte(f, '#'); i >= 0 {
+			f = f[:i]
+		}
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		parts := strings.Split(f, ":")
+		if len(parts) == 2 && (parts[0] == "other" || parts[0] == "o" || parts[0] == "mask" || parts[0] == "m") {
+			parts = []string{parts[0], "", parts[1]}
+		}
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%w: %q", ErrFormat, f)
+		}
+		var e Entry
+		named := parts[1] != ""
+		switch parts[0] {
+		case "user", "u":
+			e.Tag = UserObj
+			if named {
+				e.Tag = User
+			}
+		case "group", "g":
+			e.Tag = GroupObj
+			if named {
+				e.Tag = Group
+			}
+		case "mask", "m":
+			e.Tag = Mask
+		case "other", "o":
+			e.Tag = Other
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrFormat, f)
+		}
+		e.ID = UndefinedID
+		if named {
+			if e.Tag != User && e.Tag != Group {
+				return nil, fmt.Errorf("%w: %q", ErrFormat, f)
+			}
+			id, err := strconv.ParseUint(parts[1], 10, 32)
+			if err != nil || id == UndefinedID {
+				return nil, fmt.Errorf("%w: %q", ErrFormat, f)
+			}
+			e.ID = uint32(id)
+		}
+		perm, err := parsePerm(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrFormat, f)
+		}
+		e.Perm = perm
+		acl = append(acl, e)
+	}
+	return acl, nil
+}
+
+func parsePerm(s string) (uint16, error) {
+	var perm uint16
+	for _, c := range s {
+		switch c {
+		case 'r':
+			perm |= Read
+		case 'w':
+			perm |= Write
+		case 'x':
+			perm |= Execute
+		case '-':
+		default:
+			return 0, ErrFormat
+		}
+	}
+	return perm, nil
+}
+
+// Get reads the ACL stored in the attribute name (AccessAttr or
+// DefaultAttr) of path, without following a symlink at the end of path.
+func Get(path, name string) (ACL, error) {
+	b, err := xattr.LGet(path, name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Set stores acl in the attribute name of path.
+func Set(path, name string, acl ACL) error {
+	return xattr.LSet(path, name, acl.Encode())
+}
@@ -0,0 +1,154 @@
+package posixacl
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+// value is "user::rw-,user:1000:r--,group::r--,mask::r--,other::---" as
+// stored by Linux.
+var value = []byte{
+	0x02, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x06, 0x00, 0xff, 0xff, 0xff, 0xff,
+	0x02, 0x00, 0x04, 0x00, 0xe8, 0x03, 0x00, 0x00,
+	0x04, 0x00, 0x04, 0x00, 0xff, 0xff, 0xff, 0xff,
+	0x10, 0x00, 0x04, 0x00, 0xff, 0xff, 0xff, 0xff,
+	0x20, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff,
+}
+
+const text = "user::rw-,user:1000:r--,group::r--,mask::r--,other::---"
+
+func TestCodec(t *testing.T) {
+	acl, err := Parse(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.String() != text {
+		t.Errorf("String = %q", acl.String())
+	}
+	if !acl.Valid() {
+		t.Error("ACL is not valid")
+	}
+	if e, ok := acl.Find(User, 1000); !ok || e.Perm != Read {
+		t.Errorf("Find = %+v, %v", e, ok)
+	}
+	// Encode sorts the entries.
+	shuffled := ACL{acl[4], acl[1], acl[3], acl[0], acl[2]}
+	if !bytes.Equal(shuffled.Encode(), value) {
+		t.Errorf("Encode = % x", shuffled.Encode())
+	}
+	for _, bad := range [][]byte{nil, value[:10], append([]byte{3}, value[1:]...)} {
+		if _, err := Parse(bad); err != ErrFormat {
+			t.Errorf("Parse(% x) = %v", bad, err)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	acl, err := ParseText("# file: x\nu::rw-\nu:1000:r\ng::r--\nm::r\no::-\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.String() != text {
+		t.Errorf("ParseText = %q", acl.String())
+	}
+	if acl, err := ParseText("other:r,mask:rwx"); err != nil || acl.String() != "other::r--,mask::rwx" {
+		t.Errorf("ParseText of short entries = %v, %v", acl, err)
+	}
+	for _, bad := range []string{"user:alice:r", "user::rwz", "mask:1:r", "who::r", "user"} {
+		if _, err := ParseText(bad); !errors.Is(err, ErrFormat) {
+			t.Errorf("ParseText(%q) = %v", bad, err)
+		}
+	}
+	invalid, _ := ParseText("user::rw-,user:1:r,group::r,other::r")
+	if invalid.Valid() {
+		t.Error("ACL without mask is valid")
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	f, err := ioutil.TempFile("", "xattr-posixacl-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	acl, _ := Parse(value)
+	err = Set(f.Name(), AccessAttr, acl)
+	xattrtest.Skip(t, err, "filesystem does not support POSIX ACLs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Get(f.Name(), AccessAttr)
+	if err != nil || !reflect.DeepEqual(got, acl) {
+		t.Errorf("Get = %v, %v", got, err)
+	}
+}
+
+func TestAccess(t *testing.T) {
+	acl, err := ParseText("u::rw-,u:1001:rwx,g::r--,g:100:rw-,m::rw-,o::r--")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := File{UID: 1000, GID: 20, Mode: 0664, ACL: acl}
+	for _, c := range []struct {
+		uid    uint32
+		groups []uint32
+		want   uint16
+		ok     bool
+	}{
+		{1000, nil, Read | Write, true},
+		{1000, nil, Execute, false},
+		{1001, []uint32{20, 100}, Write, true},
+		{1001, nil, Execute, false}, // limited by the mask
+		{1002, []uint32{20}, Read, true},
+		{1002, []uint32{20}, Write, false},
+		{1002, []uint32{20, 100}, Write, true},
+		{1002, []uint32{20, 100}, Read | Write, true},
+		{1002, []uint32{20}, Execute, false},
+		{1003, nil, Read, true},
+		{1003, nil, Write, false},
+	} {
+		if ok := f.Access(Credentials{UID: c.uid, Groups: c.groups}, c.want); ok != c.ok {
+			t.Errorf("Access(%d %v, %s) = %v", c.uid, c.groups, PermString(c.want), ok)
+		}
+	}
+
+	// A matching group that does not grant access denies it, even if the
+	// other entry would allow it.
+	f = File{UID: 0, GID: 20, Mode: 0604}
+	if f.Access(Credentials{UID: 1000, Groups: []uint32{20}}, Read) {
+		t.Error("group member got the other entry")
+	}
+	if !f.Access(Credentials{UID: 1000, Groups: []uint32{30}}, Read) {
+		t.Error("other user denied")
+	}
+}
+
+func TestInherit(t *testing.T) {
+	def, _ := ParseText("u::rwx,g::r-x,g:100:rwx,m::rwx,o::r-x")
+	mode, access, newDef := Inherit(def, 0666, 022, false)
+	if mode != 0664 || access.String() != "user::rw-,group::r-x,group:100:rwx,mask::rw-,other::r--" || newDef != nil {
+		t.Errorf("Inherit file = %o, %v, %v", mode, access, newDef)
+	}
+	mode, access, newDef = Inherit(def, os.ModeDir|0750, 022, true)
+	if mode != os.ModeDir|0750 || access.String() != "user::rwx,group::r-x,group:100:rwx,mask::r-x,other::---" || newDef.String() != def.String() {
+		t.Errorf("Inherit dir = %v, %v, %v", mode, access, newDef)
+	}
+	minimal, _ := ParseText("u::rwx,g::rwx,o::r-x")
+	if mode, access, _ := Inherit(minimal, 0666, 077, false); mode != 0664 || access != nil {
+		t.Errorf("Inherit minimal = %o, %v", mode, access)
+	}
+	if mode, access, newDef := Inherit(nil, 0666, 022, true); mode != 0644 || access != nil || newDef != nil {
+		t.Errorf("Inherit without default = %o, %v, %v", mode, access, newDef)
+	}
+	if FromMode(0754).Mode() != 0754 {
+		t.Error("FromMode(0754).Mode() != 0754")
+	}
+}
@@ -0,0 +1,166 @@
+package xattr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Filter selects the attributes removed by RemoveAll and friends.
+type Filter struct {
+	// Match selects attributes by name. A nil Match selects all attributes.
+	Match func(name string) bool
+	// SkipDenied skips attributes the caller is not allowed to remove,
+	// such as those in the security and trusted namespaces, instead of
+	// reporting EPERM or EACCES.
+	SkipDenied bool
+}
+
+// Namespace returns a Filter that selects the attributes in the given
+// namespaces, which include the trailing dot, as in "user.".
+func Namespace(namespaces ...string) Filter {
+	return Filter{Match: func(name string) bool {
+		for _, ns := range namespaces {
+			if strings.HasPrefix(name, ns) {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// ErrorList is returned when removing several attributes failed. It lists
+// one *Error per failure.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	s := make([]string, len(l))
+	for i, e := range l {
+		s[i] = e.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Is reports whether any of the individual errors matches target, so that
+// errors.Is looks at every failure.
+func (l ErrorList) Is(target error) bool {
+	for _, e := range l {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first individual error that matches target, so that
+// errors.As looks at every failure.
+func (l ErrorList) As(target interface{}) bool {
+	for _, e := range l {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the individual errors.
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
+
+// RemoveAll removes the attributes of path selected by filter. It keeps
+// going after a failure and returns an ErrorList with every attribute that
+// could not be removed.
+func RemoveAll(path string, filter Filter) error {
+	return removeAll("xattr.RemoveAll", path, filter, func() ([]string, error) {
+		return List(path)
+	}, func(name string) error {
+		return Remove(path, name)
+	})
+}
+
+// LRemoveAll is like RemoveAll but does not follow a symlink at the end of
+// the path.
+func LRemoveAll(path string, filter Filter) error {
+	return removeAll("xattr.LRemoveAll", path, filter, func() ([]string, error) {
+		return LList(path)
+	}, func(name string) error {
+		return LRemove(path, name)
+	})
+}
+
+// FRemoveAll is like RemoveAll but accepts a os.File instead of a file path.
+func FRemoveAll(f *os.File, filter Filter) error {
+	return removeAll("xattr.FRemoveAll", f.Name(), filter, func() ([]string, error) {
+		return FList(f)
+	}, func(name string) error {
+		return FRemove(f, name)
+	})
+}
+
+// RemoveAllTree calls LRemoveAll for every file and directory in the tree
+// rooted at root. Symlinks are not followed. The failures of all files are
+// collected into a single ErrorList; errors reading the tree stop the walk
+// and are returned as is.
+func RemoveAllTree(root string, filter Filter) error {
+	var errs ErrorList
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch err := LRemoveAll(path, filter).(type) {
+		case nil:
+		case ErrorList:
+			errs = append(errs, err...)
+		case *Error:
+			errs = append(errs, err)
+		default:
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// removeAll contains the logic shared by RemoveAll, LRemoveAll and
+// FRemoveAll. listFunc and removeFunc return errors wrapped in *Error.
+func removeAll(myname, path string, filter Filter, listFunc func() ([]string, error), removeFunc func(name string) error) error {
+	names, err := listFunc()
+	if err != nil {
+		err.(*Error).Op = myname
+		return err
+	}
+	var errs ErrorList
+	for _, name := range names {
+		if filter.Match != nil && !filter.Match(name) {
+			continue
+		}
+		err := removeFunc(name)
+		if e, ok := err.(*Error); ok {
+			err = e.Err
+		}
+		switch {
+		case err == nil, err == ENOATTR:
+			// ENOATTR: someone else removed it after we listed it.
+		case filter.SkipDenied && (err == syscall.EPERM || err == syscall.EACCES):
+		default:
+			errs = append(errs, &Error{myname, path, name, err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
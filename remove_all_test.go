@@ -0,0 +1,110 @@
+//go:build linux || darwin || freebsd || netbsd || solaris
+// +build linux darwin freebsd netbsd solaris
+
+package xattr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestRemoveAll(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	path := tmp.Name()
+
+	for _, name := range []string{"a", "b", "keep.a", "keep.b"} {
+		err = Set(path, UserPrefix+name, []byte(name))
+		checkIfError(t, err)
+	}
+
+	filter := Filter{Match: func(name string) bool {
+		return strings.HasPrefix(name, UserPrefix) && !strings.HasPrefix(name, UserPrefix+"keep.")
+	}}
+	err = RemoveAll(path, filter)
+	checkIfError(t, err)
+	if names := userNames(t, path); strings.Join(names, ",") != "user.keep.a,user.keep.b" {
+		t.Errorf("RemoveAll left %q", names)
+	}
+
+	err = FRemoveAll(tmp, Namespace(UserPrefix))
+	checkIfError(t, err)
+	if names := userNames(t, path); len(names) != 0 {
+		t.Errorf("FRemoveAll left %q", names)
+	}
+}
+
+func TestRemoveAllTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	file := filepath.Join(sub, "file")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{dir, sub, file} {
+		err = Set(path, UserPrefix+"x", []byte("x"))
+		checkIfError(t, err)
+	}
+
+	err = RemoveAllTree(dir, Namespace(UserPrefix))
+	checkIfError(t, err)
+	for _, path := range []string{dir, sub, file} {
+		if names := userNames(t, path); len(names) != 0 {
+			t.Errorf("%s: RemoveAllTree left %q", path, names)
+		}
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	l := ErrorList{
+		{"xattr.RemoveAll", "/a", "user.x", ENOATTR},
+		{"xattr.RemoveAll", "/b", "user.y", ENOATTR},
+	}
+	want := l[0].Error() + "; " + l[1].Error()
+	if l.Error() != want {
+		t.Errorf("have %q, want %q", l.Error(), want)
+	}
+	if errs := l.Unwrap(); len(errs) != 2 || errs[1] != l[1] {
+		t.Errorf("wrong Unwrap result: %v", errs)
+	}
+
+	l[1].Err = syscall.EPERM
+	if !errors.Is(l, ENOATTR) || !errors.Is(l, syscall.EPERM) || errors.Is(l, syscall.EIO) {
+		t.Error("errors.Is does not look at every failure")
+	}
+	var e *Error
+	if !errors.As(l, &e) || e != l[0] {
+		t.Errorf("errors.As found %v", e)
+	}
+}
+
+// userNames returns the sorted names in the user namespace of path.
+func userNames(t *testing.T, path string) []string {
+	list, err := List(path)
+	checkIfError(t, err)
+	var names []string
+	for _, name := range list {
+		if strings.HasPrefix(name, UserPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,135 @@
+package xattr
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+)
+
+// Rename renames the attribute oldName of path to newName. There is no
+// system call for this, so the value is copied to newName, read back to
+// verify it and only then removed from oldName. If any step fails, the
+// changes made so far are rolled back.
+//
+// By default, Rename fails with EEXIST if newName already exists. Pass
+// XATTR_REPLACE in flags to replace an existing newName instead; its old
+// value is restored on failure. Other flags are forwarded to the syscall
+// layer.
+func Rename(path, oldName, newName string, flags int) error {
+	return rename("xattr.Rename", path, oldName, newName, flags, renameOps{
+		get: func(name string) ([]byte, error) {
+			return Get(path, name)
+		},
+		set: func(name string, data []byte, flags int) error {
+			return SetWithFlags(path, name, data, flags)
+		},
+		remove: func(name string) error {
+			return Remove(path, name)
+		},
+	})
+}
+
+// LRename is like Rename but does not follow a symlink at the end of the
+// path.
+func LRename(path, oldName, newName string, flags int) error {
+	return rename("xattr.LRename", path, oldName, newName, flags, renameOps{
+		get: func(name string) ([]byte, error) {
+			return LGet(path, name)
+		},
+		set: func(name string, data []byte, flags int) error {
+			return LSetWithFlags(path, name, data, flags)
+		},
+		remove: func(name string) error {
+			return LRemove(path, name)
+		},
+	})
+}
+
+// FRename is like Rename but accepts a os.File instead of a file path.
+func FRename(f *os.File, oldName, newName string, flags int) error {
+	return rename("xattr.FRename", f.Name(), oldName, newName, flags, renameOps{
+		get: func(name string) ([]byte, error) {
+			return FGet(f, name)
+		},
+		set: func(name string, data []byte, flags int) error {
+			return FSetWithFlags(f, name, data, flags)
+		},
+		remove: func(name string) error {
+			return FRemove(f, name)
+		},
+	})
+}
+
+// renameOps are the primitives rename works with, so that it goes through
+// a registered Fallback like the functions it is built on.
+type renameOps struct {
+	get    func(name string) ([]byte, error)
+	set    func(name string, data []byte, flags int) error
+	remove func(name string) error
+}
+
+// rename contains the copy, verify and rollback logic shared by Rename,
+// LRename and FRename.
+func rename(myname, path, oldName, newName string, flags int, ops renameOps) error {
+	fail := func(name string, err error) error {
+		if e, ok := err.(*Error); ok {
+			err = e.Err
+		}
+		return &Error{myname, path, name, err}
+	}
+	if oldName == newName {
+		return fail(newName, syscall.EINVAL)
+	}
+	value, err := ops.get(oldName)
+	if err != nil {
+		return fail(oldName, err)
+	}
+
+	// Remember the value we are about to replace, and check for an
+	// existing attribute ourselves on platforms that ignore XATTR_CREATE.
+	prev, err := ops.get(newName)
+	exists := err == nil
+	if err != nil && !isENOATTR(err) {
+		return fail(newName, err)
+	}
+	if flags&XATTR_REPLACE == 0 {
+		if exists {
+			return fail(newName, syscall.EEXIST)
+		}
+		flags |= XATTR_CREATE
+	} else if !exists {
+		return fail(newName, ENOATTR)
+	}
+
+	// undo restores newName to its previous state.
+	undo := func() {
+		if exists {
+			_ = ops.set(newName, prev, XATTR_REPLACE)
+		} else {
+			_ = ops.remove(newName)
+		}
+	}
+	if err := ops.set(newName, value, flags); err != nil {
+		return fail(newName, err)
+	}
+	copied, err := ops.get(newName)
+	if err == nil && !bytes.Equal(copied, value) {
+		err = syscall.EIO
+	}
+	if err != nil {
+		undo()
+		return fail(newName, err)
+	}
+	if err := ops.remove(oldName); err != nil {
+		undo()
+		return fail(oldName, err)
+	}
+	return nil
+}
+
+// isENOATTR reports whether err, as returned by get, means that the
+// attribute does not exist.
+func isENOATTR(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.Err == ENOATTR
+}
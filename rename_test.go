@@ -0,0 +1,59 @@
+//go:build linux || darwin || freebsd || netbsd || solaris
+// +build linux darwin freebsd netbsd solaris
+
+package xattr
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	path := tmp.Name()
+
+	oldName, newName := UserPrefix+"old", UserPrefix+"new"
+	err = Set(path, oldName, []byte("one"))
+	checkIfError(t, err)
+
+	err = Rename(path, oldName, newName, 0)
+	checkIfError(t, err)
+	if _, err := Get(path, oldName); unpackSysErr(err) != ENOATTR {
+		t.Errorf("old attribute still exists after Rename: %v", err)
+	}
+	data, err := Get(path, newName)
+	checkIfError(t, err)
+	if string(data) != "one" {
+		t.Errorf("wrong value after Rename: %q", data)
+	}
+
+	// Renaming onto an existing attribute must not clobber it.
+	err = Set(path, oldName, []byte("two"))
+	checkIfError(t, err)
+	err = LRename(path, oldName, newName, 0)
+	if unpackSysErr(err) != syscall.EEXIST {
+		t.Errorf("LRename onto an existing attribute: want EEXIST, have %v", err)
+	}
+	if data, _ := Get(path, oldName); string(data) != "two" {
+		t.Errorf("failed LRename changed the old attribute to %q", data)
+	}
+
+	// Unless XATTR_REPLACE asks for it.
+	err = FRename(tmp, oldName, newName, XATTR_REPLACE)
+	checkIfError(t, err)
+	if data, _ := Get(path, newName); string(data) != "two" {
+		t.Errorf("FRename with XATTR_REPLACE did not replace the value: %q", data)
+	}
+
+	err = Rename(path, oldName, newName, XATTR_REPLACE)
+	if unpackSysErr(err) != ENOATTR {
+		t.Errorf("Rename of a missing attribute: want ENOATTR, have %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package samba
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/xattr"
+)
+
+// DOSAttribAttr is the attribute in which Samba stores DOS attributes.
+const DOSAttribAttr = "user.DOSATTRIB"
+
+// DOS file attributes.
+const (
+	AttrReadOnly          = 0x0001
+	AttrHidden            = 0x0002
+	AttrSystem            = 0x0004
+	AttrDirectory         = 0x0010
+	AttrArchive           = 0x0020
+	AttrNormal            = 0x0080
+	AttrTemporary         = 0x0100
+	AttrSparse            = 0x0200
+	AttrReparsePoint      = 0x0400
+	AttrCompressed        = 0x0800
+	AttrOffline           = 0x1000
+	AttrNotContentIndexed = 0x2000
+	AttrEncrypted         = 0x4000
+)
+
+// Valid flags of DOSInfo versions 3 to 5.
+const (
+	ValidAttrib     = 0x01
+	ValidEASize     = 0x02
+	ValidSize       = 0x04
+	ValidAllocSize  = 0x08
+	ValidCreateTime = 0x10
+	ValidChangeTime = 0x20
+	ValidITime      = 0x40
+)
+
+// Versions of the user.DOSATTRIB format. VersionHex is the plain "0x20"
+// string of Samba 3.0; VersionCompat holds only the attributes.
+const (
+	VersionHex    = 0
+	Version1      = 1
+	Version2      = 2
+	Version3      = 3
+	Version4      = 4
+	Version5      = 5
+	VersionCompat = 0xffff
+)
+
+// DOSInfo is a decoded user.DOSATTRIB value. Which fields are stored
+// depends on the version:
+//
+//	VersionHex, VersionCompat   Attrib
+//	Version1                    Attrib, EASize, Size, AllocSize, CreateTime, ChangeTime
+//	Version2                    Valid, Attrib, EASize, Size, AllocSize, CreateTime, ChangeTime, WriteTime, Name
+//	Version3                    Valid, Attrib, EASize, Size, AllocSize, CreateTime, ChangeTime
+//	Version4                    Valid, Attrib, ITime, CreateTime
+//	Version5                    Valid, Attrib, CreateTime
+//
+// Samba 4.9 and later write Version5.
+type DOSInfo struct {
+	Version int
+	// Valid holds the Valid flags; in Version2 it holds the unused flags
+	// field.
+	Valid      uint32
+	Attrib     uint32
+	EASize     uint32
+	Size       uint64
+	AllocSize  uint64
+	CreateTime time.Time
+	ChangeTime time.Time
+	WriteTime  time.Time
+	ITime      time.Time
+	Name       string
+}
+
+// ParseDOSInfo decodes a user.DOSATTRIB value: the attributes as a
+// NUL-terminated hex string, followed by the NDR-encoded xattr_DosAttrib
+// structure unless the value is from Samba 3.0.
+func ParseDOSInfo(b []byte) (*DOSInfo, error) {
+	r := &ndrReader{b: b}
+	hex := r.cstring()
+	if r.err != nil {
+		// Samba 3.0 did not always terminate the string.
+		hex, r.err, r.off = string(b), nil, len(b)
+	}
+	attrib, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(hex, "0x"), "0X"), 16, 32)
+	if err != nil {
+		return nil, ErrFormat
+	}
+	info := &DOSInfo{Version: VersionHex, Attrib: uint32(attrib)}
+	if r.remaining() == 0 {
+		return info, nil
+	}
+	info.Version = int(r.uint16())
+	if level := int(r.uint16()); level != info.Version {
+		return nil, ErrFormat
+	}
+	switch info.Version {
+	case VersionCompat:
+		info.Attrib = r.uint32()
+	case Version1:
+		info.Attrib = r.uint32()
+		info.EASize = r.uint32()
+		info.Size = r.udlong()
+		info.AllocSize = r.udlong()
+		info.CreateTime = FromNTTime(r.udlong())
+		info.ChangeTime = FromNTTime(r.udlong())
+	case Version2:
+		info.Valid = r.uint32()
+		info.Attrib = r.uint32()
+		info.EASize = r.uint32()
+		info.Size = r.udlong()
+		info.AllocSize = r.udlong()
+		info.CreateTime = FromNTTime(r.udlong())
+		info.ChangeTime = FromNTTime(r.udlong())
+		info.WriteTime = FromNTTime(r.udlong())
+		info.Name = r.cstring()
+	case Version3:
+		info.Valid = r.uint32()
+		info.Attrib = r.uint32()
+		info.EASize = r.uint32()
+		info.Size = r.udlong()
+		info.AllocSize = r.udlong()
+		info.CreateTime = FromNTTime(r.udlong())
+		info.ChangeTime = FromNTTime(r.udlong())
+	case Version4:
+		info.Valid = r.uint32()
+		info.Attrib = r.uint32()
+		info.ITime = FromNTTime(r.udlong())
+		info.CreateTime = FromNTTime(r.udlong())
+	case Version5:
+		info.Valid = r.uint32()
+		info.Attrib = r.uint32()
+		info.CreateTime = FromNTTime(r.udlong())
+	default:
+		return nil, fmt.Errorf("samba: unknown DOSATTRIB version %d", info.Version)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return info, nil
+}
+
+// Encode returns the user.DOSATTRIB value of info in its version.
+func (info *DOSInfo) Encode() ([]byte, error) {
+	w := &ndrWriter{}
+	w.cstring(fmt.Sprintf("0x%x", info.Attrib))
+	if info.Version == VersionHex {
+		return w.b, nil
+	}
+	w.uint16(uint16(info.Version))
+	w.uint16(uint16(info.Version))
+	switch info.Version {
+	case VersionCompat:
+		w.uint32(info.Attrib)
+	case Version1:
+		w.uint32(info.Attrib)
+		w.uint32(info.EASize)
+		w.udlong(info.Size)
+		w.udlong(info.AllocSize)
+		w.udlong(ToNTTime(info.CreateTime))
+		w.udlong(ToNTTime(info.ChangeTime))
+	case Version2:
+		w.uint32(info.Valid)
+		w.uint32(info.Attrib)
+		w.uint32(info.EASize)
+		w.udlong(info.Size)
+		w.udlong(info.AllocSize)
+		w.udlong(ToNTTime(info.CreateTime))
+		w.udlong(ToNTTime(info.ChangeTime))
+		w.udlong(ToNTTime(info.WriteTime))
+		w.cstring(info.Name)
+	case Version3:
+		w.uint32(info.Valid)
+		w.uint32(info.Attrib)
+		w.uint32(info.EASize)
+		w.udlong(info.Size)
+		w.udlong(info.AllocSize)
+		w.udlong(ToNTTime(info.CreateTime))
+		w.udlong(ToNTTime(info.ChangeTime))
+	case Version4:
+		w.uint32(info.Valid)
+		w.uint32(info.Attrib)
+		w.udlong(ToNTTime(info.ITime))
+		w.udlong(ToNTTime(info.CreateTime))
+	case Version5:
+		w.uint32(info.Valid)
+		w.uint32(info.Attrib)
+		w.udlong(ToNTTime(info.CreateTime))
+	default:
+		return nil, fmt.Errorf("samba: unknown DOSATTRIB version %d", info.Version)
+	}
+	return w.b, nil
+}
+
+// GetDOSInfo reads the DOS information Samba stored for path.
+func GetDOSInfo(path string) (*DOSInfo, error) {
+	b, err := xattr.LGet(path, DOSAttribAttr)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDOSInfo(b)
+}
+
+// SetDOSInfo stores info for path.
+func SetDOSInfo(path string, info *DOSInfo) error {
+	b, err := info.Encode()
+	if err != nil {
+		return err
+	}
+	return xattr.LSet(path, DOSAttribAttr, b)
+}
+
+// GetDOSAttributes returns the DOS attributes of path. A file without
+// user.DOSATTRIB has no attributes set.
+func GetDOSAttributes(path string) (uint32, error) {
+	info, err := GetDOSInfo(path)
+	if errors.Is(err, xattr.ENOATTR) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Attrib, nil
+}
+
+// SetDOSAttributes replaces the DOS attributes of path, keeping the other
+// stored information. A new value is written in Version5.
+func SetDOSAttributes(path string, attrib uint32) error {
+	info, err := GetDOSInfo(path)
+	if errors.Is(err, xattr.ENOATTR) {
+		info, err = &DOSInfo{Version: Version5}, nil
+	}
+	if err != nil {
+		return err
+	}
+	info.Attrib = attrib
+	if info.Version >= Version3 && info.Version <= Version5 {
+		info.Valid |= ValidAttrib
+	}
+	return SetDOSInfo(path, info)
+}
+
+func setDOSFlag(path string, flag uint32, on bool) error {
+	attrib, err := GetDOSAttributes(path)
+	if err != nil {
+		return err
+	}
+	if on {
+		attrib |= flag
+	} else {
+		attrib &^= flag
+	}
+	return SetDOSAttributes(path, attrib)
+}
+
+// SetReadOnly sets or clears the read-only attribute of path.
+func SetReadOnly(path string, on bool) error { return setDOSFlag(path, AttrReadOnly, on) }
+
+// SetHidden sets or clears the hidden attribute of path.
+func SetHidden(path string, on bool) error { return setDOSFlag(path, AttrHidden, on) }
+
+// SetSystem sets or clears the system attribute of path.
+func SetSystem(path string, on bool) error { return setDOSFlag(path, AttrSystem, on) }
+
+// SetArchive sets or clears the archive attribute of path.
+func SetArchive(path string, on bool) error { return setDOSFlag(path, AttrArchive, on) }
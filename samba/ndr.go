@@ -0,0 +1,142 @@
+package samba
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// ErrFormat is returned for values that cannot be decoded.
+var ErrFormat = errors.New("samba: invalid NDR data")
+
+// ndrReader decodes little-endian NDR data. Alignment is relative to the
+// start of the buffer. The first error sticks and is reported by err.
+type ndrReader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *ndrReader) fail() {
+	if r.err == nil {
+		r.err = ErrFormat
+	}
+}
+
+func (r *ndrReader) align(n int) {
+	if pad := (n - r.off%n) % n; pad > 0 {
+		r.bytes(pad)
+	}
+}
+
+// bytes returns the next n bytes. After an error, it returns zeros for the
+// fixed-size reads, so that decoding can carry on until the error is
+// checked.
+func (r *ndrReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.b)-r.off {
+		r.fail()
+		if n < 0 || n > 8 {
+			return nil
+		}
+		return make([]byte, n)
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *ndrReader) uint16() uint16 {
+	r.align(2)
+	return binary.LittleEndian.Uint16(r.bytes(2))
+}
+
+func (r *ndrReader) uint32() uint32 {
+	r.align(4)
+	return binary.LittleEndian.Uint32(r.bytes(4))
+}
+
+// udlong reads a 64-bit value aligned to 4 bytes, as Samba uses for sizes
+// and NTTIME.
+func (r *ndrReader) udlong() uint64 {
+	r.align(4)
+	return binary.LittleEndian.Uint64(r.bytes(8))
+}
+
+// cstring reads a NUL-terminated string.
+func (r *ndrReader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	for i := r.off; i < len(r.b); i++ {
+		if r.b[i] == 0 {
+			s := string(r.b[r.off:i])
+			r.off = i + 1
+			return s
+		}
+	}
+	r.fail()
+	return ""
+}
+
+func (r *ndrReader) remaining() int {
+	return len(r.b) - r.off
+}
+
+// ndrWriter encodes little-endian NDR data.
+type ndrWriter struct {
+	b []byte
+}
+
+func (w *ndrWriter) align(n int) {
+	for len(w.b)%n != 0 {
+		w.b = append(w.b, 0)
+	}
+}
+
+func (w *ndrWriter) bytes(b []byte) {
+	w.b = append(w.b, b...)
+}
+
+func (w *ndrWriter) uint16(v uint16) {
+	w.align(2)
+	w.b = append(w.b, byte(v), byte(v>>8))
+}
+
+func (w *ndrWriter) uint32(v uint32) {
+	w.align(4)
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	w.b = append(w.b, buf[:]...)
+}
+
+func (w *ndrWriter) udlong(v uint64) {
+	w.align(4)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	w.b = append(w.b, buf[:]...)
+}
+
+func (w *ndrWriter) cstring(s string) {
+	w.b = append(append(w.b, s...), 0)
+}
+
+// ntEpochOffset is the number of seconds between 1601-01-01, the NTTIME
+// epoch, and 1970-01-01.
+const ntEpochOffset = 11644473600
+
+// FromNTTime converts an NTTIME, in 100ns units since 1601, to a time.
+// Zero converts to the zero time.
+func FromNTTime(nt uint64) time.Time {
+	if nt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(nt/1e7)-ntEpochOffset, int64(nt%1e7)*100).UTC()
+}
+
+// ToNTTime converts a time to an NTTIME. The zero time converts to zero.
+func ToNTTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix()+ntEpochOffset)*1e7 + uint64(t.Nanosecond()/100)
+}
@@ -0,0 +1,137 @@
+package samba
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/xattr"
+)
+
+// NTACLAttr is the attribute in which Samba stores Windows security
+// descriptors.
+const NTACLAttr = "security.NTACL"
+
+// Hash types of NTACL versions 3 and 4.
+const (
+	HashNone   = 0
+	HashSHA256 = 1
+)
+
+// referentBase is the first unique pointer referent ID Samba's NDR
+// encoder uses; further ones follow in steps of 4.
+const referentBase = 0x00020000
+
+// NTACL is a decoded security.NTACL value. Version 1 holds only the
+// security descriptor. Version 2 adds an MD5 hash of it, and versions 3
+// and 4 add the hash type and a SHA-256 hash; version 4 also records the
+// hash of the POSIX ACL the descriptor was mapped to, with a description
+// of the mapping and the time it was made. Samba writes version 4.
+type NTACL struct {
+	Version uint16
+	// Hash is 16 bytes in version 2 and 64 bytes in versions 3 and 4.
+	Hash        []byte
+	HashType    uint16
+	Description string
+	Time        time.Time
+	SysACLHash  []byte
+	SD          *SecurityDescriptor
+}
+
+// ParseNTACL decodes a security.NTACL value.
+func ParseNTACL(b []byte) (*NTACL, error) {
+	r := &ndrReader{b: b}
+	n := &NTACL{Version: r.uint16()}
+	if level := r.uint16(); level != n.Version {
+		return nil, ErrFormat
+	}
+	if r.uint32() == 0 {
+		return nil, ErrFormat
+	}
+	switch n.Version {
+	case 1:
+	case 2:
+		if r.uint32() == 0 {
+			return nil, ErrFormat
+		}
+		n.Hash = append([]byte{}, r.bytes(16)...)
+	case 3, 4:
+		if r.uint32() == 0 {
+			return nil, ErrFormat
+		}
+		n.HashType = r.uint16()
+		n.Hash = append([]byte{}, r.bytes(64)...)
+		if n.Version == 4 {
+			n.Description = r.cstring()
+			n.Time = FromNTTime(r.udlong())
+			n.SysACLHash = append([]byte{}, r.bytes(64)...)
+		}
+	default:
+		return nil, fmt.Errorf("samba: unknown NTACL version %d", n.Version)
+	}
+	r.align(4)
+	if r.err != nil {
+		return nil, r.err
+	}
+	sd, err := ParseSecurityDescriptor(b[r.off:])
+	if err != nil {
+		return nil, err
+	}
+	n.SD = sd
+	return n, nil
+}
+
+// Encode returns the security.NTACL value of n.
+func (n *NTACL) Encode() ([]byte, error) {
+	if n.SD == nil {
+		return nil, fmt.Errorf("samba: NTACL without security descriptor")
+	}
+	fixed := func(b []byte, size int) []byte {
+		out := make([]byte, size)
+		copy(out, b)
+		return out
+	}
+	w := &ndrWriter{}
+	w.uint16(n.Version)
+	w.uint16(n.Version)
+	w.uint32(referentBase)
+	switch n.Version {
+	case 1:
+	case 2:
+		w.uint32(referentBase + 4)
+		w.bytes(fixed(n.Hash, 16))
+	case 3, 4:
+		w.uint32(referentBase + 4)
+		w.uint16(n.HashType)
+		w.bytes(fixed(n.Hash, 64))
+		if n.Version == 4 {
+			w.cstring(n.Description)
+			w.udlong(ToNTTime(n.Time))
+			w.bytes(fixed(n.SysACLHash, 64))
+		}
+	default:
+		return nil, fmt.Errorf("samba: unknown NTACL version %d", n.Version)
+	}
+	w.align(4)
+	w.bytes(n.SD.Encode())
+	return w.b, nil
+}
+
+// GetNTACL reads the security.NTACL attribute of path.
+func GetNTACL(path string) (*NTACL, error) {
+	b, err := xattr.LGet(path, NTACLAttr)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNTACL(b)
+}
+
+// SetNTACL writes the security.NTACL attribute of path. Samba checks the
+// hashes of versions 3 and 4 against the file and may fall back to its
+// POSIX ACL if they do not match; keeping them right is up to the caller.
+func SetNTACL(path string, n *NTACL) error {
+	b, err := n.Encode()
+	if err != nil {
+		return err
+	}
+	return xattr.LSet(path, NTACLAttr, b)
+}
@@ -0,0 +1,13 @@
+/*
+Package samba decodes and encodes the extended attributes in which Samba
+keeps Windows file metadata on Linux servers:
+
+	user.DOSATTRIB    DOS attributes and creation time
+	security.NTACL    Windows security descriptor
+	user.DosStream.*  alternate data streams (vfs_streams_xattr)
+
+Security descriptors can be rendered in the Security Descriptor Definition
+Language (SDDL) that Windows tools print. Alternate data streams such as Zone.Identifier are
+exposed by name, see Streams.
+*/
+package samba
@@ -0,0 +1,337 @@
+package samba
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestNTTime(t *testing.T) {
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	nt := ToNTTime(tm)
+	if nt != 132224078456000000 {
+		t.Errorf("ToNTTime = %d", nt)
+	}
+	if got := FromNTTime(nt); !got.Equal(tm) {
+		t.Errorf("FromNTTime = %v", got)
+	}
+	if !FromNTTime(0).IsZero() || ToNTTime(time.Time{}) != 0 {
+		t.Error("zero time is not zero")
+	}
+}
+
+func TestDOSInfo(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	nt := ToNTTime(created)
+	// Version 4 as written by Samba 4.8: the hex string, the version and
+	// the union level, then valid flags, attributes, itime and create
+	// time, all aligned to four bytes.
+	v4 := []byte("0x22\x00" + "\x00" + "\x04\x00" + "\x04\x00" + "\x00\x00" +
+		"\x11\x00\x00\x00" + "\x22\x00\x00\x00" + "\x00\x00\x00\x00\x00\x00\x00\x00")
+	v4 = append(v4, byte(nt), byte(nt>>8), byte(nt>>16), byte(nt>>24), byte(nt>>32), byte(nt>>40), byte(nt>>48), byte(nt>>56))
+	info, err := ParseDOSInfo(v4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &DOSInfo{Version: Version4, Valid: ValidAttrib | ValidCreateTime, Attrib: AttrHidden | AttrArchive, CreateTime: created}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("ParseDOSInfo = %+v", info)
+	}
+	if b, err := info.Encode(); err != nil || !bytes.Equal(b, v4) {
+		t.Errorf("Encode = % x, %v", b, err)
+	}
+
+	for _, info := range []*DOSInfo{
+		{Version: VersionHex, Attrib: AttrReadOnly},
+		{Version: VersionCompat, Attrib: AttrSystem},
+		{Version: Version1, Attrib: AttrArchive, EASize: 3, Size: 1 << 40, AllocSize: 4096, CreateTime: created, ChangeTime: created.Add(time.Hour)},
+		{Version: Version2, Valid: 1, Attrib: AttrArchive, Size: 7, WriteTime: created, Name: "FILE.TXT"},
+		{Version: Version3, Valid: ValidAttrib, Attrib: AttrNormal, ChangeTime: created},
+		{Version: Version5, Valid: ValidAttrib | ValidCreateTime, Attrib: AttrDirectory, CreateTime: created},
+	} {
+		b, err := info.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseDOSInfo(b)
+		if err != nil || !reflect.DeepEqual(got, info) {
+			t.Errorf("version %d: ParseDOSInfo(Encode) = %+v, %v", info.Version, got, err)
+		}
+	}
+
+	if info, err := ParseDOSInfo([]byte("0x20")); err != nil || info.Attrib != AttrArchive {
+		t.Errorf("unterminated hex string: %+v, %v", info, err)
+	}
+	for _, bad := range [][]byte{[]byte("zz\x00"), v4[:20], []byte("0x20\x00\x00\x09\x00\x09\x00")} {
+		if _, err := ParseDOSInfo(bad); err == nil {
+			t.Errorf("ParseDOSInfo(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDOSAttributes(t *testing.T) {
+	f, err := ioutil.TempFile("", "xattr-samba-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	attrib, err := GetDOSAttributes(f.Name())
+	xattrtest.Check(t, err)
+	if attrib != 0 {
+		t.Fatalf("GetDOSAttributes = %#x", attrib)
+	}
+	if err := SetHidden(f.Name(), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetReadOnly(f.Name(), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetHidden(f.Name(), false); err != nil {
+		t.Fatal(err)
+	}
+	info, err := GetDOSInfo(f.Name())
+	if err != nil || info.Version != Version5 || info.Attrib != AttrReadOnly || info.Valid != ValidAttrib {
+		t.Errorf("GetDOSInfo = %+v, %v", info, err)
+	}
+}
+
+func testSD() *SecurityDescriptor {
+	owner, group := MustParseSID("S-1-5-32-544"), MustParseSID("S-1-5-18")
+	return &SecurityDescriptor{
+		Control: DACLProtected | DACLAutoInherited,
+		Owner:   &owner,
+		Group:   &group,
+		DACL: &ACL{Revision: ACLRevision, ACEs: []ACE{
+			{Type: AccessAllowed, Flags: ObjectInherit | ContainerInherit, Mask: FileAllAccess, SID: owner},
+			{Type: AccessAllowed, Flags: ObjectInherit | ContainerInherit | InheritOnly, Mask: FileAllAccess, SID: MustParseSID("S-1-3-0")},
+			{Type: AccessAllowed, Mask: 0x1200a9, SID: MustParseSID("S-1-5-21-1004336348-1177238915-682003330-1001")},
+			{Type: AccessDenied, Mask: WriteDAC | WriteOwner, SID: MustParseSID("S-1-1-0")},
+		}},
+	}
+}
+
+func TestSID(t *testing.T) {
+	for _, s := range []string{"S-1-5-32-544", "S-1-0x123456789ABC-1", "S-1-1-0", "S-1-5"} {
+		sid, err := ParseSID(s)
+		if err != nil || sid.String() != s {
+			t.Errorf("ParseSID(%q) = %v, %v", s, sid, err)
+		}
+	}
+	for _, bad := range []string{"", "S-1", "X-1-5", "S-1-5-x", "S-1-5-4294967296"} {
+		if _, err := ParseSID(bad); err == nil {
+			t.Errorf("ParseSID(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSecurityDescriptor(t *testing.T) {
+	sd := testSD()
+	b := sd.Encode()
+	// Header: revision, control with DACL present and self-relative set,
+	// owner right after the header, no SACL.
+	if b[0] != 1 || b[2] != 0x04 || b[3] != 0x94 || b[4] != 20 || b[12] != 0 {
+		t.Errorf("header % x", b[:20])
+	}
+	got, err := ParseSecurityDescriptor(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd.Revision, sd.Control = 1, sd.Control|DACLPresent|SelfRelative
+	if !reflect.DeepEqual(got, sd) {
+		t.Errorf("ParseSecurityDescriptor = %+v", got)
+	}
+	want := "O:BAG:SYD:PAI(A;OICI;FA;;;BA)(A;OICIIO;FA;;;CO)" +
+		"(A;;0x1200a9;;;S-1-5-21-1004336348-1177238915-682003330-1001)(D;;WDWO;;;WD)"
+	if s := got.SDDL(); s != want {
+		t.Errorf("SDDL = %s", s)
+	}
+
+	obj := ACE{Type: AccessAllowedObject, Mask: GenericRead, ObjectFlags: ObjectTypePresent, SID: MustParseSID("S-1-5-11")}
+	copy(obj.ObjectType[:], []byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x78, 0x56, 1, 2, 3, 4, 5, 6, 7, 8})
+	sd = &SecurityDescriptor{SACL: &ACL{ACEs: []ACE{obj, {Type: 0x11, Flags: Inherited, ApplicationData: []byte{1, 2, 3, 4}}}}}
+	got, err = ParseSecurityDescriptor(sd.Encode())
+	if err != nil || len(got.SACL.ACEs) != 2 || !reflect.DeepEqual(got.SACL.ACEs[0], obj) {
+		t.Fatalf("object ACE: %+v, %v", got, err)
+	}
+	if s := got.SDDL(); s != "S:(OA;;GR;12345678-1234-5678-0102-030405060708;;AU)(0x11;ID;0x0;;;)" {
+		t.Errorf("SDDL = %s", s)
+	}
+
+	for _, bad := range [][]byte{b[:10], append(append([]byte{}, b[:4]...), 0xff, 0xff, 0, 0)} {
+		if _, err := ParseSecurityDescriptor(bad); err == nil {
+			t.Errorf("ParseSecurityDescriptor(% x) succeeded", bad)
+		}
+	}
+}
+
+func TestNTACL(t *testing.T) {
+	created := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	for _, n := range []*NTACL{
+		{Version: 1, SD: testSD()},
+		{Version: 2, Hash: bytes.Repeat([]byte{2}, 16), SD: testSD()},
+		{Version: 3, HashType: HashSHA256, Hash: bytes.Repeat([]byte{3}, 64), SD: testSD()},
+		{Version: 4, HashType: HashSHA256, Hash: bytes.Repeat([]byte{4}, 64), Description: "posix_acl",
+			Time: created, SysACLHash: bytes.Repeat([]byte{5}, 64), SD: testSD()},
+	} {
+		b, err := n.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Version == 4 && (b[160] != 1 || !bytes.Equal(b[:12], []byte{4, 0, 4, 0, 0, 0, 2, 0, 4, 0, 2, 0})) {
+			t.Errorf("version 4 layout: % x", b[:12])
+		}
+		got, err := ParseNTACL(b)
+		if err != nil {
+			t.Fatalf("version %d: %v", n.Version, err)
+		}
+		if got.SD.SDDL() != n.SD.SDDL() || got.Description != n.Description || !got.Time.Equal(n.Time) ||
+			!bytes.Equal(got.Hash, n.Hash) || !bytes.Equal(got.SysACLHash, n.SysACLHash) {
+			t.Errorf("version %d: ParseNTACL = %+v", n.Version, got)
+		}
+	}
+	if _, err := ParseNTACL([]byte{5, 0, 5, 0, 0, 0, 2, 0}); err == nil {
+		t.Error("ParseNTACL of version 5 succeeded")
+	}
+}
+
+// fixtureSD is "O:BAG:BUD:P(A;OICI;FA;;;BA)(A;OICI;0x1200a9;;;WD)" in the
+// NDR form of security.idl, which puts the owner, group and DACL in that
+// order after the header.
+const fixtureSD = "" +
+	"01" + "00" + "0490" + // revision, sbz1, control: self-relative, protected, DACL present
+	"14000000" + "24000000" + "00000000" + "34000000" + // owner, group, SACL, DACL offsets
+	"010200000000000520000000" + "20020000" + // S-1-5-32-544
+	"010200000000000520000000" + "21020000" + // S-1-5-32-545
+	"02" + "00" + "3400" + "0200" + "0000" + // ACL revision, sbz1, size, count, sbz2
+	"00" + "03" + "1800" + "ff011f00" + "01020000000000052000000020020000" + // ACE: allowed, OICI, FA, BA
+	"00" + "03" + "1400" + "a9001200" + "010100000000000100000000" // ACE: allowed, OICI, 0x1200a9, WD
+
+// ntaclFixtures are security.NTACL values assembled field by field from
+// Samba's xattr.idl, independently of the encoder. The first 14 bytes of
+// version 4 are what Samba writes ("BAAEAAAAAgAEAAIAAQ" in the base64 output
+// of getfattr).
+var ntaclFixtures = []struct {
+	version uint16
+	hex     string
+}{
+	{1, "" +
+		"0100" + "0100" + // version, union level
+		"00000200" + // referent of the security descriptor
+		fixtureSD},
+	{4, "" +
+		"0400" + "0400" + // version, union level
+		"00000200" + // referent of security_descriptor_hash_v4
+		"04000200" + // referent of the security descriptor
+		"0100" + // hash type SHA256
+		"60d85d22f8f9855a3a412309379c34ac5f2ad4f706fa36fc326d5c79c6f86edd" + // SHA256 of the descriptor
+		strings.Repeat("00", 32) + // rest of the 64-byte hash
+		"706f7369785f61636c00" + // "posix_acl" and its NUL
+		"008e1f4b19c1d501" + // NTTIME 2020-01-02 03:04:05.6 UTC
+		strings.Repeat("11", 64) + // hash of the POSIX ACLs
+		fixtureSD},
+}
+
+func TestNTACLFixtures(t *testing.T) {
+	const sddl = "O:BAG:BUD:P(A;OICI;FA;;;BA)(A;OICI;0x1200a9;;;WD)"
+	for _, f := range ntaclFixtures {
+		b, err := hex.DecodeString(f.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := ParseNTACL(b)
+		if err != nil {
+			t.Fatalf("version %d: %v", f.version, err)
+		}
+		if n.Version != f.version || n.SD.SDDL() != sddl {
+			t.Errorf("version %d: ParseNTACL = %d, %s", f.version, n.Version, n.SD.SDDL())
+		}
+		if f.version == 4 {
+			sum := sha256.Sum256(b[len(b)-len(fixtureSD)/2:])
+			if n.HashType != HashSHA256 || !bytes.Equal(n.Hash[:32], sum[:]) || n.Description != "posix_acl" ||
+				!n.Time.Equal(time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.UTC)) ||
+				!bytes.Equal(n.SysACLHash, bytes.Repeat([]byte{0x11}, 64)) {
+				t.Errorf("version 4: ParseNTACL = %+v", n)
+			}
+		}
+		if enc, err := n.Encode(); err != nil || !bytes.Equal(enc, b) {
+			t.Errorf("version %d: Encode = %x, %v\nwant %x", f.version, enc, err, b)
+		}
+	}
+}
+
+func TestStreams(t *testing.T) {
+	f, err := ioutil.TempFile("", "xattr-samba-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	zone := []byte("[ZoneTransfer]\r\nZoneId=3\r\n")
+	xattrtest.Check(t, WriteStream(f.Name(), "Zone.Identifier", zone))
+	raw, err := xattr.LGet(f.Name(), "user.DosStream.Zone.Identifier:$DATA")
+	if err != nil || !bytes.Equal(raw, append(zone, 0)) {
+		t.Errorf("raw value = %q, %v", raw, err)
+	}
+	if got, err := ReadStream(f.Name(), "zone.identifier:$DATA"); err != nil || !bytes.Equal(got, zone) {
+		t.Errorf("ReadStream = %q, %v", got, err)
+	}
+	if _, err := ReadStream(f.Name(), "a:b"); !errors.Is(err, ErrStreamName) {
+		t.Errorf("ReadStream(a:b) = %v", err)
+	}
+
+	st, err := OpenStream(f.Name(), "notes", os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(st, "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(st, "there")
+	st.Seek(100, io.SeekStart)
+	if n, err := st.Write(nil); n != 0 || err != nil || st.Size() != 11 {
+		t.Errorf("empty write past the end = %d, %v; size %d", n, err, st.Size())
+	}
+	if err := st.Truncate(-1); err == nil || st.Size() != 11 {
+		t.Errorf("Truncate(-1) = %v; size %d", err, st.Size())
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	names, err := ListStreams(f.Name())
+	if err != nil || !reflect.DeepEqual(names, []string{"Zone.Identifier", "notes"}) {
+		t.Errorf("ListStreams = %q, %v", names, err)
+	}
+	st, err = OpenStream(f.Name(), "NOTES", os.O_RDONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadAll(st); err != nil || string(got) != "hello there" || st.Name() != "notes" {
+		t.Errorf("read %s = %q, %v", st.Name(), got, err)
+	}
+	st.Close()
+	if _, err := OpenStream(f.Name(), "notes", os.O_WRONLY|os.O_CREATE|os.O_EXCL); !errors.Is(err, os.ErrExist) {
+		t.Errorf("exclusive open = %v", err)
+	}
+
+	if err := RemoveStream(f.Name(), "notes"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadStream(f.Name(), "notes"); !errors.Is(err, xattr.ENOATTR) {
+		t.Errorf("ReadStream after remove = %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package samba
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SID is a Windows security identifier.
+type SID struct {
+	Revision uint8
+	// Authority is the 48-bit identifier authority.
+	Authority      uint64
+	SubAuthorities []uint32
+}
+
+// ParseSID parses the string form of a SID, such as "S-1-5-32-544".
+func ParseSID(s string) (SID, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || (parts[0] != "S" && parts[0] != "s") || len(parts) > 3+15 {
+		return SID{}, fmt.Errorf("samba: invalid SID %q", s)
+	}
+	rev, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return SID{}, fmt.Errorf("samba: invalid SID %q", s)
+	}
+	auth, err := strconv.ParseUint(parts[2], 0, 48)
+	if err != nil {
+		return SID{}, fmt.Errorf("samba: invalid SID %q", s)
+	}
+	sid := SID{Revision: uint8(rev), Authority: auth}
+	for _, p := range parts[3:] {
+		sub, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return SID{}, fmt.Errorf("samba: invalid SID %q", s)
+		}
+		sid.SubAuthorities = append(sid.SubAuthorities, uint32(sub))
+	}
+	return sid, nil
+}
+
+// MustParseSID is like ParseSID but panics on errors.
+func MustParseSID(s string) SID {
+	sid, err := ParseSID(s)
+	if err != nil {
+		panic(err)
+	}
+	return sid
+}
+
+// String returns the string form of sid.
+func (sid SID) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "S-%d-", sid.Revision)
+	if sid.Authority >= 1<<32 {
+		fmt.Fprintf(&b, "0x%012X", sid.Authority)
+	} else {
+		b.WriteString(strconv.FormatUint(sid.Authority, 10))
+	}
+	for _, sub := range sid.SubAuthorities {
+		b.WriteString("-" + strconv.FormatUint(uint64(sub), 10))
+	}
+	return b.String()
+}
+
+// Equal reports whether sid and other are the same SID.
+func (sid SID) Equal(other SID) bool {
+	return sid.String() == other.String()
+}
+
+func (sid SID) size() int {
+	return 8 + 4*len(sid.SubAuthorities)
+}
+
+func readSID(b []byte) (SID, int, error) {
+	if len(b) < 8 || b[1] > 15 || len(b) < 8+4*int(b[1]) {
+		return SID{}, 0, ErrFormat
+	}
+	sid := SID{Revision: b[0]}
+	for _, c := range b[2:8] {
+		sid.Authority = sid.Authority<<8 | uint64(c)
+	}
+	for i := 0; i < int(b[1]); i++ {
+		sid.SubAuthorities = append(sid.SubAuthorities, binary.LittleEndian.Uint32(b[8+4*i:]))
+	}
+	return sid, sid.size(), nil
+}
+
+func appendSID(b []byte, sid SID) []byte {
+	b = append(b, sid.Revision, byte(len(sid.SubAuthorities)))
+	for i := 5; i >= 0; i-- {
+		b = append(b, byte(sid.Authority>>(8*uint(i))))
+	}
+	for _, sub := range sid.SubAuthorities {
+		b = append(b, byte(sub), byte(sub>>8), byte(sub>>16), byte(sub>>24))
+	}
+	return b
+}
+
+// ACE types.
+const (
+	AccessAllowed       = 0x00
+	AccessDenied        = 0x01
+	SystemAudit         = 0x02
+	SystemAlarm         = 0x03
+	AccessAllowedObject = 0x05
+	AccessDeniedObject  = 0x06
+	SystemAuditObject   = 0x07
+	SystemAlarmObject   = 0x08
+)
+
+// ACE flags.
+const (
+	ObjectInherit      = 0x01
+	ContainerInherit   = 0x02
+	NoPropagateInherit = 0x04
+	InheritOnly        = 0x08
+	Inherited          = 0x10
+	SuccessfulAccess   = 0x40
+	FailedAccess       = 0x80
+)
+
+// Object ACE flags.
+const (
+	ObjectTypePresent          = 0x1
+	InheritedObjectTypePresent = 0x2
+)
+
+// ACE is an access control entry.
+type ACE struct {
+	Type  uint8
+	Flags uint8
+	Mask  uint32
+	SID   SID
+	// ObjectFlags, ObjectType and InheritedObjectType are only used by
+	// the object ACE types.
+	ObjectFlags         uint32
+	ObjectType          [16]byte
+	InheritedObjectType [16]byte
+	// ApplicationData holds the bytes following the SID, as used by
+	// callback ACEs. For ACE types this package does not know, it holds
+	// the whole body after the header and SID is empty.
+	ApplicationData []byte
+}
+
+func isObjectACE(typ uint8) bool {
+	return typ >= AccessAllowedObject && typ <= SystemAlarmObject
+}
+
+func isKnownACE(typ uint8) bool {
+	return typ <= SystemAlarm || isObjectACE(typ)
+}
+
+func readACE(b []byte) (ACE, int, error) {
+	if len(b) < 4 {
+		return ACE{}, 0, ErrFormat
+	}
+	size := int(binary.LittleEndian.Uint16(b[2:]))
+	if size < 4 || size > len(b) {
+		return ACE{}, 0, ErrFormat
+	}
+	ace := ACE{Type: b[0], Flags: b[1]}
+	body := b[4:size]
+	if !isKnownACE(ace.Type) {
+		ace.ApplicationData = append([]byte{}, body...)
+		return ace, size, nil
+	}
+	if len(body) < 4 {
+		return ACE{}, 0, ErrFormat
+	}
+	ace.Mask = binary.LittleEndian.Uint32(body)
+	body = body[4:]
+	if isObjectACE(ace.Type) {
+		if len(body) < 4 {
+			return ACE{}, 0, ErrFormat
+		}
+		ace.ObjectFlags = binary.LittleEndian.Uint32(body)
+		body = body[4:]
+		for _, f := range []struct {
+			flag uint32
+			dst  *[16]byte
+		}{{ObjectTypePresent, &ace.ObjectType}, {InheritedObjectTypePresent, &ace.InheritedObjectType}} {
+			if ace.ObjectFlags&f.flag == 0 {
+				continue
+			}
+			if len(body) < 16 {
+				return ACE{}, 0, ErrFormat
+			}
+			copy(f.dst[:], body)
+			body = body[16:]
+		}
+	}
+	sid, n, err := readSID(body)
+	if err != nil {
+		return ACE{}, 0, err
+	}
+	ace.SID = sid
+	if rest := body[n:]; len(rest) > 0 {
+		ace.ApplicationData = append([]byte{}, rest...)
+	}
+	return ace, size, nil
+}
+
+func appendACE(b []byte, ace ACE) []byte {
+	start := len(b)
+	b = append(b, ace.Type, ace.Flags, 0, 0)
+	if isKnownACE(ace.Type) {
+		b = appendUint32(b, ace.Mask)
+		if isObjectACE(ace.Type) {
+			b = appendUint32(b, ace.ObjectFlags)
+			if ace.ObjectFlags&ObjectTypePresent != 0 {
+				b = append(b, ace.ObjectType[:]...)
+			}
+			if ace.ObjectFlags&InheritedObjectTypePresent != 0 {
+				b = append(b, ace.InheritedObjectType[:]...)
+			}
+		}
+		b = appendSID(b, ace.SID)
+	}
+	b = append(b, ace.ApplicationData...)
+	for (len(b)-start)%4 != 0 {
+		b = append(b, 0)
+	}
+	binary.LittleEndian.PutUint16(b[start+2:], uint16(len(b)-start))
+	return b
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// ACL revisions.
+const (
+	ACLRevision   = 2
+	ACLRevisionDS = 4
+)
+
+// ACL is an access control list.
+type ACL struct {
+	Revision uint8
+	ACEs     []ACE
+}
+
+func readACL(b []byte) (*ACL, error) {
+	if len(b) < 8 {
+		return nil, ErrFormat
+	}
+	size, count := int(binary.LittleEndian.Uint16(b[2:])), int(binary.LittleEndian.Uint16(b[4:]))
+	if size < 8 || size > len(b) {
+		return nil, ErrFormat
+	}
+	acl := &ACL{Revision: b[0]}
+	p := b[8:size]
+	for i := 0; i < count; i++ {
+		ace, n, err := readACE(p)
+		if err != nil {
+			return nil, err
+		}
+		acl.ACEs = append(acl.ACEs, ace)
+		p = p[n:]
+	}
+	return acl, nil
+}
+
+func appendACL(b []byte, acl *ACL) []byte {
+	start := len(b)
+	rev := acl.Revision
+	if rev == 0 {
+		rev = ACLRevision
+	}
+	b = append(b, rev, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint16(b[start+4:], uint16(len(acl.ACEs)))
+	for _, ace := range acl.ACEs {
+		b = appendACE(b, ace)
+	}
+	binary.LittleEndian.PutUint16(b[start+2:], uint16(len(b)-start))
+	return b
+}
+
+// Security descriptor control flags.
+const (
+	OwnerDefaulted     = 0x0001
+	GroupDefaulted     = 0x0002
+	DACLPresent        = 0x0004
+	DACLDefaulted      = 0x0008
+	SACLPresent        = 0x0010
+	SACLDefaulted      = 0x0020
+	DACLAutoInheritReq = 0x0100
+	SACLAutoInheritReq = 0x0200
+	DACLAutoInherited  = 0x0400
+	SACLAutoInherited  = 0x0800
+	DACLProtected      = 0x1000
+	SACLProtected      = 0x2000
+	RMControlValid     = 0x4000
+	SelfRelative       = 0x8000
+)
+
+const (
+	sdRevision   = 1
+	sdHeaderSize = 20
+)
+
+// SecurityDescriptor is a Windows security descriptor. Owner, Group, SACL
+// and DACL are nil if absent.
+type SecurityDescriptor struct {
+	Revision uint8
+	Control  uint16
+	Owner    *SID
+	Group    *SID
+	SACL     *ACL
+	DACL     *ACL
+}
+
+// ParseSecurityDescriptor decodes a self-relative security descriptor.
+func ParseSecurityDescriptor(b []byte) (*SecurityDescriptor, error) {
+	if len(b) < sdHeaderSize {
+		return nil, ErrFormat
+	}
+	le := binary.LittleEndian
+	sd := &SecurityDescriptor{Revision: b[0], Control: le.Uint16(b[2:])}
+	offsets := [4]uint32{le.Uint32(b[4:]), le.Uint32(b[8:]), le.Uint32(b[12:]), le.Uint32(b[16:])}
+	for i, off := range offsets {
+		if off == 0 {
+			continue
+		}
+		if off < sdHeaderSize || uint64(off) >= uint64(len(b)) {
+			return nil, ErrFormat
+		}
+		var err error
+		switch i {
+		case 0, 1:
+			var sid SID
+			sid, _, err = readSID(b[off:])
+			if i == 0 {
+				sd.Owner = &sid
+			} else {
+				sd.Group = &sid
+			}
+		case 2:
+			sd.SACL, err = readACL(b[off:])
+		case 3:
+			sd.DACL, err = readACL(b[off:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sd, nil
+}
+
+// Encode returns sd in self-relative form, with the owner, group, SACL and
+// DACL in this order as Samba writes them. The present and self-relative
+// control flags are set to match.
+func (sd *SecurityDescriptor) Encode() []byte {
+	rev := sd.Revision
+	if rev == 0 {
+		rev = sdRevision
+	}
+	control := sd.Control | SelfRelative
+	control &^= DACLPresent | SACLPresent
+	if sd.DACL != nil {
+		control |= DACLPresent
+	}
+	if sd.SACL != nil {
+		control |= SACLPresent
+	}
+	b := make([]byte, sdHeaderSize)
+	b[0] = rev
+	binary.LittleEndian.PutUint16(b[2:], control)
+	if sd.Owner != nil {
+		binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+		b = appendSID(b, *sd.Owner)
+	}
+	if sd.Group != nil {
+		binary.LittleEndian.PutUint32(b[8:], uint32(len(b)))
+		b = appendSID(b, *sd.Group)
+	}
+	if sd.SACL != nil {
+		binary.LittleEndian.PutUint32(b[12:], uint32(len(b)))
+		b = appendACL(b, sd.SACL)
+	}
+	if sd.DACL != nil {
+		binary.LittleEndian.PutUint32(b[16:], uint32(len(b)))
+		b = appendACL(b, sd.DACL)
+	}
+	return b
+}
@@ -0,0 +1,208 @@
+package samba
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sidAliases are the SDDL abbreviations of well-known SIDs that do not
+// depend on the domain.
+var sidAliases = map[string]string{
+	"S-1-1-0":      "WD",
+	"S-1-3-0":      "CO",
+	"S-1-3-1":      "CG",
+	"S-1-3-4":      "OW",
+	"S-1-5-2":      "NU",
+	"S-1-5-4":      "IU",
+	"S-1-5-6":      "SU",
+	"S-1-5-7":      "AN",
+	"S-1-5-9":      "ED",
+	"S-1-5-10":     "PS",
+	"S-1-5-11":     "AU",
+	"S-1-5-12":     "RC",
+	"S-1-5-18":     "SY",
+	"S-1-5-19":     "LS",
+	"S-1-5-20":     "NS",
+	"S-1-5-32-544": "BA",
+	"S-1-5-32-545": "BU",
+	"S-1-5-32-546": "BG",
+	"S-1-5-32-547": "PU",
+	"S-1-5-32-548": "AO",
+	"S-1-5-32-549": "SO",
+	"S-1-5-32-550": "PO",
+	"S-1-5-32-551": "BO",
+	"S-1-5-32-552": "RE",
+	"S-1-5-32-554": "RU",
+	"S-1-5-32-555": "RD",
+	"S-1-5-32-556": "NO",
+}
+
+var aceTypeNames = map[uint8]string{
+	AccessAllowed:       "A",
+	AccessDenied:        "D",
+	SystemAudit:         "AU",
+	SystemAlarm:         "AL",
+	AccessAllowedObject: "OA",
+	AccessDeniedObject:  "OD",
+	SystemAuditObject:   "OU",
+	SystemAlarmObject:   "OL",
+}
+
+var aceFlagNames = []struct {
+	flag uint8
+	name string
+}{
+	{ObjectInherit, "OI"},
+	{ContainerInherit, "CI"},
+	{NoPropagateInherit, "NP"},
+	{InheritOnly, "IO"},
+	{Inherited, "ID"},
+	{SuccessfulAccess, "SA"},
+	{FailedAccess, "FA"},
+}
+
+// File access masks with an SDDL abbreviation.
+const (
+	FileAllAccess    = 0x001f01ff
+	FileGenericRead  = 0x00120089
+	FileGenericWrite = 0x00120116
+	FileGenericExec  = 0x001200a0
+	GenericAll       = 0x10000000
+	GenericExecute   = 0x20000000
+	GenericWrite     = 0x40000000
+	GenericRead      = 0x80000000
+	StandardDelete   = 0x00010000
+	ReadControl      = 0x00020000
+	WriteDAC         = 0x00040000
+	WriteOwner       = 0x00080000
+)
+
+var fileRights = []struct {
+	mask uint32
+	name string
+}{
+	{FileAllAccess, "FA"},
+	{FileGenericRead, "FR"},
+	{FileGenericWrite, "FW"},
+	{FileGenericExec, "FX"},
+}
+
+var genericRights = []struct {
+	mask uint32
+	name string
+}{
+	{GenericAll, "GA"},
+	{GenericRead, "GR"},
+	{GenericWrite, "GW"},
+	{GenericExecute, "GX"},
+	{ReadControl, "RC"},
+	{StandardDelete, "SD"},
+	{WriteDAC, "WD"},
+	{WriteOwner, "WO"},
+}
+
+// sddlSID returns the SDDL form of sid.
+func sddlSID(sid SID) string {
+	s := sid.String()
+	if alias, ok := sidAliases[s]; ok {
+		return alias
+	}
+	return s
+}
+
+// sddlRights returns the SDDL form of an access mask: a file right
+// abbreviation if one matches exactly, a combination of generic and
+// standard rights if they cover the mask, and hexadecimal otherwise.
+func sddlRights(mask uint32) string {
+	for _, r := range fileRights {
+		if mask == r.mask {
+			return r.name
+		}
+	}
+	var b strings.Builder
+	rest := mask
+	for _, r := range genericRights {
+		if rest&r.mask != 0 {
+			b.WriteString(r.name)
+			rest &^= r.mask
+		}
+	}
+	if rest != 0 || mask == 0 {
+		return fmt.Sprintf("0x%x", mask)
+	}
+	return b.String()
+}
+
+func sddlGUID(g [16]byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%02x%02x-%02x%02x%02x%02x%02x%02x",
+		uint32(g[3])<<24|uint32(g[2])<<16|uint32(g[1])<<8|uint32(g[0]),
+		uint16(g[5])<<8|uint16(g[4]), uint16(g[7])<<8|uint16(g[6]),
+		g[8], g[9], g[10], g[11], g[12], g[13], g[14], g[15])
+}
+
+// SDDL returns ace in the Security Descriptor Definition Language, such as
+// "(A;OICI;FA;;;BA)". ACE types without an SDDL form are written with
+// their number in hexadecimal.
+func (ace ACE) SDDL() string {
+	typ, ok := aceTypeNames[ace.Type]
+	if !ok {
+		typ = fmt.Sprintf("0x%x", ace.Type)
+	}
+	var flags strings.Builder
+	for _, f := range aceFlagNames {
+		if ace.Flags&f.flag != 0 {
+			flags.WriteString(f.name)
+		}
+	}
+	var objType, inhType string
+	if isObjectACE(ace.Type) {
+		if ace.ObjectFlags&ObjectTypePresent != 0 {
+			objType = sddlGUID(ace.ObjectType)
+		}
+		if ace.ObjectFlags&InheritedObjectTypePresent != 0 {
+			inhType = sddlGUID(ace.InheritedObjectType)
+		}
+	}
+	sid := ""
+	if isKnownACE(ace.Type) {
+		sid = sddlSID(ace.SID)
+	}
+	return fmt.Sprintf("(%s;%s;%s;%s;%s;%s)", typ, flags.String(), sddlRights(ace.Mask), objType, inhType, sid)
+}
+
+func sddlACL(prefix string, acl *ACL, control uint16, protected, autoInheritReq, autoInherited uint16) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	if control&protected != 0 {
+		b.WriteString("P")
+	}
+	if control&autoInheritReq != 0 {
+		b.WriteString("AR")
+	}
+	if control&autoInherited != 0 {
+		b.WriteString("AI")
+	}
+	for _, ace := range acl.ACEs {
+		b.WriteString(ace.SDDL())
+	}
+	return b.String()
+}
+
+// SDDL returns sd in the Security Descriptor Definition Language, for
+// example "O:BAG:SYD:PAI(A;OICI;FA;;;BA)(A;OICIIO;FA;;;CO)".
+func (sd *SecurityDescriptor) SDDL() string {
+	var b strings.Builder
+	if sd.Owner != nil {
+		b.WriteString("O:" + sddlSID(*sd.Owner))
+	}
+	if sd.Group != nil {
+		b.WriteString("G:" + sddlSID(*sd.Group))
+	}
+	if sd.DACL != nil {
+		b.WriteString(sddlACL("D:", sd.DACL, sd.Control, DACLProtected, DACLAutoInheritReq, DACLAutoInherited))
+	}
+	if sd.SACL != nil {
+		b.WriteString(sddlACL("S:", sd.SACL, sd.Control, SACLProtected, SACLAutoInheritReq, SACLAutoInherited))
+	}
+	return b.String()
+}
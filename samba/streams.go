@@ -0,0 +1,320 @@
+package samba
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/xattr"
+)
+
+// StreamPrefix is the default attribute name prefix of Samba's
+// streams_xattr module, set with the "streams_xattr:prefix" option.
+const StreamPrefix = "user.DosStream."
+
+// streamSuffix is the stream type Samba appends to the attribute name.
+const streamSuffix = ":$DATA"
+
+// ErrStreamName is returned for names that are not valid stream names.
+var ErrStreamName = errors.New("samba: invalid stream name")
+
+// Streams gives access to the alternate data streams that the
+// streams_xattr module stores as "<prefix><name>:$DATA" attributes. Samba
+// appends a NUL byte to every stream; it is added and removed
+// transparently. Stream names are matched case-insensitively, like Windows
+// does, but keep their case when created.
+type Streams struct {
+	// Prefix is the attribute name prefix. If empty, StreamPrefix is used.
+	Prefix string
+}
+
+// DefaultStreams uses the default prefix.
+var DefaultStreams = Streams{}
+
+func (s Streams) prefix() string {
+	if s.Prefix == "" {
+		return StreamPrefix
+	}
+	return s.Prefix
+}
+
+// streamName validates a stream name and strips an optional ":$DATA".
+func streamName(name string) (string, error) {
+	if strings.HasSuffix(strings.ToUpper(name), streamSuffix) {
+		name = name[:len(name)-len(streamSuffix)]
+	}
+	if name == "" || strings.ContainsAny(name, ":/\\\x00") {
+		return "", fmt.Errorf("%w: %q", ErrStreamName, name)
+	}
+	return name, nil
+}
+
+// List returns the sorted names of the streams of path.
+func (s Streams) List(path string) ([]string, error) {
+	attrs, err := xattr.LList(path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, attr := range attrs {
+		if strings.HasPrefix(attr, s.prefix()) && strings.HasSuffix(attr, streamSuffix) {
+			names = append(names, attr[len(s.prefix()):len(attr)-len(streamSuffix)])
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// lookup returns the attribute name of the stream name of path. If no
+// stream matches, it returns the name a new stream would get and false.
+func (s Streams) lookup(path, name string) (string, bool, error) {
+	name, err := streamName(name)
+	if err != nil {
+		return "", false, err
+	}
+	attr := s.prefix() + name + streamSuffix
+	names, err := s.List(path)
+	if err != nil {
+		return "", false, err
+	}
+	for _, n := range names {
+		if n == name {
+			return attr, true, nil
+		}
+	}
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return s.prefix() + n + streamSuffix, true, nil
+		}
+	}
+	return attr, false, nil
+}
+
+// Read returns the content of the stream name of path. It fails with
+// ENOATTR if there is no such stream.
+func (s Streams) Read(path, name string) ([]byte, error) {
+	attr, ok, err := s.lookup(path, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &xattr.Error{Op: "samba.ReadStream", Path: path, Name: attr, Err: xattr.ENOATTR}
+	}
+	value, err := xattr.LGet(path, attr)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(value); n > 0 && value[n-1] == 0 {
+		value = value[:n-1]
+	}
+	return value, nil
+}
+
+// Write replaces the content of the stream name of path, creating the
+// stream if needed.
+func (s Streams) Write(path, name string, data []byte) error {
+	attr, _, err := s.lookup(path, name)
+	if err != nil {
+		return err
+	}
+	value := make([]byte, len(data)+1)
+	copy(value, data)
+	return xattr.LSet(path, attr, value)
+}
+
+// Remove deletes the stream name of path.
+func (s Streams) Remove(path, name string) error {
+	attr, ok, err := s.lookup(path, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &xattr.Error{Op: "samba.RemoveStream", Path: path, Name: attr, Err: xattr.ENOATTR}
+	}
+	return xattr.LRemove(path, attr)
+}
+
+// Open opens the stream name of path. flag is a combination of the os.O_*
+// flags: O_RDONLY, O_WRONLY or O_RDWR, and optionally O_CREATE, O_EXCL,
+// O_TRUNC and O_APPEND. The content is held in memory and written back
+// when the stream is closed, if it was changed.
+func (s Streams) Open(path, name string, flag int) (*Stream, error) {
+	attr, ok, err := s.lookup(path, name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &xattr.Error{Op: "samba.OpenStream", Path: path, Name: attr, Err: xattr.ENOATTR}
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &xattr.Error{Op: "samba.OpenStream", Path: path, Name: attr, Err: os.ErrExist}
+	}
+	st := &Stream{path: path, name: attr[len(s.prefix()) : len(attr)-len(streamSuffix)], streams: s, flag: flag}
+	if ok && flag&os.O_TRUNC == 0 {
+		if st.data, err = s.Read(path, st.name); err != nil {
+			return nil, err
+		}
+	}
+	st.dirty = !ok || flag&os.O_TRUNC != 0
+	if st.dirty && st.writable() {
+		// Create or truncate the stream right away, like a file.
+		if err := s.Write(path, st.name, nil); err != nil {
+			return nil, err
+		}
+		st.dirty = false
+	}
+	return st, nil
+}
+
+// Stream is an open alternate data stream.
+type Stream struct {
+	path    string
+	name    string
+	streams Streams
+	flag    int
+	data    []byte
+	off     int64
+	dirty   bool
+	closed  bool
+}
+
+var errClosed = errors.New("samba: stream is closed")
+
+func (st *Stream) writable() bool {
+	return st.flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+// Name returns the name of the stream.
+func (st *Stream) Name() string { return st.name }
+
+// Size returns the current size of the stream.
+func (st *Stream) Size() int64 { return int64(len(st.data)) }
+
+// Read implements io.Reader.
+func (st *Stream) Read(p []byte) (int, error) {
+	if st.closed {
+		return 0, errClosed
+	}
+	if st.flag&os.O_WRONLY != 0 {
+		return 0, fmt.Errorf("samba: stream %s not open for reading", st.name)
+	}
+	if st.off >= int64(len(st.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, st.data[st.off:])
+	st.off += int64(n)
+	return n, nil
+}
+
+// Write implements io.Writer.
+func (st *Stream) Write(p []byte) (int, error) {
+	if st.closed {
+		return 0, errClosed
+	}
+	if !st.writable() {
+		return 0, fmt.Errorf("samba: stream %s not open for writing", st.name)
+	}
+	if len(p) == 0 {
+		// Like a file, an empty write after seeking past the end does not
+		// extend the stream.
+		return 0, nil
+	}
+	if st.flag&os.O_APPEND != 0 {
+		st.off = int64(len(st.data))
+	}
+	if end := st.off + int64(len(p)); end > int64(len(st.data)) {
+		st.data = append(st.data, make([]byte, end-int64(len(st.data)))...)
+	}
+	copy(st.data[st.off:], p)
+	st.off += int64(len(p))
+	st.dirty = true
+	return len(p), nil
+}
+
+// Seek implements io.Seeker.
+func (st *Stream) Seek(offset int64, whence int) (int64, error) {
+	if st.closed {
+		return 0, errClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += st.off
+	case io.SeekEnd:
+		offset += int64(len(st.data))
+	default:
+		return 0, fmt.Errorf("samba: invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("samba: negative position")
+	}
+	st.off = offset
+	return offset, nil
+}
+
+// Truncate changes the size of the stream.
+func (st *Stream) Truncate(size int64) error {
+	if st.closed {
+		return errClosed
+	}
+	if !st.writable() {
+		return fmt.Errorf("samba: stream %s not open for writing", st.name)
+	}
+	if size < 0 {
+		return fmt.Errorf("samba: negative size")
+	}
+	if size < int64(len(st.data)) {
+		st.data = st.data[:size]
+	} else {
+		st.data = append(st.data, make([]byte, size-int64(len(st.data)))...)
+	}
+	st.dirty = true
+	return nil
+}
+
+// Sync writes the content back if it was changed.
+func (st *Stream) Sync() error {
+	if st.closed {
+		return errClosed
+	}
+	if !st.dirty {
+		return nil
+	}
+	if err := st.streams.Write(st.path, st.name, st.data); err != nil {
+		return err
+	}
+	st.dirty = false
+	return nil
+}
+
+// Close writes the content back if it was changed and closes the stream.
+func (st *Stream) Close() error {
+	if st.closed {
+		return errClosed
+	}
+	err := st.Sync()
+	st.closed = true
+	return err
+}
+
+// ListStreams returns the names of the streams of path.
+func ListStreams(path string) ([]string, error) { return DefaultStreams.List(path) }
+
+// ReadStream returns the content of the stream name of path.
+func ReadStream(path, name string) ([]byte, error) { return DefaultStreams.Read(path, name) }
+
+// WriteStream replaces the content of the stream name of path.
+func WriteStream(path, name string, data []byte) error {
+	return DefaultStreams.Write(path, name, data)
+}
+
+// RemoveStream deletes the stream name of path.
+func RemoveStream(path, name string) error { return DefaultStreams.Remove(path, name) }
+
+// OpenStream opens the stream name of path, see Streams.Open.
+func OpenStream(path, name string, flag int) (*Stream, error) {
+	return DefaultStreams.Open(path, name, flag)
+}
@@ -0,0 +1,287 @@
+package search
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// token kinds
+const (
+	tEOF = iota
+	tWord
+	tString
+	tOp
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// lex splits s into tokens. Words are runs of characters other than white
+// space, quotes and operator characters; strings are double-quoted with Go
+// escapes.
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, &SyntaxError{i, "unterminated string"}
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, &SyntaxError{i, "invalid string"}
+			}
+			toks = append(toks, token{tString, text, i})
+			i = j + 1
+		case strings.HasPrefix(s[i:], "&&"), strings.HasPrefix(s[i:], "||"),
+			strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			toks = append(toks, token{tOp, s[i : i+2], i})
+			i += 2
+		case strings.IndexByte("()!=<>~", c) >= 0:
+			toks = append(toks, token{tOp, s[i : i+1], i})
+			i++
+		case c == '&' || c == '|':
+			return nil, &SyntaxError{i, "unexpected " + string(c)}
+		default:
+			j := i
+			for j < len(s) && !isSpecial(s[j]) {
+				j++
+			}
+			toks = append(toks, token{tWord, s[i:j], i})
+			i = j
+		}
+	}
+	return append(toks, token{tEOF, "", len(s)}), nil
+}
+
+func isSpecial(c byte) bool {
+	return unicode.IsSpace(rune(c)) || strings.IndexByte(`"()!=<>~&|`, c) >= 0
+}
+
+// SyntaxError reports an invalid expression.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("search: %s at offset %d", e.Msg, e.Offset)
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the given operators or
+// keywords.
+func (p *parser) accept(texts ...string) bool {
+	t := p.peek()
+	if t.kind != tOp && t.kind != tWord {
+		return false
+	}
+	for _, s := range texts {
+		if t.text == s {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{p.peek().pos, fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (node, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &orNode{x, y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		y, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		x = &andNode{x, y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("not", "!") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.accept("(") {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return x, nil
+	}
+	if p.accept("has") {
+		m, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		return &testNode{name: m}, nil
+	}
+	if p.peek().kind == tWord && p.peek().text == "size" && p.toks[p.pos+1].text == "(" {
+		p.pos += 2
+		m, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		t := &testNode{name: m, size: true}
+		if err := p.parseComparison(t); err != nil {
+			return nil, err
+		}
+		if t.op == "" {
+			return nil, p.errorf("size() needs a comparison")
+		}
+		return t, nil
+	}
+	m, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	t := &testNode{name: m}
+	if err := p.parseComparison(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseName parses an attribute name pattern: a plain name, or a name
+// prefixed with "prefix:", "glob:" or "re:". The pattern may be quoted,
+// also after the kind prefix.
+func (p *parser) parseName() (*nameMatcher, error) {
+	t := p.next()
+	if t.kind != tWord && t.kind != tString {
+		return nil, &SyntaxError{t.pos, "expected attribute name"}
+	}
+	kind, pattern := "", t.text
+	if t.kind == tWord {
+		for _, k := range []string{"prefix:", "glob:", "re:"} {
+			if strings.HasPrefix(t.text, k) {
+				kind, pattern = k[:len(k)-1], t.text[len(k):]
+				break
+			}
+		}
+		if kind != "" && pattern == "" && p.peek().kind == tString {
+			pattern = p.next().text
+		}
+	}
+	m := &nameMatcher{kind: kind, pattern: pattern}
+	switch kind {
+	case "glob":
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, &SyntaxError{t.pos, "invalid glob pattern"}
+		}
+	case "re":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, &SyntaxError{t.pos, "invalid regular expression"}
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// parseComparison parses an optional comparison operator and operand.
+func (p *parser) parseComparison(t *testNode) error {
+	for _, op := range []string{"==", "=", "!=", "~", "<=", "<", ">=", ">"} {
+		if p.accept(op) {
+			if op == "==" {
+				op = "="
+			}
+			t.op = op
+			break
+		}
+	}
+	if t.op == "" {
+		return nil
+	}
+	v := p.next()
+	if v.kind != tWord && v.kind != tString {
+		return &SyntaxError{v.pos, "expected value"}
+	}
+	t.value = v.text
+	switch t.op {
+	case "<", "<=", ">", ">=":
+		n, err := strconv.ParseFloat(v.text, 64)
+		if err != nil {
+			return &SyntaxError{v.pos, "expected number"}
+		}
+		t.num = n
+	}
+	if t.size {
+		n, err := strconv.ParseFloat(v.text, 64)
+		if err != nil {
+			return &SyntaxError{v.pos, "expected number"}
+		}
+		t.num = n
+		if t.op == "~" {
+			return &SyntaxError{v.pos, "~ cannot be used with size()"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+/*
+Package search finds files by their extended attributes.
+
+Files are selected with a small expression language. A test names an
+attribute and optionally compares its value:
+
+	user.owner                  attribute exists
+	has user.owner              same
+	user.owner = alice          value equals "alice"
+	user.owner != "bob smith"   value differs
+	user.comment ~ draft        value contains "draft"
+	user.retention < 30         value is a number less than 30
+	size(user.thumbnail) > 4096 value is larger than 4096 bytes
+
+The operators <, <=, > and >= compare numerically; values that are not
+numbers never match. The name may be a pattern:
+
+	prefix:user.app.            name starts with "user.app."
+	glob:user.*.owner           name matches a path.Match pattern
+	re:"^user\\.v[0-9]+$"       name matches a regular expression
+
+A pattern test matches if any attribute with a matching name satisfies
+the comparison. Tests are combined with "and" (&&), "or" (||), "not" (!)
+and parentheses, and bind in that order:
+
+	has user.owner and user.retention < 30
+	security.capability or not prefix:user.
+*/
+package search
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+// Expr is a parsed search expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses a search expression.
+func Parse(s string) (*Expr, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tEOF {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+	return &Expr{s, root}, nil
+}
+
+// MustParse is like Parse but panics if the expression is invalid.
+func MustParse(s string) *Expr {
+	e, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func (e *Expr) String() string { return e.src }
+
+// Attr is an attribute that contributed to a match.
+type Attr struct {
+	Name  string
+	Value []byte
+}
+
+// Match is a file selected by an expression. Attrs lists the attributes
+// that satisfied a test of the expression, sorted by name; attributes only
+// referenced under "not" are not included.
+type Match struct {
+	Path  string
+	Attrs []Attr
+}
+
+// Match evaluates the expression against the attributes of path, without
+// following a symlink at the end of the path. It returns nil if the file
+// does not match.
+func (e *Expr) Match(path string) (*Match, error) {
+	names, err := walk.List(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &file{path: path, names: names, values: map[string][]byte{}, matched: map[string]bool{}}
+	ok, err := e.root.eval(f, true)
+	if err != nil || !ok {
+		return nil, err
+	}
+	m := &Match{Path: path}
+	for _, name := range names {
+		if f.matched[name] {
+			m.Attrs = append(m.Attrs, Attr{name, f.values[name]})
+		}
+	}
+	return m, nil
+}
+
+// Search walks the tree rooted at root without following symlinks and calls
+// fn for every file or directory that matches e. If fn returns an error,
+// Search stops and returns it. Files that cannot be read are skipped; once
+// done, Search returns an error listing them.
+func Search(root string, e *Expr, fn func(*Match) error) error {
+	var errs walk.Errors
+	err := walk.All(root, &errs, func(path, rel string, info os.FileInfo) error {
+		m, err := e.Match(path)
+		if err != nil {
+			errs.Add(err)
+			return nil
+		}
+		if m == nil {
+			return nil
+		}
+		return fn(m)
+	})
+	if err != nil {
+		return err
+	}
+	return errs.Err()
+}
+
+// file holds the attributes of the file under evaluation. Values are read
+// on demand and cached.
+type file struct {
+	path    string
+	names   []string
+	values  map[string][]byte
+	matched map[string]bool
+}
+
+func (f *file) value(name string) ([]byte, bool, error) {
+	if v, ok := f.values[name]; ok {
+		return v, true, nil
+	}
+	v, err := xattr.LGet(f.path, name)
+	if err != nil {
+		if walk.Vanished(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	f.values[name] = v
+	return v, true, nil
+}
+
+type node interface {
+	// eval reports whether f satisfies the node. If record is true, the
+	// attributes that satisfied a test are recorded in f.matched.
+	eval(f *file, record bool) (bool, error)
+}
+
+type orNode struct{ x, y node }
+
+func (n *orNode) eval(f *file, record bool) (bool, error) {
+	ok, err := n.x.eval(f, record)
+	if err != nil || ok {
+		return ok, err
+	}
+	return n.y.eval(f, record)
+}
+
+type andNode struct{ x, y node }
+
+func (n *andNode) eval(f *file, record bool) (bool, error) {
+	ok, err := n.x.eval(f, record)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return n.y.eval(f, record)
+}
+
+type notNode struct{ x node }
+
+func (n *notNode) eval(f *file, record bool) (bool, error) {
+	ok, err := n.x.eval(f, false)
+	return !ok, err
+}
+
+type nameMatcher struct {
+	kind    string // "", "prefix", "glob" or "re"
+	pattern string
+	re      *regexp.Regexp
+}
+
+func (m *nameMatcher) match(name string) bool {
+	switch m.kind {
+	case "prefix":
+		return strings.HasPrefix(name, m.pattern)
+	case "glob":
+		ok, _ := path.Match(m.pattern, name)
+		return ok
+	case "re":
+		return m.re.MatchString(name)
+	}
+	return name == m.pattern
+}
+
+// testNode tests the attributes whose names match name. Without op, it
+// tests for existence.
+type testNode struct {
+	name  *nameMatcher
+	size  bool
+	op    string
+	value string
+	num   float64
+}
+
+func (n *testNode) eval(f *file, record bool) (bool, error) {
+	found := false
+	for _, name := range f.names {
+		if !n.name.match(name) {
+			continue
+		}
+		ok := true
+		if n.op != "" || record {
+			v, exists, err := f.value(name)
+			if err != nil {
+				return false, err
+			}
+			ok = exists && (n.op == "" || n.compare(v))
+		}
+		if ok {
+			found = true
+			if !record {
+				return true, nil
+			}
+			f.matched[name] = true
+		}
+	}
+	return found, nil
+}
+
+func (n *testNode) compare(v []byte) bool {
+	if n.size {
+		return compareNum(float64(len(v)), n.op, n.num)
+	}
+	switch n.op {
+	case "=":
+		return string(v) == n.value
+	case "!=":
+		return string(v) != n.value
+	case "~":
+		return strings.Contains(string(v), n.value)
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimRight(string(v), "\x00")), 64)
+	if err != nil {
+		return false
+	}
+	return compareNum(x, n.op, n.num)
+}
+
+func compareNum(x float64, op string, y float64) bool {
+	switch op {
+	case "=":
+		return x == y
+	case "!=":
+		return x != y
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	case ">=":
+		return x >= y
+	}
+	return false
+}
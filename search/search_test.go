@@ -0,0 +1,142 @@
+package search
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/xattr/internal/walk"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"user.a and",
+		"(user.a",
+		"user.a < x",
+		"size(user.a)",
+		"size(user.a) ~ 3",
+		"re:(",
+		`user.a = "x`,
+		"user.a & user.b",
+		"user.a user.b",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-search-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]map[string]string{
+		"a": {"user.owner": "alice", "user.retention": "7"},
+		"b": {"user.owner": "bob smith", "user.retention": "90\x00"},
+		"c": {"user.app.v1": "draft-1", "user.app.v2": "final"},
+		"d": {},
+	}
+	for name, attrs := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range attrs {
+			xattrtest.Set(t, path, k, v)
+		}
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"user.owner", []string{"a", "b"}},
+		{"has user.owner and user.retention < 30", []string{"a"}},
+		{"user.retention >= 30", []string{"b"}},
+		{`user.owner = "bob smith"`, []string{"b"}},
+		{"user.owner != alice", []string{"b"}},
+		{"user.owner ~ ali", []string{"a"}},
+		{"prefix:user.app.", []string{"c"}},
+		{"glob:user.app.v*", []string{"c"}},
+		{`re:"^user\\.app\\.v[0-9]$" = final`, []string{"c"}},
+		{"size(user.owner) > 5", []string{"b"}},
+		{"not prefix:user.", []string{".", "d"}},
+		{"!(user.owner || user.app.v1) && ! user.x", []string{".", "d"}},
+		{"user.owner = alice or glob:*.v1 ~ draft", []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		var have []string
+		err = Search(dir, e, func(m *Match) error {
+			rel, _ := filepath.Rel(dir, m.Path)
+			have = append(have, rel)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("%q: have %v, want %v", tt.expr, have, tt.want)
+		}
+	}
+}
+
+func TestMatchedAttrs(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "xattr-search-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	for k, v := range map[string]string{"user.a": "1", "user.b": "2", "user.c": "3"} {
+		xattrtest.Set(t, tmp.Name(), k, v)
+	}
+	m, err := MustParse("user.a and user.b > 1 and not user.c = 4").Match(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Attr{{"user.a", []byte("1")}, {"user.b", []byte("2")}}
+	if m == nil || !reflect.DeepEqual(m.Attrs, want) {
+		t.Errorf("have %+v, want attributes %q", m, want)
+	}
+}
+
+func TestSearchGoesOn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xattr-search-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var found []string
+	err = Search(dir, MustParse("not user.x"), func(m *Match) error {
+		rel, _ := filepath.Rel(dir, m.Path)
+		found = append(found, rel)
+		if rel == "a" {
+			// The walk has listed b already and fails on it.
+			os.Remove(filepath.Join(dir, "b"))
+		}
+		return nil
+	})
+	if errs, ok := err.(walk.Errors); !ok || len(errs) != 1 || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("err = %v", err)
+	}
+	if want := []string{".", "a", "c"}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %q, want %q", found, want)
+	}
+}
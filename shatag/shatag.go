@@ -0,0 +1,291 @@
+/*
+Package shatag caches content digests of files in extended attributes,
+following the convention of the shatag and cshatag tools:
+
+	user.shatag.sha256   hex digest of the content
+	user.shatag.ts       modification time when the digest was computed
+
+A cached digest is used as long as the modification time of the file still
+matches the timestamp. Verify recomputes the digest regardless, which
+detects silent corruption ("bit rot"): content that changed although the
+modification time did not.
+
+Other algorithms store their digest under "user.shatag.<name>" and share
+the timestamp attribute, so a file may carry digests of several algorithms.
+*/
+package shatag
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/xattr"
+)
+
+// Prefix is the canonical prefix of the attributes.
+const Prefix = "user.shatag."
+
+// TimestampAttr is the canonical name of the timestamp attribute.
+const TimestampAttr = Prefix + "ts"
+
+// Algorithm is a digest algorithm stored under Prefix+Name.
+type Algorithm struct {
+	Name string
+	New  func() hash.Hash
+}
+
+// Supported algorithms. SHA256 is the one used by shatag and cshatag.
+var (
+	SHA256 = Algorithm{"sha256", sha256.New}
+	SHA512 = Algorithm{"sha512", sha512.New}
+	SHA1   = Algorithm{"sha1", sha1.New}
+	MD5    = Algorithm{"md5", md5.New}
+)
+
+// ErrChanged is returned when a file is modified while it is being hashed.
+var ErrChanged = errors.New("shatag: file changed while hashing")
+
+// Status is the outcome of Verify.
+type Status int
+
+const (
+	// OK means the cached digest matches the content.
+	OK Status = iota
+	// New means there was no cached digest; it has been stored.
+	New
+	// Outdated means the file was modified since the digest was cached;
+	// the new digest has been stored.
+	Outdated
+	// Corrupt means the content does not match the cached digest although
+	// the modification time does. The cached digest is left alone.
+	Corrupt
+)
+
+var statusNames = [...]string{
+	OK:       "ok",
+	New:      "new",
+	Outdated: "outdated",
+	Corrupt:  "corrupt",
+}
+
+func (s Status) String() string {
+	if int(s) < len(statusNames) {
+		return statusNames[s]
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// Result is the outcome of Verify for a single file.
+type Result struct {
+	Status Status
+	// Cached is the digest that was stored before, if any.
+	Cached string
+	// Actual is the digest of the current content.
+	Actual string
+}
+
+// FormatTimestamp formats t like cshatag: seconds and nanoseconds since the
+// epoch, separated by a dot.
+func FormatTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// matchTimestamp reports whether the stored timestamp ts matches t. The
+// original shatag wrote Python floats with fewer fractional digits; those
+// are compared at the precision they have.
+func matchTimestamp(ts string, t time.Time) bool {
+	secs, frac := ts, ""
+	if i := strings.IndexByte(ts, '.'); i >= 0 {
+		secs, frac = ts[:i], ts[i+1:]
+	}
+	s, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil || s != t.Unix() || len(frac) > 9 {
+		return false
+	}
+	if frac == "" {
+		return true
+	}
+	n, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return false
+	}
+	want := int64(t.Nanosecond())
+	for i := len(frac); i < 9; i++ {
+		want /= 10
+	}
+	return n == want
+}
+
+func get(path, name string) (string, error) {
+	native, err := xattr.Native(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := xattr.Get(path, native)
+	return string(value), err
+}
+
+func set(path, name, value string) error {
+	native, err := xattr.Native(name)
+	if err != nil {
+		return err
+	}
+	return xattr.Set(path, native, []byte(value))
+}
+
+// cached returns the cached digest of path, "" if there is none, and
+// whether the timestamp matches mtime.
+func (a Algorithm) cached(path string, mtime time.Time) (string, bool, error) {
+	sum, err := get(path, Prefix+a.Name)
+	if err != nil && !errors.Is(err, xattr.ENOATTR) {
+		return "", false, err
+	}
+	ts, err := get(path, TimestampAttr)
+	if errors.Is(err, xattr.ENOATTR) {
+		return sum, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return sum, matchTimestamp(ts, mtime), nil
+}
+
+// hash computes the digest of path. It fails with ErrChanged if the
+// modification time differs from mtime afterwards.
+func (a Algorithm) hash(path string, mtime time.Time) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := a.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.ModTime().Equal(mtime) {
+		return "", ErrChanged
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// store writes the digest before the timestamp, so that an interrupted
+// store never pairs a stale digest with a current timestamp. Unless the
+// timestamp is fresh, the digests of other algorithms are removed first,
+// since the new timestamp would make them look current.
+func (a Algorithm) store(path, sum string, mtime time.Time, fresh bool) error {
+	if !fresh {
+		if err := a.dropOthers(path); err != nil {
+			return err
+		}
+	}
+	if err := set(path, Prefix+a.Name, sum); err != nil {
+		return err
+	}
+	return set(path, TimestampAttr, FormatTimestamp(mtime))
+}
+
+// dropOthers removes the digests of all other algorithms from path.
+func (a Algorithm) dropOthers(path string) error {
+	names, err := xattr.List(path)
+	if err != nil {
+		return err
+	}
+	for _, native := range names {
+		name := xattr.Canonical(native)
+		if !strings.HasPrefix(name, Prefix) || name == TimestampAttr || name == Prefix+a.Name {
+			continue
+		}
+		if err := xattr.Remove(path, native); err != nil && !errors.Is(err, xattr.ENOATTR) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Digest returns the hex digest of the content of path. The cached digest
+// is returned if its timestamp matches the modification time; otherwise the
+// digest is computed and stored. The digest is returned even if storing it
+// fails.
+func (a Algorithm) Digest(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	sum, fresh, err := a.cached(path, info.ModTime())
+	if err != nil {
+		return "", err
+	}
+	if fresh && sum != "" {
+		return sum, nil
+	}
+	if sum, err = a.hash(path, info.ModTime()); err != nil {
+		return "", err
+	}
+	return sum, a.store(path, sum, info.ModTime(), fresh)
+}
+
+// Verify computes the digest of path and compares it with the cached one.
+// New and outdated digests are stored; a corrupt file is left alone.
+func (a Algorithm) Verify(path string) (Result, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Result{}, err
+	}
+	cached, fresh, err := a.cached(path, info.ModTime())
+	if err != nil {
+		return Result{}, err
+	}
+	actual, err := a.hash(path, info.ModTime())
+	if err != nil {
+		return Result{}, err
+	}
+	r := Result{Cached: cached, Actual: actual}
+	switch {
+	case cached == "":
+		r.Status = New
+	case !fresh:
+		r.Status = Outdated
+	case cached != actual:
+		r.Status = Corrupt
+		return r, nil
+	default:
+		r.Status = OK
+		return r, nil
+	}
+	return r, a.store(path, actual, info.ModTime(), fresh)
+}
+
+// Digest returns the SHA-256 digest of path, see Algorithm.Digest.
+func Digest(path string) (string, error) {
+	return SHA256.Digest(path)
+}
+
+// Verify verifies the SHA-256 digest of path, see Algorithm.Verify.
+func Verify(path string) (Result, error) {
+	return SHA256.Verify(path)
+}
+
+// Lookup returns the supported algorithm with the given name.
+func Lookup(name string) (Algorithm, bool) {
+	for _, a := range []Algorithm{SHA256, SHA512, SHA1, MD5} {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Algorithm{}, false
+}
@@ -0,0 +1,105 @@
+package shatag
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+const (
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	helloMD5    = "5d41402abc4b2a76b9719d911017c592"
+	worldSHA256 = "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7"
+)
+
+func TestTimestamp(t *testing.T) {
+	mtime := time.Unix(1326455566, 534092700)
+	if s := FormatTimestamp(mtime); s != "1326455566.534092700" {
+		t.Errorf("FormatTimestamp = %q", s)
+	}
+	for ts, want := range map[string]bool{
+		"1326455566.534092700": true,
+		"1326455566.5340927":   true,
+		"1326455566.53":        true,
+		"1326455566.54":        false,
+		"1326455566":           true,
+		"1326455567.534092700": false,
+		"junk":                 false,
+	} {
+		if got := matchTimestamp(ts, mtime); got != want {
+			t.Errorf("matchTimestamp(%q) = %v", ts, got)
+		}
+	}
+}
+
+func TestDigestVerify(t *testing.T) {
+	f, err := ioutil.TempFile("", "xattr-shatag-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := f.Name()
+	defer os.Remove(path)
+	f.WriteString("hello")
+	f.Close()
+	mtime := time.Unix(1600000000, 123456789)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := Digest(path)
+	xattrtest.Check(t, err)
+	if sum != helloSHA256 {
+		t.Errorf("Digest = %s", sum)
+	}
+	if ts, _ := get(path, TimestampAttr); ts != "1600000000.123456789" {
+		t.Errorf("timestamp = %q", ts)
+	}
+
+	// A matching timestamp is trusted without reading the file.
+	if err := set(path, Prefix+"sha256", "cached"); err != nil {
+		t.Fatal(err)
+	}
+	if sum, _ := Digest(path); sum != "cached" {
+		t.Errorf("Digest did not use the cache: %s", sum)
+	}
+
+	// Verify rehashes and flags the mismatch without fixing it.
+	r, err := Verify(path)
+	if err != nil || r != (Result{Corrupt, "cached", helloSHA256}) {
+		t.Errorf("Verify = %+v, %v", r, err)
+	}
+	if sum, _ := Digest(path); sum != "cached" {
+		t.Error("Verify overwrote the digest of a corrupt file")
+	}
+
+	// A second algorithm shares the timestamp.
+	if sum, err := MD5.Digest(path); err != nil || sum != helloMD5 {
+		t.Errorf("MD5.Digest = %s, %v", sum, err)
+	}
+	if v, _ := get(path, Prefix+"sha256"); v != "cached" {
+		t.Error("sha256 digest removed although the timestamp was current")
+	}
+
+	// After a modification, the cached digests are outdated.
+	if err := ioutil.WriteFile(path, []byte("world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	r, err = Verify(path)
+	if err != nil || r != (Result{Outdated, "cached", worldSHA256}) {
+		t.Errorf("Verify = %+v, %v", r, err)
+	}
+	if _, err := get(path, Prefix+"md5"); !errors.Is(err, xattr.ENOATTR) {
+		t.Errorf("stale md5 digest kept: %v", err)
+	}
+	if r, err := Verify(path); err != nil || r.Status != OK {
+		t.Errorf("Verify = %+v, %v", r, err)
+	}
+}
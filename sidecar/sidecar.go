@@ -0,0 +1,421 @@
+/*
+Package sidecar stores extended attributes in hidden files next to the
+files they belong to, for filesystems that do not support them natively,
+such as FAT and exFAT media or some network shares.
+
+The attributes of "dir/name" are kept in "dir/.name.xattr". A Store is a
+xattr.Fallback, so registering it makes the functions of package xattr use
+sidecar files whenever the native call fails with ENOTSUP:
+
+	xattr.RegisterFallback(sidecar.New())
+
+Sidecar files do not follow their file when it is renamed or deleted by
+other programs. Use Rename and Remove to keep them together, Clean to
+delete orphaned sidecar files, and Store.Migrate to move the attributes to
+native storage once the files live on a filesystem that supports them.
+*/
+package sidecar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+)
+
+const (
+	// Suffix is the file name suffix of sidecar files.
+	Suffix = ".xattr"
+
+	// magic starts every sidecar file.
+	magic = "XATTR1\n"
+)
+
+// ErrFormat is returned for sidecar files that cannot be decoded.
+var ErrFormat = errors.New("sidecar: invalid file format")
+
+// Path returns the path of the sidecar file for path.
+func Path(path string) string {
+	dir, base := filepath.Split(filepath.Clean(path))
+	if base == "" || base == "." || base == ".." || base == string(filepath.Separator) {
+		return filepath.Join(path, Suffix)
+	}
+	return filepath.Join(dir, "."+base+Suffix)
+}
+
+// IsSidecar reports whether the file name looks like a sidecar file.
+func IsSidecar(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, ".") && strings.HasSuffix(base, Suffix)
+}
+
+// Store reads and writes sidecar files. It is safe for concurrent use
+// within a process; concurrent writers in different processes may lose
+// updates.
+type Store struct {
+	mu sync.Mutex
+	// bypass lists the paths Migrate is writing natively; the Store
+	// refuses to act as their fallback meanwhile.
+	bypass map[string]bool
+}
+
+// New returns a Store.
+func New() *Store {
+	return &Store{bypass: map[string]bool{}}
+}
+
+// resolve checks that path exists and returns the path of its sidecar
+// file, following a symlink at the end of path if follow is set.
+func (s *Store) resolve(path string, follow bool) (string, error) {
+	var err error
+	if follow {
+		_, err = os.Stat(path)
+		if err == nil {
+			path, err = filepath.EvalSymlinks(path)
+		}
+	} else {
+		_, err = os.Lstat(path)
+	}
+	if err != nil {
+		return "", errno(err)
+	}
+	return Path(path), nil
+}
+
+// errno returns the syscall error behind err, so that callers see the same
+// errors as from the native calls.
+func errno(err error) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return pe.Err
+	}
+	return err
+}
+
+// Get implements xattr.Fallback.
+func (s *Store) Get(path, name string, follow bool) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, err := s.resolve(path, follow)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := read(sc)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := attrs[name]
+	if !ok {
+		return nil, xattr.ENOATTR
+	}
+	return value, nil
+}
+
+// Set implements xattr.Fallback.
+func (s *Store) Set(path, name string, data []byte, flags int, follow bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bypass[path] {
+		return xattr.ENOTSUP
+	}
+	sc, err := s.resolve(path, follow)
+	if err != nil {
+		return err
+	}
+	attrs, err := read(sc)
+	if err != nil {
+		return err
+	}
+	_, exists := attrs[name]
+	if flags&xattr.XATTR_CREATE != 0 && exists {
+		return syscall.EEXIST
+	}
+	if flags&xattr.XATTR_REPLACE != 0 && !exists {
+		return xattr.ENOATTR
+	}
+	attrs[name] = append([]byte{}, data...)
+	return write(sc, attrs)
+}
+
+// Remove implements xattr.Fallback. The sidecar file is deleted when its
+// last attribute is removed.
+func (s *Store) Remove(path, name string, follow bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, err := s.resolve(path, follow)
+	if err != nil {
+		return err
+	}
+	attrs, err := read(sc)
+	if err != nil {
+		return err
+	}
+	if _, ok := attrs[name]; !ok {
+		return xattr.ENOATTR
+	}
+	delete(attrs, name)
+	return write(sc, attrs)
+}
+
+// List implements xattr.Fallback.
+func (s *Store) List(path string, follow bool) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, err := s.resolve(path, follow)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := read(sc)
+	if err != nil {
+		return nil, err
+	}
+	return sortedNames(attrs), nil
+}
+
+func sortedNames(attrs map[string][]byte) []string {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// read decodes a sidecar file. A missing file holds no attributes.
+//
+// The format is the magic string followed by one entry per attribute, each
+// consisting of the uvarint length of the name, the name, the uvarint
+// length of the value and the value.
+func read(sc string) (map[string][]byte, error) {
+	attrs := map[string][]byte{}
+	data, err := ioutil.ReadFile(sc)
+	if os.IsNotExist(err) {
+		return attrs, nil
+	}
+	if err != nil {
+		return nil, errno(err)
+	}
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, ErrFormat
+	}
+	data = data[len(magic):]
+	next := func() ([]byte, bool) {
+		n, size := binary.Uvarint(data)
+		if size <= 0 || uint64(len(data)-size) < n {
+			return nil, false
+		}
+		b := data[size : size+int(n)]
+		data = data[size+int(n):]
+		return b, true
+	}
+	for len(data) > 0 {
+		name, ok1 := next()
+		value, ok2 := next()
+		if !ok1 || !ok2 {
+			return nil, ErrFormat
+		}
+		attrs[string(name)] = value
+	}
+	return attrs, nil
+}
+
+// write replaces a sidecar file atomically, or removes it if attrs is
+// empty.
+func write(sc string, attrs map[string][]byte) error {
+	if len(attrs) == 0 {
+		if err := os.Remove(sc); err != nil && !os.IsNotExist(err) {
+			return errno(err)
+		}
+		return nil
+	}
+	buf := []byte(magic)
+	var tmp [binary.MaxVarintLen64]byte
+	for _, name := range sortedNames(attrs) {
+		value := attrs[name]
+		buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(name)))]...)
+		buf = append(buf, name...)
+		buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(value)))]...)
+		buf = append(buf, value...)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(sc), filepath.Base(sc)+".tmp")
+	if err != nil {
+		return errno(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return errno(err)
+	}
+	if err := f.Close(); err != nil {
+		return errno(err)
+	}
+	return errno(os.Rename(f.Name(), sc))
+}
+
+// Rename renames oldpath to newpath together with its sidecar file.
+func Rename(oldpath, newpath string) error {
+	if err := os.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	err := os.Rename(Path(oldpath), Path(newpath))
+	if os.IsNotExist(err) {
+		// newpath must not keep the attributes of the file it replaced.
+		err = os.Remove(Path(newpath))
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Remove removes path and its sidecar file.
+func Remove(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	err := os.Remove(Path(path))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// owner returns the path of the file the sidecar file sc belongs to.
+func owner(sc string) string {
+	dir, base := filepath.Split(sc)
+	if base == Suffix {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(dir, base[1:len(base)-len(Suffix)])
+}
+
+// CleanStats reports the work done by Clean.
+type CleanStats struct {
+	Removed int // orphaned sidecar files removed
+	Skipped int // orphaned files kept because they are not sidecar files
+}
+
+// Clean removes the sidecar files in the tree rooted at root whose file no
+// longer exists. Files with a sidecar name that do not start with the
+// sidecar format's magic string are left alone and counted as skipped.
+func Clean(root string) (CleanStats, error) {
+	var st CleanStats
+	err := walk.Walk(root, func(path, _ string, info os.FileInfo) error {
+		if info.IsDir() || !IsSidecar(path) {
+			return nil
+		}
+		if _, err := os.Lstat(owner(path)); !os.IsNotExist(err) {
+			return nil
+		}
+		ok, err := hasMagic(path, info)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			st.Skipped++
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		st.Removed++
+		return nil
+	})
+	return st, err
+}
+
+// hasMagic reports whether the regular file path starts with magic.
+func hasMagic(path string, info os.FileInfo) (bool, error) {
+	if !info.Mode().IsRegular() {
+		return false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return false, nil
+	}
+	return string(buf) == magic, nil
+}
+
+// MigrateStats reports the work done by Migrate.
+type MigrateStats struct {
+	Files  int // files whose attributes were all moved
+	Attrs  int // attributes moved
+	Failed int // files that keep their sidecar file
+}
+
+// Migrate moves the attributes of every sidecar file in the tree rooted at
+// root to native extended attributes and removes the sidecar files. Files
+// whose attributes cannot all be stored natively keep their sidecar file;
+// the first such error is returned after the walk. Existing native
+// attributes are overwritten.
+//
+// s may be registered as the fallback while Migrate runs; writes that the
+// filesystem rejects are then not redirected back to the sidecar file.
+func (s *Store) Migrate(root string) (MigrateStats, error) {
+	var st MigrateStats
+	var firstErr error
+	err := walk.Walk(root, func(path, _ string, info os.FileInfo) error {
+		if info.IsDir() || !IsSidecar(path) {
+			return nil
+		}
+		file := owner(path)
+		if _, err := os.Lstat(file); err != nil {
+			return nil
+		}
+		n, err := s.migrate(file, path)
+		st.Attrs += n
+		if err != nil {
+			st.Failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			return nil
+		}
+		st.Files++
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+	return st, firstErr
+}
+
+func (s *Store) migrate(path, sc string) (int, error) {
+	s.mu.Lock()
+	attrs, err := read(sc)
+	s.bypass[path] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.bypass, path)
+		s.mu.Unlock()
+	}()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, name := range sortedNames(attrs) {
+		if err := xattr.LSet(path, name, attrs[name]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return n, os.Remove(sc)
+}
@@ -0,0 +1,287 @@
+package sidecar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/walk"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "xattr-sidecar-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func touch(t *testing.T, path string) {
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"a/b.txt":   "a/.b.txt.xattr",
+		"a/dir/":    "a/.dir.xattr",
+		"file":      ".file.xattr",
+		"/":         "/.xattr",
+		"/a/b/../c": "/a/.c.xattr",
+	} {
+		path, want = filepath.FromSlash(path), filepath.FromSlash(want)
+		if got := Path(path); got != want {
+			t.Errorf("Path(%q) = %q, want %q", path, got, want)
+		}
+		if !IsSidecar(Path(path)) {
+			t.Errorf("IsSidecar(%q) = false", Path(path))
+		}
+	}
+	if IsSidecar("a/b.txt") {
+		t.Error("IsSidecar(a/b.txt) = true")
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	touch(t, path)
+
+	s := New()
+	if names, err := s.List(path, true); err != nil || len(names) != 0 {
+		t.Fatalf("List = %v, %v", names, err)
+	}
+	if _, err := s.Get(path, "user.a", true); err != xattr.ENOATTR {
+		t.Errorf("Get of missing attribute: %v", err)
+	}
+	if err := s.Set(path, "user.a", []byte("1"), xattr.XATTR_REPLACE, true); err != xattr.ENOATTR {
+		t.Errorf("Set with XATTR_REPLACE of missing attribute: %v", err)
+	}
+	if err := s.Set(path, "user.a", []byte("1"), xattr.XATTR_CREATE, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(path, "user.a", []byte("2"), xattr.XATTR_CREATE, true); err != syscall.EEXIST {
+		t.Errorf("Set with XATTR_CREATE of existing attribute: %v", err)
+	}
+	if err := s.Set(path, "b\xff", []byte{}, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(Path(path)) {
+		t.Fatal("no sidecar file")
+	}
+
+	// A new Store reads what the first one wrote.
+	s = New()
+	if names, err := s.List(path, true); err != nil || !reflect.DeepEqual(names, []string{"b\xff", "user.a"}) {
+		t.Errorf("List = %q, %v", names, err)
+	}
+	if v, err := s.Get(path, "user.a", true); err != nil || string(v) != "1" {
+		t.Errorf("Get = %q, %v", v, err)
+	}
+	if v, err := s.Get(path, "b\xff", true); err != nil || len(v) != 0 {
+		t.Errorf("Get = %q, %v", v, err)
+	}
+
+	if err := s.Remove(path, "user.c", true); err != xattr.ENOATTR {
+		t.Errorf("Remove of missing attribute: %v", err)
+	}
+	for _, name := range []string{"user.a", "b\xff"} {
+		if err := s.Remove(path, name, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exists(Path(path)) {
+		t.Error("sidecar file not removed with the last attribute")
+	}
+
+	if _, err := s.List(filepath.Join(dir, "missing"), true); err != syscall.ENOENT {
+		t.Errorf("List of missing file: %v", err)
+	}
+	if err := ioutil.WriteFile(Path(path), []byte("junk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.List(path, true); err != ErrFormat {
+		t.Errorf("List of corrupt sidecar file: %v", err)
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	target, link := filepath.Join(dir, "target"), filepath.Join(dir, "link")
+	touch(t, target)
+	if err := os.Symlink("target", link); err != nil {
+		t.Skip(err)
+	}
+	s := New()
+	if err := s.Set(link, "user.followed", nil, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(link, "user.link", nil, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := s.List(target, false); !reflect.DeepEqual(names, []string{"user.followed"}) {
+		t.Errorf("target has %q", names)
+	}
+	if names, _ := s.List(link, false); !reflect.DeepEqual(names, []string{"user.link"}) {
+		t.Errorf("link has %q", names)
+	}
+}
+
+func TestRenameRemoveClean(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	touch(t, a)
+	touch(t, c)
+	s := New()
+	for _, path := range []string{a, c} {
+		if err := s.Set(path, "user.x", []byte(path), 0, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Rename(a, b); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Get(b, "user.x", false); err != nil || string(v) != a {
+		t.Errorf("after Rename: %q, %v", v, err)
+	}
+	if exists(Path(a)) {
+		t.Error("old sidecar file still exists")
+	}
+	if err := Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if exists(Path(b)) {
+		t.Error("sidecar file not removed")
+	}
+
+	if err := os.Remove(c); err != nil {
+		t.Fatal(err)
+	}
+	// A user file that merely has a sidecar name is kept.
+	user := filepath.Join(dir, ".notes.xattr")
+	if err := ioutil.WriteFile(user, []byte("my notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	st, err := Clean(dir)
+	if err != nil || st != (CleanStats{Removed: 1, Skipped: 1}) {
+		t.Errorf("Clean = %+v, %v", st, err)
+	}
+	if exists(Path(c)) {
+		t.Error("orphaned sidecar file not removed")
+	}
+	if !exists(user) {
+		t.Error("user file removed")
+	}
+}
+
+func TestRenameOverSidecar(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	touch(t, a)
+	touch(t, b)
+	s := New()
+	if err := s.Set(b, "user.x", []byte("b"), 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rename(a, b); err != nil {
+		t.Fatal(err)
+	}
+	if exists(Path(b)) {
+		t.Error("renamed file inherited the sidecar file of the file it replaced")
+	}
+	if names, err := s.List(b, false); err != nil || len(names) != 0 {
+		t.Errorf("List after Rename = %q, %v", names, err)
+	}
+}
+
+// noxattrDir returns a new directory on a filesystem without extended
+// attribute support, or skips the test if there is none.
+func noxattrDir(t *testing.T) string {
+	for _, parent := range []string{os.TempDir(), "/dev/shm", "/run/user/" + strconv.Itoa(os.Getuid())} {
+		dir, err := ioutil.TempDir(parent, "xattr-sidecar-")
+		if err != nil {
+			continue
+		}
+		if err := xattr.LSet(dir, "user.probe", nil); walk.Unsupported(err) {
+			return dir
+		}
+		os.RemoveAll(dir)
+	}
+	t.Skip("no filesystem without extended attribute support")
+	return ""
+}
+
+func TestFallback(t *testing.T) {
+	dir := noxattrDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	touch(t, path)
+
+	s := New()
+	xattr.RegisterFallback(s)
+	defer xattr.RegisterFallback(nil)
+	if err := xattr.Set(path, "user.plain", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := xattr.Get(path, "user.plain"); err != nil || string(v) != "value" {
+		t.Errorf("Get = %q, %v", v, err)
+	}
+	if !exists(Path(path)) {
+		t.Error("no sidecar file")
+	}
+
+	// Migrate must not move the attribute back into the sidecar file.
+	st, err := s.Migrate(dir)
+	if err == nil || st.Failed != 1 || st.Files != 0 {
+		t.Errorf("Migrate = %+v, %v", st, err)
+	}
+	if v, err := s.Get(path, "user.plain", false); err != nil || string(v) != "value" {
+		t.Errorf("after failed Migrate: %q, %v", v, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	touch(t, path)
+	xattrtest.Check(t, xattr.LSet(path, "user.probe", nil))
+	s := New()
+	for _, name := range []string{"user.a", "user.b"} {
+		if err := s.Set(path, name, []byte(name), 0, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := s.Migrate(dir)
+	if err != nil || st != (MigrateStats{Files: 1, Attrs: 2}) {
+		t.Fatalf("Migrate = %+v, %v", st, err)
+	}
+	if exists(Path(path)) {
+		t.Error("sidecar file not removed")
+	}
+	for _, name := range []string{"user.a", "user.b"} {
+		if v, err := xattr.LGet(path, name); err != nil || string(v) != name {
+			t.Errorf("LGet(%s) = %q, %v", name, v, err)
+		}
+	}
+	if st, err := s.Migrate(dir); err != nil || st != (MigrateStats{}) {
+		t.Errorf("second Migrate = %+v, %v", st, err)
+	}
+}
@@ -13,12 +13,35 @@ Example for "L" variant, assuming path is "/symlink1/symlink2", where both compo
 symlinks:
 Get will follow "symlink1" and "symlink2" and operate on the target of
 "symlink2". LGet will follow "symlink1" but operate directly on "symlink2".
+
+Where the platform or the filesystem does not support extended attributes,
+all functions fail with ENOTSUP, unless a Fallback has been registered with
+RegisterFallback to store the attributes elsewhere. Each call decides on its
+own: the Fallback is used exactly when the native call fails with ENOTSUP.
+Attributes the Fallback holds for a file on a filesystem with native
+support are therefore invisible, and on filesystems that list attributes
+but cannot store them, such as procfs, List does not return the names the
+Fallback holds.
+
+Attribute names mean different things on different platforms. Linux requires
+a namespace prefix such as "user.", while macOS and Solaris have no
+namespaces and FreeBSD and NetBSD only know the "user." and "system."
+namespaces, where unprefixed names are in the user namespace. NativeName,
+CanonicalName and TranslateName convert between the native names of a
+platform and a canonical form, which is the Linux form:
+
+	canonical                    darwin                 freebsd, netbsd
+	user.com.apple.quarantine    com.apple.quarantine   user.com.apple.quarantine
+	system.posix1e.acl_access    (not representable)    system.posix1e.acl_access
+	trusted.overlay.opaque       (not representable)    (not representable)
+
+Use TranslateName when carrying attributes from one platform to another, for
+example when copying files or unpacking archives.
 */
 package xattr
 
 import (
 	"os"
-	"syscall"
 )
 
 // Error records an error and the operation, file path and attribute that caused it.
@@ -59,23 +82,38 @@ func (e *Error) Error() (errstr string) {
 // Get retrieves extended attribute data associated with path. It will follow
 // all symlinks along the path.
 func Get(path, name string) ([]byte, error) {
-	return get(path, name, func(name string, data []byte) (int, error) {
+	data, err := get(path, name, func(name string, data []byte) (int, error) {
 		return getxattr(path, name, data)
 	})
+	if fb := fallbackFor(err, name); fb != nil {
+		data, err = fb.Get(path, name, true)
+		return data, fallbackError("xattr.get", path, name, err)
+	}
+	return data, err
 }
 
 // LGet is like Get but does not follow a symlink at the end of the path.
 func LGet(path, name string) ([]byte, error) {
-	return get(path, name, func(name string, data []byte) (int, error) {
+	data, err := get(path, name, func(name string, data []byte) (int, error) {
 		return lgetxattr(path, name, data)
 	})
+	if fb := fallbackFor(err, name); fb != nil {
+		data, err = fb.Get(path, name, false)
+		return data, fallbackError("xattr.get", path, name, err)
+	}
+	return data, err
 }
 
 // FGet is like Get but accepts a os.File instead of a file path.
 func FGet(f *os.File, name string) ([]byte, error) {
-	return get(f.Name(), name, func(name string, data []byte) (int, error) {
+	data, err := get(f.Name(), name, func(name string, data []byte) (int, error) {
 		return fgetxattr(f, name, data)
 	})
+	if fb := fallbackFor(err, name); fb != nil {
+		data, err = fb.Get(f.Name(), name, true)
+		return data, fallbackError("xattr.get", f.Name(), name, err)
+	}
+	return data, err
 }
 
 type getxattrFunc func(name string, data []byte) (int, error)
@@ -110,11 +148,11 @@ func get(path string, name string, getxattrFunc getxattrFunc) ([]byte, error) {
 		//   MacOS never seems to return ERANGE!
 		// To keep the code simple, we always check both conditions, and sometimes
 		// double the buffer size without it being strictly necessary.
-		if err == syscall.ERANGE || err == syscall.E2BIG || read == size {
+		if err == errRange || err == errTooBig || read == size {
 			// The buffer was too small. Try again.
 			size <<= 1
 			if size >= maxBufSize {
-				return nil, &Error{myname, path, name, syscall.EOVERFLOW}
+				return nil, &Error{myname, path, name, errOverflow}
 			}
 			continue
 		}
@@ -128,6 +166,9 @@ func get(path string, name string, getxattrFunc getxattrFunc) ([]byte, error) {
 // Set associates name and data together as an attribute of path.
 func Set(path, name string, data []byte) error {
 	if err := setxattr(path, name, data, 0); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.Set", path, name, fb.Set(path, name, data, 0, true))
+		}
 		return &Error{"xattr.Set", path, name, err}
 	}
 	return nil
@@ -137,6 +178,9 @@ func Set(path, name string, data []byte) error {
 // the end of the path.
 func LSet(path, name string, data []byte) error {
 	if err := lsetxattr(path, name, data, 0); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.LSet", path, name, fb.Set(path, name, data, 0, false))
+		}
 		return &Error{"xattr.LSet", path, name, err}
 	}
 	return nil
@@ -145,6 +189,9 @@ func LSet(path, name string, data []byte) error {
 // FSet is like Set but accepts a os.File instead of a file path.
 func FSet(f *os.File, name string, data []byte) error {
 	if err := fsetxattr(f, name, data, 0); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.FSet", f.Name(), name, fb.Set(f.Name(), name, data, 0, true))
+		}
 		return &Error{"xattr.FSet", f.Name(), name, err}
 	}
 	return nil
@@ -154,6 +201,9 @@ func FSet(f *os.File, name string, data []byte) error {
 // Forwards the flags parameter to the syscall layer.
 func SetWithFlags(path, name string, data []byte, flags int) error {
 	if err := setxattr(path, name, data, flags); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.SetWithFlags", path, name, fb.Set(path, name, data, flags, true))
+		}
 		return &Error{"xattr.SetWithFlags", path, name, err}
 	}
 	return nil
@@ -163,6 +213,9 @@ func SetWithFlags(path, name string, data []byte, flags int) error {
 // the end of the path.
 func LSetWithFlags(path, name string, data []byte, flags int) error {
 	if err := lsetxattr(path, name, data, flags); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.LSetWithFlags", path, name, fb.Set(path, name, data, flags, false))
+		}
 		return &Error{"xattr.LSetWithFlags", path, name, err}
 	}
 	return nil
@@ -171,6 +224,9 @@ func LSetWithFlags(path, name string, data []byte, flags int) error {
 // FSetWithFlags is like SetWithFlags but accepts a os.File instead of a file path.
 func FSetWithFlags(f *os.File, name string, data []byte, flags int) error {
 	if err := fsetxattr(f, name, data, flags); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.FSetWithFlags", f.Name(), name, fb.Set(f.Name(), name, data, flags, true))
+		}
 		return &Error{"xattr.FSetWithFlags", f.Name(), name, err}
 	}
 	return nil
@@ -179,6 +235,9 @@ func FSetWithFlags(f *os.File, name string, data []byte, flags int) error {
 // Remove removes the attribute associated with the given path.
 func Remove(path, name string) error {
 	if err := removexattr(path, name); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.Remove", path, name, fb.Remove(path, name, true))
+		}
 		return &Error{"xattr.Remove", path, name, err}
 	}
 	return nil
@@ -188,6 +247,9 @@ func Remove(path, name string) error {
 // path.
 func LRemove(path, name string) error {
 	if err := lremovexattr(path, name); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.LRemove", path, name, fb.Remove(path, name, false))
+		}
 		return &Error{"xattr.LRemove", path, name, err}
 	}
 	return nil
@@ -196,6 +258,9 @@ func LRemove(path, name string) error {
 // FRemove is like Remove but accepts a os.File instead of a file path.
 func FRemove(f *os.File, name string) error {
 	if err := fremovexattr(f, name); err != nil {
+		if fb := fallbackFor(err, name); fb != nil {
+			return fallbackError("xattr.FRemove", f.Name(), name, fb.Remove(f.Name(), name, true))
+		}
 		return &Error{"xattr.FRemove", f.Name(), name, err}
 	}
 	return nil
@@ -204,24 +269,39 @@ func FRemove(f *os.File, name string) error {
 // List retrieves a list of names of extended attributes associated
 // with the given path in the file system.
 func List(path string) ([]string, error) {
-	return list(path, func(data []byte) (int, error) {
+	names, err := list(path, func(data []byte) (int, error) {
 		return listxattr(path, data)
 	})
+	if fb := fallbackFor(err, ""); fb != nil {
+		names, err = fb.List(path, true)
+		return names, fallbackError("xattr.list", path, "", err)
+	}
+	return names, err
 }
 
 // LList is like List but does not follow a symlink at the end of the
 // path.
 func LList(path string) ([]string, error) {
-	return list(path, func(data []byte) (int, error) {
+	names, err := list(path, func(data []byte) (int, error) {
 		return llistxattr(path, data)
 	})
+	if fb := fallbackFor(err, ""); fb != nil {
+		names, err = fb.List(path, false)
+		return names, fallbackError("xattr.list", path, "", err)
+	}
+	return names, err
 }
 
 // FList is like List but accepts a os.File instead of a file path.
 func FList(f *os.File) ([]string, error) {
-	return list(f.Name(), func(data []byte) (int, error) {
+	names, err := list(f.Name(), func(data []byte) (int, error) {
 		return flistxattr(f, data)
 	})
+	if fb := fallbackFor(err, ""); fb != nil {
+		names, err = fb.List(f.Name(), true)
+		return names, fallbackError("xattr.list", f.Name(), "", err)
+	}
+	return names, err
 }
 
 type listxattrFunc func(data []byte) (int, error)
@@ -13,12 +13,24 @@ const (
 	// XATTR_SUPPORTED will be true if the current platform is supported
 	XATTR_SUPPORTED = true
 
-	EXTATTR_NAMESPACE_USER = 1
+	// XATTR_CREATE and XATTR_REPLACE are accepted for compatibility with
+	// the other platforms. The extattr syscalls have no flags, so they are
+	// ignored by SetWithFlags.
+	XATTR_CREATE  = 0x1
+	XATTR_REPLACE = 0x2
+
+	EXTATTR_NAMESPACE_USER   = extattrNamespaceUser
+	EXTATTR_NAMESPACE_SYSTEM = extattrNamespaceSystem
 
 	// ENOATTR is not exported by the syscall package on Linux, because it is
 	// an alias for ENODATA. We export it here so it is available on all
 	// our supported platforms.
 	ENOATTR = syscall.ENOATTR
+
+	// ENOTSUP is returned when the filesystem does not support extended
+	// attributes. The extattr syscalls report EOPNOTSUPP, which differs
+	// from ENOTSUP on NetBSD.
+	ENOTSUP = syscall.EOPNOTSUPP
 )
 
 func getxattr(path string, name string, data []byte) (int, error) {
@@ -37,6 +49,7 @@ func fgetxattr(f *os.File, name string, data []byte) (int, error) {
 // number. This works because syscalls have the same signature and return
 // values.
 func sysGet(syscallNum uintptr, path string, name string, data []byte) (int, error) {
+	namespace, attrname := splitExtattrName(name)
 	ptr, nbytes := bytePtrFromSlice(data)
 	/*
 		ssize_t extattr_get_file(
@@ -54,7 +67,7 @@ func sysGet(syscallNum uintptr, path string, name string, data []byte) (int, err
 			size_t nbytes);
 	*/
 	r0, _, err := syscall.Syscall6(syscallNum, uintptr(unsafe.Pointer(syscall.StringBytePtr(path))),
-		EXTATTR_NAMESPACE_USER, uintptr(unsafe.Pointer(syscall.StringBytePtr(name))),
+		uintptr(namespace), uintptr(unsafe.Pointer(syscall.StringBytePtr(attrname))),
 		uintptr(unsafe.Pointer(ptr)), uintptr(nbytes), 0)
 	if err != syscall.Errno(0) {
 		return int(r0), err
@@ -78,6 +91,7 @@ func fsetxattr(f *os.File, name string, data []byte, flags int) error {
 // number. This works because syscalls have the same signature and return
 // values.
 func sysSet(syscallNum uintptr, path string, name string, data []byte) error {
+	namespace, attrname := splitExtattrName(name)
 	ptr, nbytes := bytePtrFromSlice(data)
 	/*
 		ssize_t extattr_set_file(
@@ -97,7 +111,7 @@ func sysSet(syscallNum uintptr, path string, name string, data []byte) error {
 		);
 	*/
 	r0, _, err := syscall.Syscall6(syscallNum, uintptr(unsafe.Pointer(syscall.StringBytePtr(path))),
-		EXTATTR_NAMESPACE_USER, uintptr(unsafe.Pointer(syscall.StringBytePtr(name))),
+		uintptr(namespace), uintptr(unsafe.Pointer(syscall.StringBytePtr(attrname))),
 		uintptr(unsafe.Pointer(ptr)), uintptr(nbytes), 0)
 	if err != syscall.Errno(0) {
 		return err
@@ -120,10 +134,11 @@ func fremovexattr(f *os.File, name string) error {
 	return removexattr(f.Name(), name)
 }
 
-// sysSet is called by removexattr and lremovexattr with the appropriate syscall
+// sysRemove is called by removexattr and lremovexattr with the appropriate syscall
 // number. This works because syscalls have the same signature and return
 // values.
 func sysRemove(syscallNum uintptr, path string, name string) error {
+	namespace, attrname := splitExtattrName(name)
 	/*
 		int extattr_delete_file(
 			const char *path,
@@ -138,7 +153,7 @@ func sysRemove(syscallNum uintptr, path string, name string) error {
 		);
 	*/
 	_, _, err := syscall.Syscall(syscallNum, uintptr(unsafe.Pointer(syscall.StringBytePtr(path))),
-		EXTATTR_NAMESPACE_USER, uintptr(unsafe.Pointer(syscall.StringBytePtr(name))),
+		uintptr(namespace), uintptr(unsafe.Pointer(syscall.StringBytePtr(attrname))),
 	)
 	if err != syscall.Errno(0) {
 		return err
@@ -158,10 +173,43 @@ func flistxattr(f *os.File, data []byte) (int, error) {
 	return listxattr(f.Name(), data)
 }
 
-// sysSet is called by listxattr and llistxattr with the appropriate syscall
-// number. This works because syscalls have the same signature and return
-// values.
+// sysList is called by listxattr and llistxattr with the appropriate syscall
+// number. It lists the user and the system namespace and returns the names
+// with their namespace prefix, NULL-terminated as on Linux. The system
+// namespace is only readable by root; for other users it is left out.
 func sysList(syscallNum uintptr, path string, data []byte) (int, error) {
+	buf, err := sysListNamespace(syscallNum, path, EXTATTR_NAMESPACE_USER, nil)
+	if err != nil {
+		return 0, err
+	}
+	buf, err = sysListNamespace(syscallNum, path, EXTATTR_NAMESPACE_SYSTEM, buf)
+	if err != nil && err != syscall.EPERM && err != syscall.EACCES {
+		return 0, err
+	}
+	if data == nil {
+		return len(buf), nil
+	}
+	if len(data) < len(buf) {
+		return 0, syscall.ERANGE
+	}
+	return copy(data, buf), nil
+}
+
+// sysListNamespace lists a single namespace and appends the result to dst.
+func sysListNamespace(syscallNum uintptr, path string, namespace int, dst []byte) ([]byte, error) {
+	size, err := sysListRaw(syscallNum, path, namespace, nil)
+	if err != nil || size == 0 {
+		return dst, err
+	}
+	buf := make([]byte, size)
+	read, err := sysListRaw(syscallNum, path, namespace, buf)
+	if err != nil {
+		return dst, err
+	}
+	return appendExtattrList(dst, namespace, buf[:read]), nil
+}
+
+func sysListRaw(syscallNum uintptr, path string, namespace int, data []byte) (int, error) {
 	ptr, nbytes := bytePtrFromSlice(data)
 	/*
 		ssize_t extattr_list_file(
@@ -179,7 +227,7 @@ func sysList(syscallNum uintptr, path string, data []byte) (int, error) {
 		);
 	*/
 	r0, _, err := syscall.Syscall6(syscallNum, uintptr(unsafe.Pointer(syscall.StringBytePtr(path))),
-		EXTATTR_NAMESPACE_USER, uintptr(unsafe.Pointer(ptr)), uintptr(nbytes), 0, 0)
+		uintptr(namespace), uintptr(unsafe.Pointer(ptr)), uintptr(nbytes), 0, 0)
 	if err != syscall.Errno(0) {
 		return int(r0), err
 	}
@@ -187,15 +235,15 @@ func sysList(syscallNum uintptr, path string, data []byte) (int, error) {
 }
 
 // stringsFromByteSlice converts a sequence of attributes to a []string.
-// On FreeBSD, each entry consists of a single byte containing the length
-// of the attribute name, followed by the attribute name.
-// The name is _not_ terminated by NULL.
+// sysList has already converted the FreeBSD format, so each entry is a
+// NULL-terminated string as on Darwin and Linux.
 func stringsFromByteSlice(buf []byte) (result []string) {
-	index := 0
-	for index < len(buf) {
-		next := index + 1 + int(buf[index])
-		result = append(result, string(buf[index+1:next]))
-		index = next
+	offset := 0
+	for index, b := range buf {
+		if b == 0 {
+			result = append(result, string(buf[offset:index]))
+			offset = index + 1
+		}
 	}
 	return
 }
@@ -27,6 +27,10 @@ const (
 	// an alias for ENODATA. We export it here so it is available on all
 	// our supported platforms.
 	ENOATTR = syscall.ENOATTR
+
+	// ENOTSUP is returned when the filesystem does not support extended
+	// attributes.
+	ENOTSUP = syscall.ENOTSUP
 )
 
 func getxattr(path string, name string, data []byte) (int, error) {
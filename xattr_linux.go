@@ -21,6 +21,10 @@ const (
 	// an alias for ENODATA. We export it here so it is available on all
 	// our supported platforms.
 	ENOATTR = syscall.ENODATA
+
+	// ENOTSUP is returned when the filesystem does not support extended
+	// attributes.
+	ENOTSUP = syscall.ENOTSUP
 )
 
 // On Linux, FUSE and CIFS filesystems can return EINTR for interrupted system
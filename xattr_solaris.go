@@ -22,6 +22,10 @@ const (
 	// compatibility with other platforms, we make ENOATTR available as
 	// an alias of unix.ENOENT.
 	ENOATTR = unix.ENOENT
+
+	// ENOTSUP is returned when the filesystem does not support extended
+	// attributes.
+	ENOTSUP = unix.ENOTSUP
 )
 
 func getxattr(path string, name string, data []byte) (int, error) {
@@ -11,57 +11,68 @@ import (
 const (
 	// We need to use the default for non supported operating systems
 	ENOATTR = syscall.Errno(0x59)
+
+	// ENOTSUP is returned by all functions on this platform, unless a
+	// Fallback has been registered.
+	ENOTSUP = errNotSupported
 )
 
 // XATTR_SUPPORTED will be true if the current platform is supported
 const XATTR_SUPPORTED = false
 
+// XATTR_CREATE and XATTR_REPLACE are defined so that code using them builds
+// on all platforms.
+const (
+	XATTR_CREATE  = 0x1
+	XATTR_REPLACE = 0x2
+)
+
 func getxattr(path string, name string, data []byte) (int, error) {
-	return 0, nil
+	return 0, ENOTSUP
 }
 
 func lgetxattr(path string, name string, data []byte) (int, error) {
-	return 0, nil
+	return 0, ENOTSUP
 }
 
 func fgetxattr(f *os.File, name string, data []byte) (int, error) {
-	return 0, nil
+	return 0, ENOTSUP
 }
 
 func setxattr(path string, name string, data []byte, flags int) error {
-	return nil
+	return ENOTSUP
 }
 
 func lsetxattr(path string, name string, data []byte, flags int) error {
-	return nil
+	return ENOTSUP
 }
 
 func fsetxattr(f *os.File, name string, data []byte, flags int) error {
-	return nil
+	return ENOTSUP
 }
 
 func removexattr(path string, name string) error {
-	return nil
+	return ENOTSUP
 }
 
 func lremovexattr(path string, name string) error {
-	return nil
+	return ENOTSUP
 }
 
 func fremovexattr(f *os.File, name string) error {
-	return nil
+	return ENOTSUP
 }
 
 func listxattr(path string, data []byte) (int, error) {
-	return 0, nil
+	return 0, ENOTSUP
 }
 
 func llistxattr(path string, data []byte) (int, error) {
-	return 0, nil
+	return 0, ENOTSUP
 }
 
 func flistxattr(f *os.File, data []byte) (int, error) {
-	return 0, nil
+	return 0, ENOTSUP
 }
 
 // dummy
@@ -0,0 +1,26 @@
+//go:build !linux && !freebsd && !netbsd && !darwin && !solaris
+// +build !linux,!freebsd,!netbsd,!darwin,!solaris
+
+package xattr
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestUnsupported(t *testing.T) {
+	path := os.TempDir()
+	if _, err := Get(path, "user.test"); !errors.Is(err, ENOTSUP) {
+		t.Errorf("Get: want ENOTSUP, have %v", err)
+	}
+	if err := Set(path, "user.test", []byte("x")); !errors.Is(err, ENOTSUP) {
+		t.Errorf("Set: want ENOTSUP, have %v", err)
+	}
+	if err := Remove(path, "user.test"); !errors.Is(err, ENOTSUP) {
+		t.Errorf("Remove: want ENOTSUP, have %v", err)
+	}
+	if _, err := List(path); !errors.Is(err, ENOTSUP) {
+		t.Errorf("List: want ENOTSUP, have %v", err)
+	}
+}
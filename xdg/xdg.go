@@ -0,0 +1,207 @@
+/*
+Package xdg reads and writes the extended attributes of the Freedesktop
+"Common Extended Attributes" convention, which desktop applications on
+Linux use to record the provenance and metadata of files:
+
+	user.xdg.origin.url      URL the file was downloaded from
+	user.xdg.referrer.url    URL of the page that linked to it
+	user.xdg.comment         free-form comment
+	user.xdg.tags            comma-separated list of tags
+	user.xdg.language        language of the content, as an RFC 5646 tag
+	user.mime_type           media type, such as "text/plain"
+	user.charset             character set, such as "utf-8"
+
+The names are canonical names (see xattr.CanonicalName) and are converted to
+the native names of the current platform. Getters return the errors of
+xattr.Get, in particular ENOATTR if the attribute is not set.
+*/
+package xdg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/xattr"
+)
+
+// Attribute names.
+const (
+	OriginURL   = "user.xdg.origin.url"
+	ReferrerURL = "user.xdg.referrer.url"
+	Comment     = "user.xdg.comment"
+	Tags        = "user.xdg.tags"
+	Language    = "user.xdg.language"
+	MimeType    = "user.mime_type"
+	Charset     = "user.charset"
+)
+
+// Get returns the value of the attribute with the canonical name of path.
+func Get(path, name string) (string, error) {
+	native, err := xattr.Native(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := xattr.Get(path, native)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// Set sets the attribute with the canonical name of path to value.
+func Set(path, name, value string) error {
+	native, err := xattr.Native(name)
+	if err != nil {
+		return err
+	}
+	return xattr.Set(path, native, []byte(value))
+}
+
+func remove(path, name string) error {
+	native, err := xattr.Native(name)
+	if err != nil {
+		return err
+	}
+	return xattr.Remove(path, native)
+}
+
+// GetOriginURL returns the URL path was downloaded from.
+func GetOriginURL(path string) (string, error) { return Get(path, OriginURL) }
+
+// SetOriginURL sets the URL path was downloaded from.
+func SetOriginURL(path, url string) error { return Set(path, OriginURL, url) }
+
+// GetReferrerURL returns the URL of the page that linked to path.
+func GetReferrerURL(path string) (string, error) { return Get(path, ReferrerURL) }
+
+// SetReferrerURL sets the URL of the page that linked to path.
+func SetReferrerURL(path, url string) error { return Set(path, ReferrerURL, url) }
+
+// GetComment returns the comment of path.
+func GetComment(path string) (string, error) { return Get(path, Comment) }
+
+// SetComment sets the comment of path.
+func SetComment(path, comment string) error { return Set(path, Comment, comment) }
+
+// GetLanguage returns the language of the content of path.
+func GetLanguage(path string) (string, error) { return Get(path, Language) }
+
+// SetLanguage sets the language of the content of path.
+func SetLanguage(path, lang string) error { return Set(path, Language, lang) }
+
+// GetMimeType returns the media type of path.
+func GetMimeType(path string) (string, error) { return Get(path, MimeType) }
+
+// SetMimeType sets the media type of path.
+func SetMimeType(path, mimeType string) error { return Set(path, MimeType, mimeType) }
+
+// GetCharset returns the character set of path.
+func GetCharset(path string) (string, error) { return Get(path, Charset) }
+
+// SetCharset sets the character set of path.
+func SetCharset(path, charset string) error { return Set(path, Charset, charset) }
+
+// ErrTag is returned for tags that cannot be stored in the comma-separated
+// list.
+var ErrTag = errors.New("xdg: tags must be non-empty and must not contain commas")
+
+// ParseTags splits a user.xdg.tags value into its tags. Whitespace around
+// tags and empty tags are dropped.
+func ParseTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// FormatTags joins tags into a user.xdg.tags value.
+func FormatTags(tags []string) (string, error) {
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" || strings.Contains(tag, ",") {
+			return "", fmt.Errorf("%w: %q", ErrTag, tag)
+		}
+	}
+	return strings.Join(tags, ","), nil
+}
+
+// GetTags returns the tags of path.
+func GetTags(path string) ([]string, error) {
+	value, err := Get(path, Tags)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTags(value), nil
+}
+
+// SetTags sets the tags of path. An empty list removes the attribute.
+func SetTags(path string, tags []string) error {
+	if len(tags) == 0 {
+		err := remove(path, Tags)
+		if errors.Is(err, xattr.ENOATTR) {
+			return nil
+		}
+		return err
+	}
+	value, err := FormatTags(tags)
+	if err != nil {
+		return err
+	}
+	return Set(path, Tags, value)
+}
+
+// currentTags returns the tags of path, treating a missing attribute as no
+// tags.
+func currentTags(path string) ([]string, error) {
+	tags, err := GetTags(path)
+	if errors.Is(err, xattr.ENOATTR) {
+		return nil, nil
+	}
+	return tags, err
+}
+
+// AddTags adds the tags that path does not have yet, keeping the order of
+// the existing ones.
+func AddTags(path string, tags ...string) error {
+	current, err := currentTags(path)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if !contains(current, strings.TrimSpace(tag)) {
+			current = append(current, strings.TrimSpace(tag))
+		}
+	}
+	return SetTags(path, current)
+}
+
+// RemoveTags removes tags from path. The attribute is removed with the last
+// tag.
+func RemoveTags(path string, tags ...string) error {
+	current, err := currentTags(path)
+	if err != nil {
+		return err
+	}
+	kept := current[:0]
+	for _, tag := range current {
+		if !contains(tags, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	if len(kept) == len(current) {
+		return nil
+	}
+	return SetTags(path, kept)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
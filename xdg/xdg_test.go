@@ -0,0 +1,78 @@
+package xdg
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/xattr"
+	"github.com/pkg/xattr/internal/xattrtest"
+)
+
+func TestParseFormatTags(t *testing.T) {
+	if got := ParseTags(" work, urgent,,  "); !reflect.DeepEqual(got, []string{"work", "urgent"}) {
+		t.Errorf("ParseTags = %q", got)
+	}
+	if got := ParseTags(""); got != nil {
+		t.Errorf("ParseTags(\"\") = %q", got)
+	}
+	if s, err := FormatTags([]string{"a", "b c"}); err != nil || s != "a,b c" {
+		t.Errorf("FormatTags = %q, %v", s, err)
+	}
+	for _, bad := range []string{"a,b", " "} {
+		if _, err := FormatTags([]string{bad}); !errors.Is(err, ErrTag) {
+			t.Errorf("FormatTags(%q) = %v", bad, err)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	f, err := ioutil.TempFile("", "xattr-xdg-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	path := f.Name()
+	defer os.Remove(path)
+
+	xattrtest.Check(t, SetOriginURL(path, "https://example.com/a.pdf"))
+	if url, err := GetOriginURL(path); err != nil || url != "https://example.com/a.pdf" {
+		t.Errorf("GetOriginURL = %q, %v", url, err)
+	}
+	if err := SetMimeType(path, "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := xattr.Get(path, "user.mime_type"); err != nil || string(v) != "application/pdf" {
+		t.Errorf("user.mime_type = %q, %v", v, err)
+	}
+	if _, err := GetComment(path); !errors.Is(err, xattr.ENOATTR) {
+		t.Errorf("GetComment of missing attribute: %v", err)
+	}
+
+	if err := AddTags(path, "work", "urgent"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddTags(path, "urgent", "2024"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := Get(path, Tags); v != "work,urgent,2024" {
+		t.Errorf("user.xdg.tags = %q", v)
+	}
+	if err := AddTags(path, "a,b"); !errors.Is(err, ErrTag) {
+		t.Errorf("AddTags with comma: %v", err)
+	}
+	if err := RemoveTags(path, "work", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := GetTags(path); err != nil || !reflect.DeepEqual(tags, []string{"urgent", "2024"}) {
+		t.Errorf("GetTags = %q, %v", tags, err)
+	}
+	if err := RemoveTags(path, "urgent", "2024"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetTags(path); !errors.Is(err, xattr.ENOATTR) {
+		t.Errorf("tags attribute not removed: %v", err)
+	}
+}